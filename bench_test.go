@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+/*
+Each workload is a closed term whose weak head normal form takes real work to reach. A
+Church numeral n applied to I and I reduces to I after n applications, so arithmetic on
+numerals feeding into "n I I" exercises the evaluators without a large result to read back.
+Every evaluator gets one benchmark with a sub-benchmark per workload, compare them with
+
+	go test -run '^$' -bench . -benchmem
+*/
+func evaluatorWorkloads() []NamedTerm {
+	prelude := LoadPrelude()
+	id := prelude.Cores["I"]
+	run := func(n Core) Core {
+		return CoreApply(n, id, id)
+	}
+	return []NamedTerm{
+		{Name: "numeral 200", Term: run(ChurchNumeral(200))},
+		{Name: "plus 100 100", Term: run(CoreApply(prelude.Cores["PLUS"], ChurchNumeral(100), ChurchNumeral(100)))},
+		{Name: "mult 20 20", Term: run(CoreApply(prelude.Cores["MULT"], ChurchNumeral(20), ChurchNumeral(20)))},
+		{Name: "pow 2 8", Term: run(CoreApply(prelude.Cores["POW"], ChurchNumeral(2), ChurchNumeral(8)))},
+		{Name: "pred 100", Term: run(CoreApply(prelude.Cores["PRED"], ChurchNumeral(100)))},
+		// X1 is forced twice, call-by-name redoes the multiplication, call-by-need does not
+		{Name: "shared mult", Term: CoreApply(
+			&CLam{Name: "X1", Body: CoreApply(&CVar{Index: 0, Name: "X1"}, CoreApply(&CVar{Index: 0, Name: "X1"}, id))},
+			run(CoreApply(prelude.Cores["MULT"], ChurchNumeral(20), ChurchNumeral(20))),
+		)},
+	}
+}
+
+// Run eval on every workload, failing the benchmark if it does not reach a result.
+func benchmarkWorkloads(b *testing.B, eval func(c Core) error) {
+	for _, workload := range evaluatorWorkloads() {
+		b.Run(workload.Name, func(b *testing.B) {
+			if eval_err := eval(workload.Term); eval_err != nil {
+				b.Fatal(eval_err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				eval(workload.Term)
+			}
+		})
+	}
+}
+
+func BenchmarkSubstCallByName(b *testing.B) {
+	benchmarkWorkloads(b, func(c Core) error {
+		_, _, err := Reduce(c, R_CallByName, 0)
+		return err
+	})
+}
+
+func BenchmarkSubstCallByValue(b *testing.B) {
+	benchmarkWorkloads(b, func(c Core) error {
+		_, _, err := Reduce(c, R_CallByValue, 0)
+		return err
+	})
+}
+
+func BenchmarkKrivine(b *testing.B) {
+	benchmarkWorkloads(b, func(c Core) error {
+		_, err := KrivineEval(c, 0)
+		return err
+	})
+}
+
+func BenchmarkCEK(b *testing.B) {
+	benchmarkWorkloads(b, func(c Core) error {
+		_, err := CEKEval(c, 0)
+		return err
+	})
+}
+
+func BenchmarkNeed(b *testing.B) {
+	benchmarkWorkloads(b, func(c Core) error {
+		_, _, err := NeedEval(c, 0)
+		return err
+	})
+}
+
+func BenchmarkNbE(b *testing.B) {
+	benchmarkWorkloads(b, func(c Core) error {
+		_, _, err := NormalizeNbE(c, 0)
+		return err
+	})
+}
+
+// Programs are compiled once per workload so only execution is measured.
+func BenchmarkVM(b *testing.B) {
+	programs := map[Core]*Program{}
+	benchmarkWorkloads(b, func(c Core) error {
+		if _, compiled := programs[c]; !compiled {
+			prog, compile_err := Compile(c)
+			if compile_err != nil {
+				return compile_err
+			}
+			programs[c] = prog
+		}
+		vm := CreateVM(programs[c])
+		if run_err := vm.Run(0); run_err != nil {
+			return run_err
+		}
+		vm.Result()
+		return nil
+	})
+}
+
+// A free head applied to arguments with large normal forms of their own, which normal order
+// normalizes one after the other and ReduceParallel concurrently.
+func parallelWorkloads() []NamedTerm {
+	prelude := LoadPrelude()
+	heavy := func(n int) Core {
+		return CoreApply(prelude.Cores["MULT"], ChurchNumeral(n), ChurchNumeral(n))
+	}
+	wide := func(width int, n int) Core {
+		args := []Core{}
+		for i := 0; i < width; i++ {
+			args = append(args, heavy(n))
+		}
+		return CoreApply(&CFree{Name: "F1"}, args...)
+	}
+	return []NamedTerm{
+		{Name: "4 x mult 12 12", Term: wide(4, 12)},
+		{Name: "8 x mult 12 12", Term: wide(8, 12)},
+		{Name: "2 x mult 24 24", Term: wide(2, 24)},
+	}
+}
+
+// Normal order by substitution against ReduceParallel on the same terms, with one worker
+// (its overhead alone) and with one per CPU.
+func BenchmarkParallel(b *testing.B) {
+	reducers := []struct {
+		name   string
+		reduce func(c Core) error
+	}{
+		{"normal order", func(c Core) error {
+			_, _, err := Reduce(c, R_NormalOrder, 0)
+			return err
+		}},
+		{"parallel 1", func(c Core) error {
+			_, _, err := ReduceParallel(c, 1, 0)
+			return err
+		}},
+		{"parallel", func(c Core) error {
+			_, _, err := ReduceParallel(c, 0, 0)
+			return err
+		}},
+	}
+	for _, workload := range parallelWorkloads() {
+		for _, reducer := range reducers {
+			b.Run(fmt.Sprintf("%v/%v", workload.Name, reducer.name), func(b *testing.B) {
+				if reduce_err := reducer.reduce(workload.Term); reduce_err != nil {
+					b.Fatal(reduce_err)
+				}
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					reducer.reduce(workload.Term)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+/*
+	Bytecode compiler and stack VM
+
+A closed Core term is compiled into a flat Program of Krivine-style instructions. Every
+argument of an application becomes its own block of code; a block is zero or more GRABs
+(one per lambda), then the PUSHes of an application spine, then the ACCESS of its head. For
+example λF1.λX1.(F1 X1) is the single block GRAB F1; GRAB X1; PUSHVAR 0; ACCESS 1.
+
+  - PUSH a: push a thunk for block a in the current environment
+  - PUSHVAR n: push the thunk already bound at index n, so it is shared
+  - ACCESS n: jump to the thunk bound at index n
+  - GRAB: bind the top of the stack and fall through into the lambda body, or halt when
+    the stack is empty
+
+Thunks are updated with their value the first time they are entered, so evaluation is
+call-by-need. The VM only runs pure lambda terms: Compile rejects literals and primitive
+operators, which have no instructions here, rather than leave them stuck. The VM stops at a
+weak head normal form, which for a closed term is always a lambda, and reads it back by
+decompiling the code under its environment.
+
+Bindings and thunks live in an index-addressed heap that only grows during a run, so a
+beta step costs an append and no garbage collector work. Measured with the benchmarks of
+bench_test.go, the VM is about 1.7 times faster than KrivineEval on plain numerals and
+arithmetic (numeral_200 11µs against 19µs, mult_20_20 29µs against 53µs) and about 2.5 to
+3 times faster where sharing pays (pow_2_8 39µs against 92µs, shared_mult 30µs against
+92µs), and 2 to 3 times faster than NeedEval and 4 to 15 times faster than CEKEval. It
+is not an order of magnitude faster than the tree-walking machines, only than the
+substitution based Reduce. The heap is not collected while running, which trades memory
+for long runs for this speed.
+*/
+type Opcode int
+
+const (
+	_ Opcode = iota
+	OP_Grab
+	OP_Push
+	OP_PushVar
+	OP_Access
+)
+
+func (op Opcode) ToString() string {
+	switch op {
+	case OP_Grab:
+		return "GRAB"
+	case OP_Push:
+		return "PUSH"
+	case OP_PushVar:
+		return "PUSHVAR"
+	case OP_Access:
+		return "ACCESS"
+	}
+	return "UNKNOWN"
+}
+
+type Instr struct {
+	Op Opcode
+	// Block address for PUSH, de Bruijn index for PUSHVAR and ACCESS
+	Arg int
+	// Binder name for GRAB, variable name for PUSHVAR and ACCESS, only used for display
+	Name string
+}
+
+type Program struct {
+	Code  []Instr
+	Entry int
+}
+
+type VMThunk struct {
+	PC  int
+	Env *VMEnv
+	// PC/Env are a lambda, i.e. the thunk has already been evaluated
+	Whnf bool
+}
+
+// Environment as read back by Decompile. While running, the VM keeps both in its heap.
+type VMEnv struct {
+	Value *VMThunk
+	Next  *VMEnv
+}
+
+func (e *VMEnv) Extend(value *VMThunk) *VMEnv {
+	return &VMEnv{Value: value, Next: e}
+}
+
+func (e *VMEnv) Lookup(index int) *VMThunk {
+	for ; e != nil; e = e.Next {
+		if index == 0 {
+			return e.Value
+		}
+		index -= 1
+	}
+	return nil
+}
+
+func (e *VMEnv) Len() int {
+	n := 0
+	for ; e != nil; e = e.Next {
+		n += 1
+	}
+	return n
+}
+
+// Compile a closed term. Free variables are rejected since the VM has nothing to bind them to,
+// and so are primitives since it has no delta rules: only pure terms run on it.
+func Compile(c Core) (*Program, error) {
+	free := map[string]bool{}
+	CoreFreeNames(c, free)
+	names, prims := []string{}, []string{}
+	for name := range free {
+		if _, is_prim := DecodePrim(&CFree{Name: name}); is_prim {
+			prims = append(prims, name)
+		} else {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(prims)
+	if len(prims) > 0 {
+		return nil, fmt.Errorf("Cannot compile primitives, the VM has no delta rules (the krivine, cek, "+
+			"call-by-need and NbE evaluators do): %v", strings.Join(prims, ", "))
+	} else if len(names) > 0 {
+		return nil, fmt.Errorf("Cannot compile open term, free variables: %v", strings.Join(names, ", "))
+	}
+	prog := &Program{Code: []Instr{}}
+	prog.Entry = prog.compileBlock(c)
+	return prog, nil
+}
+
+// Pending instruction of a block whose PUSH target still has to be compiled.
+type pendingInstr struct {
+	Instr
+	Block Core
+}
+
+// Emit the block for c after the blocks of all its arguments, returning its address.
+func (prog *Program) compileBlock(c Core) int {
+	pending := []pendingInstr{}
+	for {
+		if lam, is_lam := c.(*CLam); is_lam {
+			pending = append(pending, pendingInstr{Instr: Instr{Op: OP_Grab, Name: lam.Name}})
+			c = lam.Body
+			continue
+		}
+		head, args := CoreSpine(c)
+		for i := len(args) - 1; i >= 0; i-- {
+			if arg_var, is_var := args[i].(*CVar); is_var {
+				pending = append(pending, pendingInstr{Instr: Instr{Op: OP_PushVar, Arg: arg_var.Index, Name: arg_var.Name}})
+			} else {
+				pending = append(pending, pendingInstr{Instr: Instr{Op: OP_Push}, Block: args[i]})
+			}
+		}
+		if head_var, is_var := head.(*CVar); is_var {
+			pending = append(pending, pendingInstr{Instr: Instr{Op: OP_Access, Arg: head_var.Index, Name: head_var.Name}})
+			break
+		}
+		// A lambda in head position (a redex) continues the same block
+		c = head
+	}
+	for i := range pending {
+		if pending[i].Block != nil {
+			pending[i].Arg = prog.compileBlock(pending[i].Block)
+		}
+	}
+	addr := len(prog.Code)
+	for _, instr := range pending {
+		prog.Code = append(prog.Code, instr.Instr)
+	}
+	return addr
+}
+
+func (instr Instr) ToString() string {
+	switch instr.Op {
+	case OP_Grab:
+		return fmt.Sprintf("%-8v ; %v", instr.Op.ToString(), instr.Name)
+	case OP_Push:
+		return fmt.Sprintf("%-8v L%v", instr.Op.ToString(), instr.Arg)
+	}
+	return fmt.Sprintf("%-8v %-4v ; %v", instr.Op.ToString(), instr.Arg, instr.Name)
+}
+
+// Write a listing of the program, one instruction per line, with a label at every block.
+func (prog *Program) Disassemble(w io.Writer) {
+	labels := map[int]bool{prog.Entry: true}
+	for _, instr := range prog.Code {
+		if instr.Op == OP_Push {
+			labels[instr.Arg] = true
+		}
+	}
+	for pc, instr := range prog.Code {
+		if labels[pc] {
+			entry := ""
+			if pc == prog.Entry {
+				entry = " (entry)"
+			}
+			fmt.Fprintf(w, "L%v:%v\n", pc, entry)
+		}
+		fmt.Fprintf(w, "  %4v  %v\n", pc, instr.ToString())
+	}
+}
+
+// Decompile the block at pc, substituting env for indices that escape the block.
+func (prog *Program) Decompile(pc int, env *VMEnv) Core {
+	return prog.decompile(pc, env, 0)
+}
+
+func (prog *Program) decompile(pc int, env *VMEnv, depth int) Core {
+	variable := func(index int, name string) Core {
+		if index < depth {
+			return &CVar{Index: index, Name: name}
+		}
+		thunk := env.Lookup(index - depth)
+		if thunk == nil {
+			return &CVar{Index: index, Name: name}
+		}
+		return Shift(prog.Decompile(thunk.PC, thunk.Env), depth, 0)
+	}
+	// Pushed last argument first, so args is in reverse
+	args := []Core{}
+	var head Core
+	for head == nil {
+		instr := prog.Code[pc]
+		switch instr.Op {
+		case OP_Grab:
+			head = &CLam{Name: instr.Name, Body: prog.decompile(pc+1, env, depth+1)}
+		case OP_Push:
+			args = append(args, prog.decompile(instr.Arg, env, depth))
+		case OP_PushVar:
+			args = append(args, variable(instr.Arg, instr.Name))
+		case OP_Access:
+			head = variable(instr.Arg, instr.Name)
+		}
+		pc += 1
+	}
+	for i := len(args) - 1; i >= 0; i-- {
+		head = &CApp{Fun: head, Arg: args[i]}
+	}
+	return head
+}
+
+type VMStats struct {
+	// Instructions executed
+	Steps int
+	// GRABs that bound an argument
+	Betas int
+	// Thunks overwritten with their value
+	Updates  int
+	MaxStack int
+}
+
+// A binding in the heap of the VM, together with the thunk allocated by the PUSH of its
+// argument, so a PUSH and the GRAB binding it allocate a single node between them. Nodes
+// refer to each other by index, 0 standing for none, which keeps the heap free of Go
+// pointers for the garbage collector to scan and write barriers to guard.
+type vmNode struct {
+	// Node whose thunk is bound here: this one, unless the argument was PUSHVAR'd
+	Value int
+	// Rest of the environment, filled in by the GRAB
+	Next int
+	// The thunk itself: a block and the environment it runs in
+	PC   int
+	Env  int
+	Whnf bool
+}
+
+// Stack entries are either the node of an argument, or the node of a thunk to update once a
+// lambda reaches it.
+type vmItem struct {
+	Arg    int
+	Update int
+}
+
+// Instruction-level state, exported so callers can single-step with Step and inspect it.
+// Env is the node of the innermost binding in Heap.
+type VM struct {
+	Program *Program
+	PC      int
+	Env     int
+	Stack   []vmItem
+	Heap    []vmNode
+	Halted  bool
+	Stats   VMStats
+}
+
+func CreateVM(prog *Program) *VM {
+	return &VM{
+		Program: prog,
+		PC:      prog.Entry,
+		Stack:   make([]vmItem, 0, 64),
+		// Node 0 is the empty environment
+		Heap: make([]vmNode, 1, 256),
+	}
+}
+
+// Node of the thunk bound at index in env, 0 if unbound.
+func (vm *VM) lookup(env int, index int) int {
+	for ; env != 0; env = vm.Heap[env].Next {
+		if index == 0 {
+			return vm.Heap[env].Value
+		}
+		index -= 1
+	}
+	return 0
+}
+
+func (vm *VM) envLen(env int) int {
+	n := 0
+	for ; env != 0; env = vm.Heap[env].Next {
+		n += 1
+	}
+	return n
+}
+
+// Execute one instruction. Stepping a halted VM does nothing.
+func (vm *VM) Step() error {
+	_, exec_err := vm.exec(1)
+	return exec_err
+}
+
+// Step until halted. max_steps <= 0 means no limit.
+func (vm *VM) Run(max_steps int) error {
+	budget := 0
+	if max_steps > 0 {
+		budget = max_steps - vm.Stats.Steps
+		if budget <= 0 {
+			return fmt.Errorf("%w: %v VM instructions", ErrStepLimit, vm.Stats.Steps)
+		}
+	}
+	limited, exec_err := vm.exec(budget)
+	if limited {
+		return fmt.Errorf("%w: %v VM instructions", ErrStepLimit, vm.Stats.Steps)
+	}
+	return exec_err
+}
+
+// Execute up to budget instructions (no limit when budget <= 0), reporting whether the
+// budget ran out before the VM halted. The registers are held in locals meanwhile.
+func (vm *VM) exec(budget int) (bool, error) {
+	code := vm.Program.Code
+	pc, env, stack, stats := vm.PC, vm.Env, vm.Stack, vm.Stats
+	defer func() {
+		vm.PC, vm.Env, vm.Stack, vm.Stats = pc, env, stack, stats
+	}()
+	for executed := 0; !vm.Halted; executed++ {
+		if budget > 0 && executed >= budget {
+			return true, nil
+		}
+		if pc < 0 || pc >= len(code) {
+			return false, fmt.Errorf("Program counter %v outside of the program (%v instructions)", pc, len(code))
+		}
+		stats.Steps += 1
+		instr := &code[pc]
+		switch instr.Op {
+		case OP_Grab:
+			for len(stack) > 0 && stack[len(stack)-1].Update != 0 {
+				thunk := &vm.Heap[stack[len(stack)-1].Update]
+				thunk.PC, thunk.Env, thunk.Whnf = pc, env, true
+				stack = stack[:len(stack)-1]
+				stats.Updates += 1
+			}
+			if len(stack) == 0 {
+				vm.Halted = true
+				return false, nil
+			}
+			binding := stack[len(stack)-1].Arg
+			vm.Heap[binding].Next = env
+			env = binding
+			stack = stack[:len(stack)-1]
+			stats.Betas += 1
+			pc += 1
+		case OP_Push:
+			binding := len(vm.Heap)
+			vm.Heap = append(vm.Heap, vmNode{Value: binding, PC: instr.Arg, Env: env})
+			stack = append(stack, vmItem{Arg: binding})
+			pc += 1
+		case OP_PushVar, OP_Access:
+			thunk := vm.lookup(env, instr.Arg)
+			if thunk == 0 {
+				return false, fmt.Errorf("%v %v at %v: unbound index", instr.Op.ToString(), instr.Arg, pc)
+			}
+			if instr.Op == OP_PushVar {
+				stack = append(stack, vmItem{Arg: len(vm.Heap)})
+				vm.Heap = append(vm.Heap, vmNode{Value: thunk})
+				pc += 1
+				break
+			}
+			if !vm.Heap[thunk].Whnf {
+				stack = append(stack, vmItem{Update: thunk})
+			}
+			pc, env = vm.Heap[thunk].PC, vm.Heap[thunk].Env
+		default:
+			return false, fmt.Errorf("Unknown opcode %v at %v", instr.Op, pc)
+		}
+		stats.MaxStack = max(stats.MaxStack, len(stack))
+	}
+	return false, nil
+}
+
+// One line summary of the machine, e.g. for printing after every Step.
+func (vm *VM) State() string {
+	if vm.Halted {
+		return fmt.Sprintf("halted at %v, env %v", vm.PC, vm.envLen(vm.Env))
+	}
+	return fmt.Sprintf("%4v  %-24v stack %v, env %v", vm.PC, vm.Program.Code[vm.PC].ToString(), len(vm.Stack), vm.envLen(vm.Env))
+}
+
+// The environment env of the heap as linked VMEnv and VMThunk values, for Decompile. Nodes
+// are converted once each, so shared thunks stay shared.
+func (vm *VM) ReadEnv(env int) *VMEnv {
+	envs := map[int]*VMEnv{}
+	thunks := map[int]*VMThunk{}
+	var read_env func(env int) *VMEnv
+	read_env = func(env int) *VMEnv {
+		if env == 0 {
+			return nil
+		}
+		if out, done := envs[env]; done {
+			return out
+		}
+		node := vm.Heap[env]
+		thunk, done := thunks[node.Value]
+		if !done {
+			thunk = &VMThunk{PC: vm.Heap[node.Value].PC, Whnf: vm.Heap[node.Value].Whnf}
+			thunks[node.Value] = thunk
+			thunk.Env = read_env(vm.Heap[node.Value].Env)
+		}
+		out := &VMEnv{Value: thunk, Next: read_env(node.Next)}
+		envs[env] = out
+		return out
+	}
+	return read_env(env)
+}
+
+// The current weak head normal form, once halted.
+func (vm *VM) Result() Core {
+	return vm.Program.Decompile(vm.PC, vm.ReadEnv(vm.Env))
+}
+
+// Compile and run a closed term to weak head normal form.
+func RunVM(c Core, max_steps int) (Core, VMStats, error) {
+	prog, compile_err := Compile(c)
+	if compile_err != nil {
+		return nil, VMStats{}, compile_err
+	}
+	vm := CreateVM(prog)
+	if run_err := vm.Run(max_steps); run_err != nil {
+		return nil, vm.Stats, run_err
+	}
+	return vm.Result(), vm.Stats, nil
+}
+
+// Evaluate a parsed closed term on the VM and read the result back.
+func EvalVM(l LExpr, max_steps int) (LExpr, VMStats, error) {
+	c, lower_err := Lower(l)
+	if lower_err != nil {
+		return nil, VMStats{}, lower_err
+	}
+	result, stats, run_err := RunVM(c, max_steps)
+	if run_err != nil {
+		return nil, stats, run_err
+	}
+	return Raise(result), stats, nil
+}
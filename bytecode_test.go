@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestVMAgreesWithNormalOrder(t *testing.T) {
+	for _, c := range preludeCases() {
+		if c.Prims {
+			// Rejected, see TestVMRejectsPrimitives
+			continue
+		}
+		t.Run(c.Name, func(t *testing.T) {
+			whnf, _, run_err := RunVM(c.Term, Test_Max_Steps)
+			if run_err != nil {
+				t.Fatal(run_err)
+			}
+			checkWeakHeadResult(t, c.Term, whnf)
+		})
+	}
+}
+
+// Single stepping makes the same transitions as Run.
+func TestVMStepMatchesRun(t *testing.T) {
+	for _, c := range preludeCases() {
+		if c.Prims {
+			continue
+		}
+		prog, compile_err := Compile(c.Term)
+		if compile_err != nil {
+			t.Fatalf("%v: %v", c.Name, compile_err)
+		}
+		run := CreateVM(prog)
+		if run_err := run.Run(Test_Max_Steps); run_err != nil {
+			t.Fatalf("%v: %v", c.Name, run_err)
+		}
+		stepped := CreateVM(prog)
+		for !stepped.Halted && stepped.Stats.Steps < Test_Max_Steps {
+			if step_err := stepped.Step(); step_err != nil {
+				t.Fatalf("%v: %v", c.Name, step_err)
+			}
+		}
+		if stepped.Stats != run.Stats || stepped.Result().CPrint() != run.Result().CPrint() {
+			t.Errorf("%v: stepping gives %v %+v, running %v %+v", c.Name,
+				stepped.Result().CPrint(), stepped.Stats, run.Result().CPrint(), run.Stats)
+		}
+	}
+}
+
+// Decompiling the entry block gives back the term that was compiled.
+func TestVMDecompileRoundTrip(t *testing.T) {
+	for _, c := range preludeCases() {
+		if c.Prims {
+			continue
+		}
+		prog, compile_err := Compile(c.Term)
+		if compile_err != nil {
+			t.Fatalf("%v: %v", c.Name, compile_err)
+		}
+		if got := prog.Decompile(prog.Entry, nil); got.CPrint() != c.Term.CPrint() {
+			t.Errorf("%v: decompiled to %v, want %v", c.Name, got.CPrint(), c.Term.CPrint())
+		}
+	}
+}
+
+func TestVMLimitsAndOpenTerms(t *testing.T) {
+	omega := LoadPrelude().Cores["OMEGA"]
+	if _, _, run_err := RunVM(omega, 1000); !errors.Is(run_err, ErrStepLimit) {
+		t.Errorf("OMEGA: got %v, want the step limit", run_err)
+	}
+	if _, compile_err := Compile(&CFree{Name: "X1"}); compile_err == nil || !strings.Contains(compile_err.Error(), "X1") {
+		t.Errorf("open term: got %v", compile_err)
+	}
+}
+
+func TestVMRejectsPrimitives(t *testing.T) {
+	for _, c := range preludeCases() {
+		if !c.Prims {
+			continue
+		}
+		if _, _, run_err := RunVM(c.Term, Test_Max_Steps); run_err == nil || !strings.Contains(run_err.Error(), "no delta rules") {
+			t.Errorf("%v: got %v, want primitives rejected", c.Name, run_err)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Entry point for "lambda <command> [args]". Without arguments main runs its demo instead.
+func RunCommand(args []string, w io.Writer) error {
+	switch args[0] {
+	case "disasm":
+		return disassembleCommand(args[1:], w)
+	case "optimal":
+		return RunOptimalComparison(w, Optimal_Comparison_Cases(), 10000000)
+	case "check":
+		return checkCommand(args[1:], w)
+	case "infer":
+		return inferCommand(args[1:], w)
+	case "systemf":
+		return systemFCommand(args[1:], w)
+	case "pi":
+		return piCommand(args[1:], w)
+	case "eval":
+		return evalCommand(args[1:], w)
+	}
+	return fmt.Errorf("Unknown command %q, expected one of: check, disasm, eval, infer, optimal, pi, systemf", args[0])
+}
+
+// lambda check [FILE | TERM]: type every statement of FILE, or TERM, in the simply typed
+// lambda calculus, reading statements from stdin when no argument is given. Prints one line
+// per statement, or every syntax error of a statement that does not parse.
+func checkCommand(args []string, w io.Writer) error {
+	r, source_err := statementSource(args, os.Stdin)
+	if source_err != nil {
+		return source_err
+	}
+	decoder := CreateDecoder(r)
+	decoder.Recover = true
+	return runStatements(decoder, w, "check", func(stmt Statement, label string) error {
+		t, check_err := CheckStatement(stmt, TypeContext{})
+		if check_err != nil {
+			return check_err
+		}
+		fmt.Fprintf(w, "%v : %v\n", label, t.TPrint())
+		return nil
+	})
+}
+
+// lambda infer [+records] [NAME | FILE | TERM]: infer the principal type of a prelude
+// definition, of every statement of FILE, or of TERM, reading statements from stdin when no
+// argument is given. +records adds records and variants with row types, see records.go.
+func inferCommand(args []string, w io.Writer) error {
+	records, args := recordsOption(args)
+	var r io.Reader = os.Stdin
+	if len(args) > 0 {
+		source, source_err := inferSource(args[0])
+		if source_err != nil {
+			return source_err
+		}
+		r = source
+	}
+	decoder := CreateDecoder(r)
+	decoder.AcceptRecords = records
+	return runStatements(decoder, w, "infer", func(stmt Statement, label string) error {
+		scheme, infer_err := InferStatement(stmt, nil)
+		if type_err, is_type_err := infer_err.(*TypeError); is_type_err {
+			return errors.New(type_err.Explain(stmt.Source[stmt.BodyStart:]))
+		} else if infer_err != nil {
+			return infer_err
+		}
+		fmt.Fprintf(w, "%v : %v\n", label, scheme.TPrint())
+		return nil
+	})
+}
+
+// Whether args open with the option "+records", and the arguments after it.
+func recordsOption(args []string) (bool, []string) {
+	if len(args) > 0 && args[0] == "+records" {
+		return true, args[1:]
+	}
+	return false, args
+}
+
+// The FILE of an option "--cache FILE" opening args, and the arguments after it.
+func cacheOption(args []string) (string, []string, error) {
+	if len(args) == 0 || args[0] != "--cache" {
+		return "", args, nil
+	}
+	if len(args) < 2 {
+		return "", nil, fmt.Errorf("--cache needs the FILE to keep normal forms in")
+	}
+	return args[1], args[2:], nil
+}
+
+// Statements named by arg: the prelude line defining it, the file at that path, or arg itself.
+func inferSource(arg string) (io.Reader, error) {
+	name := strings.ToUpper(arg)
+	if _, defined := LoadPrelude().Exprs[name]; defined {
+		for _, line := range strings.Split(Prelude_Source, "\n") {
+			if eq := strings.Index(line, "="); eq >= 0 && strings.TrimSpace(line[:eq]) == name {
+				return strings.NewReader(line), nil
+			}
+		}
+	}
+	return statementSource([]string{arg}, nil)
+}
+
+// Statements named by the first of args: the file at that path, or the argument itself,
+// default_source when args is empty.
+func statementSource(args []string, default_source io.Reader) (io.Reader, error) {
+	if len(args) == 0 {
+		return default_source, nil
+	}
+	if file_bytes, read_err := os.ReadFile(args[0]); read_err == nil {
+		return strings.NewReader(string(file_bytes)), nil
+	} else if !os.IsNotExist(read_err) {
+		return nil, read_err
+	}
+	return strings.NewReader(args[0]), nil
+}
+
+// Returned by the function given to runStatements once it has printed its own failure
+var errStatementReported = errors.New("Statement failure already reported")
+
+// Decode every statement and hand it to run along with the label it is printed under. A
+// statement that fails to decode, or whose run returns an error, is reported on its own line
+// and counted; the error returned sums those up as statements that failed to verb.
+func runStatements(decoder *Decoder, w io.Writer, verb string, run func(stmt Statement, label string) error) error {
+	failed := 0
+	for {
+		stmt, decode_err := decoder.Decode()
+		if decode_err == io.EOF {
+			break
+		}
+		label := stmt.Name
+		if len(label) == 0 && stmt.Expr != nil {
+			label = stmt.Expr.LPrint()
+		}
+		if decode_err != nil {
+			fmt.Fprintf(w, "%v\n", decode_err)
+			failed += 1
+			continue
+		}
+		if run_err := run(stmt, label); run_err != nil {
+			if run_err != errStatementReported {
+				fmt.Fprintf(w, "%v: %v\n", label, run_err)
+			}
+			failed += 1
+		}
+	}
+	if failed > 0 {
+		if failed == 1 {
+			return fmt.Errorf("1 statement failed to %v", verb)
+		}
+		return fmt.Errorf("%v statements failed to %v", failed, verb)
+	}
+	return nil
+}
+
+// lambda systemf [FILE | TERM]: type every statement in System F and print the normal form
+// of its erasure, for the built-in typed Church encodings when no argument is given.
+func systemFCommand(args []string, w io.Writer) error {
+	r, source_err := statementSource(args, strings.NewReader(SystemF_Examples_Source))
+	if source_err != nil {
+		return source_err
+	}
+	return runStatements(CreateDecoder(r), w, "check", func(stmt Statement, label string) error {
+		t, check_err := CheckSystemFStatement(stmt, TypeContext{})
+		if check_err != nil {
+			return check_err
+		}
+		_, nf, _, eval_err := EvalSystemF(stmt.Expr, stmt.Spans, 100000)
+		if eval_err != nil {
+			fmt.Fprintf(w, "%v : %v\n  %v\n", label, t.TPrint(), eval_err)
+			return errStatementReported
+		}
+		fmt.Fprintf(w, "%v : %v\n  ⇒ %v\n", label, t.TPrint(), nf.LPrint())
+		return nil
+	})
+}
+
+// lambda pi [FILE | TERM]: type every statement with dependent types, for the built-in
+// examples when no argument is given. Definitions named like variables (NAT1) can be used by
+// later statements.
+func piCommand(args []string, w io.Writer) error {
+	r, source_err := statementSource(args, strings.NewReader(Pi_Examples_Source))
+	if source_err != nil {
+		return source_err
+	}
+	globals := map[string]DependentGlobal{}
+	return runStatements(CreateDecoder(r), w, "check", func(stmt Statement, label string) error {
+		t, check_err := CheckDependentStatement(stmt, globals)
+		if check_err != nil {
+			return check_err
+		}
+		fmt.Fprintf(w, "%v : %v\n", label, t.LPrint())
+		return nil
+	})
+}
+
+// Strategies "lambda eval" can be asked for, by the first argument
+var Eval_Strategies = map[string]Strategy{
+	"normal":      R_NormalOrder,
+	"applicative": R_ApplicativeOrder,
+	"cbn":         R_CallByName,
+	"cbv":         R_CallByValue,
+	"head":        R_HeadReduction,
+}
+
+// Abstract machines "lambda eval" can run instead, which take data types apart directly. Only
+// Control_Machine runs @SHIFT and @RESET.
+const Control_Machine = "cek"
+
+var Eval_Machines = map[string]func(Core, int) (MachineResult, error){
+	"krivine": KrivineEval,
+	"cek":     CEKEval,
+}
+
+// lambda eval [+records] [--cache CACHE] [normal|applicative|cbn|cbv|head|krivine|cek] [FILE | TERM]: reduce
+// every statement under the strategy (normal order by default), primitive operators included,
+// or run it on a machine, for the built-in examples when no FILE or TERM is given. Definitions
+// named like variables (TWICE1) can be used by later statements, and so can the constructors
+// of data declarations. Under cbv and applicative a recursive let that needs its own value,
+// "lambda eval cbv 'L@X1=(+(X1)(1)).(X1)'", fails as ill-founded. +records adds records and
+// variants, and their examples to the built-in ones. Delimited continuations only run on the
+// cek machine: by default statements using them go there, and with an explicit strategy they
+// are rejected. --cache keeps normal order results in the NormalFormCache log CACHE, so later
+// runs look them up instead of reducing them again.
+func evalCommand(args []string, w io.Writer) error {
+	records, args := recordsOption(args)
+	cache_path, args, option_err := cacheOption(args)
+	if option_err != nil {
+		return option_err
+	}
+	strategy := R_NormalOrder
+	machine := ""
+	// Neither a strategy nor a machine asked for, so control statements go to Control_Machine
+	chosen := false
+	if len(args) > 0 {
+		if named, found := Eval_Strategies[args[0]]; found {
+			strategy = named
+			args = args[1:]
+			chosen = true
+		} else if _, found := Eval_Machines[args[0]]; found {
+			machine = args[0]
+			args = args[1:]
+			chosen = true
+		}
+	}
+	examples := Prim_Examples_Source + Data_Examples_Source
+	if records {
+		examples += Records_Examples_Source
+	}
+	if !chosen || machine == Control_Machine {
+		examples += Control_Examples_Source
+	}
+	var cache *NormalFormCache
+	if len(cache_path) > 0 {
+		if strategy != R_NormalOrder || len(machine) > 0 {
+			return fmt.Errorf("--cache only holds normal forms of normal order reduction")
+		}
+		opened, open_err := OpenNormalFormCache(cache_path)
+		if open_err != nil {
+			return open_err
+		}
+		defer opened.Close()
+		cache = opened
+	}
+	r, source_err := statementSource(args, strings.NewReader(examples))
+	if source_err != nil {
+		return source_err
+	}
+	decoder := CreateDecoder(r)
+	decoder.AcceptData = true
+	decoder.AcceptRecords = records
+	data := CreateDataEnv()
+	globals := map[string]Core{}
+	return runStatements(decoder, w, "evaluate", func(stmt Statement, label string) error {
+		if stmt.Data != nil {
+			if declare_err := data.Declare(*stmt.Data); declare_err != nil {
+				fmt.Fprintf(w, "%v: %v\n", stmt.Pos.ToString(), declare_err)
+				return errStatementReported
+			}
+			fmt.Fprintf(w, "%v declared\n", stmt.Data.Print())
+			return nil
+		}
+		c, lower_err := Lower(stmt.Expr)
+		if lower_err != nil {
+			return lower_err
+		}
+		c = SubstituteFree(c, globals)
+		if IsVariableName(stmt.Name) {
+			// Kept unreduced, a recursive definition may have no normal form on its own
+			globals[stmt.Name] = c
+			fmt.Fprintf(w, "%v defined\n", label)
+			return nil
+		}
+		statement_machine := machine
+		if !chosen && UsesControl(c) {
+			statement_machine = Control_Machine
+		}
+		nf, cost, eval_err := evalStatement(c, data, strategy, statement_machine, cache)
+		if eval_err != nil {
+			return eval_err
+		}
+		if records {
+			nf = DecodeRecords(nf)
+		}
+		fmt.Fprintf(w, "%v ⇒ %v  (%v)\n", label, Raise(nf).LPrint(), cost)
+		return nil
+	})
+}
+
+// Result of c on the machine named machine, or else of reducing its Scott encoding under
+// strategy, and what it cost. Records and variants are encoded either way. A cache, only
+// given for normal order, is consulted before reducing and filled after.
+func evalStatement(c Core, data *DataEnv, strategy Strategy, machine string, cache *NormalFormCache) (Core, string, error) {
+	if UsesControl(c) && machine != Control_Machine {
+		return nil, "", fmt.Errorf("@SHIFT and @RESET only run on the %v machine, as in lambda eval %v", Control_Machine, Control_Machine)
+	}
+	c, encode_err := EncodeRecords(c)
+	if encode_err != nil {
+		return nil, "", encode_err
+	}
+	if run, found := Eval_Machines[machine]; found {
+		if check_err := data.Check(c); check_err != nil {
+			return nil, "", check_err
+		}
+		result, eval_err := run(c, 1000000)
+		if eval_err != nil {
+			return nil, "", eval_err
+		}
+		return result.Quote(), fmt.Sprintf("%v transitions", result.Stats.Steps), nil
+	}
+	encoded, encode_err := data.ScottEncode(c)
+	if encode_err != nil {
+		return nil, "", encode_err
+	}
+	if cache != nil {
+		if nf, steps, cached := cache.Lookup(encoded); cached {
+			return data.ScottDecode(nf), fmt.Sprintf("%v steps, cached", steps), nil
+		}
+	}
+	nf, steps, reduce_err := Reduce(encoded, strategy, 1000000)
+	if reduce_err != nil {
+		return nil, "", reduce_err
+	}
+	if cache != nil {
+		if store_err := cache.Store(encoded, nf, steps); store_err != nil {
+			return nil, "", store_err
+		}
+	}
+	return data.ScottDecode(nf), fmt.Sprintf("%v steps", steps), nil
+}
+
+// lambda disasm NAME: compile a prelude definition and print its bytecode.
+func disassembleCommand(args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Usage: disasm NAME, NAME being a prelude definition")
+	}
+	prelude := LoadPrelude()
+	c, defined := prelude.Cores[strings.ToUpper(args[0])]
+	if !defined {
+		return fmt.Errorf("%q is not defined, expected one of: %v", args[0], strings.Join(prelude.Names, ", "))
+	}
+	prog, compile_err := Compile(c)
+	if compile_err != nil {
+		return compile_err
+	}
+	prog.Disassemble(w)
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStatementSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "statements.lambda")
+	if write_err := os.WriteFile(path, []byte("LX1.(X1)"), 0644); write_err != nil {
+		t.Fatal(write_err)
+	}
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{path}, "LX1.(X1)"},
+		{[]string{"LY1.(Y1)"}, "LY1.(Y1)"},
+		{nil, "default"},
+	}
+	for _, c := range cases {
+		r, source_err := statementSource(c.args, strings.NewReader("default"))
+		if source_err != nil {
+			t.Fatal(source_err)
+		}
+		if got, _ := io.ReadAll(r); string(got) != c.want {
+			t.Errorf("%v: read %q, want %q", c.args, got, c.want)
+		}
+	}
+}
+
+func TestRunStatementsCountsFailures(t *testing.T) {
+	var out strings.Builder
+	decoder := CreateDecoder(strings.NewReader("X1\nY1\nX1)\nZ1\n"))
+	run_err := runStatements(decoder, &out, "test", func(stmt Statement, label string) error {
+		switch label {
+		case "Y1":
+			return errors.New("rejected")
+		case "Z1":
+			out.WriteString("Z1 reported itself\n")
+			return errStatementReported
+		}
+		out.WriteString(label + " ok\n")
+		return nil
+	})
+	if run_err == nil || run_err.Error() != "3 statements failed to test" {
+		t.Errorf("got %v, want 3 failures:\n%v", run_err, out.String())
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 || lines[0] != "X1 ok" || lines[1] != "Y1: rejected" || lines[3] != "Z1 reported itself" {
+		t.Errorf("printed %q", lines)
+	}
+	single := runStatements(CreateDecoder(strings.NewReader("X1)\n")), &out, "test", func(Statement, string) error {
+		return nil
+	})
+	if single == nil || single.Error() != "1 statement failed to test" {
+		t.Errorf("got %v, want a single failure", single)
+	}
+}
+
+func TestCheckReportsEverySyntaxError(t *testing.T) {
+	var out strings.Builder
+	check_err := RunCommand([]string{"check", "X1?Y1!LZ1.(Z1); LX1:INT.(X1)"}, &out)
+	if check_err == nil || check_err.Error() != "1 statement failed to check" {
+		t.Errorf("got %v, want one failure", check_err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "line 1, column 3:") ||
+		!strings.HasPrefix(lines[1], "line 1, column 6:") || lines[2] != "λX1:INT.X1 : INT→INT" {
+		t.Errorf("printed %q", lines)
+	}
+}
+
+func TestEvalRunsControlByDefault(t *testing.T) {
+	var out strings.Builder
+	if eval_err := RunCommand([]string{"eval"}, &out); eval_err != nil {
+		t.Fatalf("%v\n%v", eval_err, out.String())
+	}
+	if want := "(@RESET(+1(@SHIFTλK1.(K1(K1(10)))))) ⇒ 12  ("; !strings.Contains(out.String(), want) {
+		t.Errorf("built-in examples did not include %q:\n%v", want, out.String())
+	}
+	out.Reset()
+	if eval_err := RunCommand([]string{"eval", "+(1)(@RESET(+(10)(@SHIFT(LK1.(K1(K1(100)))))))"}, &out); eval_err != nil {
+		t.Fatal(eval_err)
+	}
+	if want := "⇒ 121  ("; !strings.Contains(out.String(), want) || !strings.Contains(out.String(), "transitions") {
+		t.Errorf("printed %q, want %q from the cek machine", out.String(), want)
+	}
+	out.Reset()
+	if eval_err := RunCommand([]string{"eval", "normal", "@RESET(1)"}, &out); eval_err == nil {
+		t.Errorf("normal order ran a control operator: %v", out.String())
+	}
+}
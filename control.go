@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+	Delimited continuations - @SHIFT and @RESET
+
+@RESET(E) evaluates E and delimits the continuations captured within it. @SHIFT(F) captures
+the rest of the computation up to the nearest enclosing @RESET as a function K, removes it,
+and applies F to K in its place:
+
+	@RESET(+(1)(@SHIFT(λK1.(K1(K1(10))))))  ⇒ 12    the rest, +(1)(□), run twice
+	@RESET(+(1)(@SHIFT(λK1.(5))))           ⇒ 5     the rest discarded, an early exit
+	@RESET(+(1)(@SHIFT(λK1.(K1))))          ⇒ λV1.(@RESET(+(1)(V1)))
+
+Their semantics is given by the CEK machine (machine.go), where "the rest of the computation"
+is literally the stack of frames: @RESET pushes a K_Reset frame, and @SHIFT pops every frame
+above the nearest one. Those frames are quoted back into a term with a hole, the evaluation
+context E, and K is λV1.(@RESET(E[V1])), so applying K pushes the same frames again under a
+fresh delimiter, and a continuation read back from the machine shows what it would do. This is
+shift/reset in the sense of Danvy and Filinski: the body of F runs with the @RESET still
+around it, and K reinstates its own.
+
+Call-by-value is what makes "the rest" well defined, the arguments evaluated left to right
+before the function is applied, so neither the reducers nor the Krivine machine run control
+operators: "lambda eval" sends the statements using them to the CEK machine unless another
+strategy was asked for, evalStatement rejects them outside of it, and a @SHIFT with no @RESET
+around it is an error rather than capturing up to the top level. Inference cannot type them,
+as their types would depend on the answer type of the enclosing @RESET.
+*/
+var ErrNoReset = errors.New("@SHIFT outside of any @RESET")
+
+var ErrControl = errors.New("@SHIFT and @RESET only run on the CEK machine")
+
+// ErrControl naming evaluator if c uses a control operator, for the evaluators that would
+// otherwise leave them stuck.
+func rejectControl(c Core, evaluator string) error {
+	if UsesControl(c) {
+		return fmt.Errorf("%w, not %v", ErrControl, evaluator)
+	}
+	return nil
+}
+
+func (op PrimOp) IsControl() bool {
+	return op == PRIM_Reset || op == PRIM_Shift
+}
+
+// Whether c applies or mentions a control operator anywhere.
+func UsesControl(c Core) bool {
+	switch node := c.(type) {
+	case *CFree:
+		prim, _ := DecodePrim(node)
+		op, is_op := prim.(*LPrim)
+		return is_op && op.Op.IsControl()
+	case *CLam:
+		return UsesControl(node.Body)
+	case *CApp:
+		return UsesControl(node.Fun) || UsesControl(node.Arg)
+	}
+	return false
+}
+
+// The continuation up to the nearest K_Reset of kont as a closed function λV1.(@RESET(E[V1])),
+// and the frames left once it is removed, the K_Reset included.
+func captureContinuation(kont []Frame) (*Closure, []Frame, error) {
+	reset := len(kont) - 1
+	for reset >= 0 && kont[reset].Kind != K_Reset {
+		reset -= 1
+	}
+	if reset < 0 {
+		return nil, kont, fmt.Errorf("%w, there is no continuation to capture", ErrNoReset)
+	}
+	var context Core = &CVar{Index: 0, Name: "V1"}
+	for i := len(kont) - 1; i > reset; i-- {
+		context = plugFrame(kont[i], context)
+	}
+	k := &CLam{Name: "V1", Body: &CApp{Fun: PrimCore(&LPrim{Op: PRIM_Reset}), Arg: context}}
+	return &Closure{Term: k, Env: nil}, kont[:reset+1], nil
+}
+
+// The term frame would compute from hole, all of its closures quoted under the binder of
+// the continuation.
+func plugFrame(frame Frame, hole Core) Core {
+	quote := func(cl *Closure) Core {
+		return Shift(QuoteClosure(cl), 1, 0)
+	}
+	switch frame.Kind {
+	case K_Arg:
+		return &CApp{Fun: hole, Arg: quote(&Closure{Term: frame.Term, Env: frame.Env})}
+	case K_Apply:
+		return &CApp{Fun: quote(frame.Value), Arg: hole}
+	case K_ApplyTo:
+		return &CApp{Fun: hole, Arg: quote(frame.Value)}
+	case K_If, K_Case:
+		// The condition or scrutinee is the hole, the rest of the application waits for it
+		head, args := CoreSpine(frame.Term)
+		plugged := []Core{hole}
+		for _, arg := range args[1:] {
+			plugged = append(plugged, quote(&Closure{Term: arg, Env: frame.Env}))
+		}
+		return CoreApply(head, plugged...)
+	}
+	return hole
+}
+
+// Programs shown by "lambda eval" and "lambda eval cek" after the data examples.
+const Control_Examples_Source = `
+@RESET(+(1)(@SHIFT(λK1.(K1(K1(10))))))
+@RESET(+(1)(@SHIFT(λK1.(5))))
+@RESET(+(1)(@SHIFT(λK1.(K1))))
++(1)(@RESET(+(10)(@SHIFT(λK1.(K1(K1(100)))))))
+@RESET(++("a")(@SHIFT(λK1.(++(K1("b"))(K1("c"))))))
+SUMPOS1 = λX1.(@RESET(λ@S1=(λY1.(@CASE(Y1)(@NIL.(0))(@CONS.H1.T1.(@IF(<(H1)(0))(@SHIFT(λK1.(-(0)(1))))(+(H1)(S1(T1))))))).(S1(X1))))
+SUMPOS1(XS1)
+SUMPOS1(@CONS(1)(@CONS(-(0)(2))(XS1)))
+ABORT1 = λX1.(@SHIFT(λK1.(X1)))
+@RESET(+(1)(+(2)(ABORT1(5))))
+CHOOSE1 = λX1.(λY1.(@SHIFT(λK1.(++(K1(X1))(K1(Y1))))))
+@RESET(++(CHOOSE1("a")("b"))(CHOOSE1("c")("d")))
+`
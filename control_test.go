@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// The control examples, every one under a @RESET, run on the CEK machine lambda eval picks
+// for them by itself.
+func TestControlExamples(t *testing.T) {
+	var out strings.Builder
+	if eval_err := RunCommand([]string{"eval"}, &out); eval_err != nil {
+		t.Fatalf("%v:\n%v", eval_err, out.String())
+	}
+	results := map[string]string{}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if term, result, found := strings.Cut(line, " ⇒ "); found {
+			results[term] = strings.Split(result, "  (")[0]
+		}
+	}
+	want := map[string]string{
+		"(@RESET(+1(@SHIFTλK1.(K1(K1(10))))))":               "12",
+		"(@RESET(+1(@SHIFTλK1.5)))":                          "5",
+		"(@RESET(+1(@SHIFTλK1.K1)))":                         "λV1.(@RESET(+1V1))",
+		"(SUMPOS1(@CONS1(@CONS(-0(2))XS1)))":                 "~1",
+		"(@RESET(+1(+2(ABORT1(5)))))":                        "5",
+		"(@RESET(++(CHOOSE1\"a\"\"b\")(CHOOSE1\"c\"\"d\")))": "\"acadbcbd\"",
+	}
+	for term, result := range want {
+		if got, found := results[term]; !found || got != result {
+			t.Errorf("%v: got %q, want %q:\n%v", term, got, result, out.String())
+		}
+	}
+}
+
+func TestShiftNeedsReset(t *testing.T) {
+	cases := []string{
+		"@SHIFT(λK1.(K1))",
+		"(λX1.(@SHIFT(λK1.(X1))))(5)",
+	}
+	for _, source := range cases {
+		if _, eval_err := CEKEval(parseCore(t, source), Test_Max_Steps); eval_err == nil {
+			t.Errorf("%v: ran without a @RESET", source)
+		}
+	}
+}
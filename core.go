@@ -0,0 +1,372 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+	Core - nameless form of an LExpr shared by the evaluators
+
+Bound variables are de Bruijn indices (0 is the nearest enclosing binder) so alpha-equivalent
+terms are structurally equal and substitution never needs renaming. Binder names are kept
+only as hints for reading a Core back into an LExpr. Applications are binary, unlike the
+flat Exprs slices of LExpression.
+
+Core requires the following:
+  - CPrint() - canonical text of the nameless term, e.g. λλ(1 0) for λX1.λY1.(X1Y1). Equal
+    strings mean alpha-equivalent terms
+*/
+type Core interface {
+	CPrint() string
+}
+
+type CVar struct {
+	Index int
+	Name  string
+}
+
+type CFree struct {
+	Name string
+}
+
+type CLam struct {
+	Name string
+	Body Core
+}
+
+type CApp struct {
+	Fun Core
+	Arg Core
+}
+
+func (c *CVar) CPrint() string {
+	return strconv.Itoa(c.Index)
+}
+
+func (c *CFree) CPrint() string {
+	return c.Name
+}
+
+func (c *CLam) CPrint() string {
+	return "λ" + c.Body.CPrint()
+}
+
+func (c *CApp) CPrint() string {
+	return "(" + c.Fun.CPrint() + " " + c.Arg.CPrint() + ")"
+}
+
+// Left-nested application of head to args, i.e. ((head a1) a2) ...
+func CoreApply(head Core, args ...Core) Core {
+	for _, arg := range args {
+		head = &CApp{Fun: head, Arg: arg}
+	}
+	return head
+}
+
+// Split ((head a1) a2) ... back into head and args.
+func CoreSpine(c Core) (Core, []Core) {
+	args := []Core{}
+	for {
+		app, is_app := c.(*CApp)
+		if !is_app {
+			break
+		}
+		args = append(args, app.Arg)
+		c = app.Fun
+	}
+	for i, j := 0, len(args)-1; i < j; i, j = i+1, j-1 {
+		args[i], args[j] = args[j], args[i]
+	}
+	return c, args
+}
+
+// Lower converts a parsed LExpr into Core. Variables not bound by an enclosing lambda become
+// CFree.
+func Lower(l LExpr) (Core, error) {
+	return lower(l, nil)
+}
+
+func lower(l LExpr, scope []string) (Core, error) {
+	switch node := l.(type) {
+	case *LVar:
+		for i := len(scope) - 1; i >= 0; i-- {
+			if scope[i] == node.Symbol {
+				return &CVar{Index: len(scope) - 1 - i, Name: node.Symbol}, nil
+			}
+		}
+		return &CFree{Name: node.Symbol}, nil
+	case *LExpression:
+		if len(node.Binding.Symbol) == 0 {
+			return lowerSeq(node.Exprs, scope)
+		}
+		inner_scope := append(scope[:len(scope):len(scope)], node.Binding.Symbol)
+		body, body_err := lowerSeq(node.Exprs, inner_scope)
+		if body_err != nil {
+			return nil, body_err
+		}
+		return &CLam{Name: node.Binding.Symbol, Body: body}, nil
+	case *LLet:
+		inner_scope := append(scope[:len(scope):len(scope)], node.Binding.Symbol)
+		var bound Core
+		var bound_err error
+		if node.Recursive {
+			bound, bound_err = lower(node.Bound, inner_scope)
+			bound = &CApp{Fun: PrimCore(&LPrim{Op: PRIM_Fix}), Arg: &CLam{Name: node.Binding.Symbol, Body: bound}}
+		} else {
+			bound, bound_err = lower(node.Bound, scope)
+		}
+		if bound_err != nil {
+			return nil, bound_err
+		}
+		body, body_err := lower(node.Body, inner_scope)
+		if body_err != nil {
+			return nil, body_err
+		}
+		return &CApp{Fun: &CLam{Name: node.Binding.Symbol, Body: body}, Arg: bound}, nil
+	case *LPi:
+		if node.Binding.Domain == nil {
+			return nil, fmt.Errorf("Cannot lower %v, the binding of a Π needs a type", node.LPrint())
+		}
+		domain, domain_err := lower(node.Binding.Domain, scope)
+		if domain_err != nil {
+			return nil, domain_err
+		}
+		body, body_err := lower(node.Body, append(scope[:len(scope):len(scope)], node.Binding.Symbol))
+		if body_err != nil {
+			return nil, body_err
+		}
+		return CorePi(node.Binding.Symbol, domain, body), nil
+	case *LUniverse:
+		// Π and universes are free constants, see dependent.go
+		return &CFree{Name: UniverseName(node.Level)}, nil
+	case *LTyAbs:
+		// Types are erased
+		return lower(node.Body, scope)
+	case *LInt, *LBool, *LString, *LPrim:
+		// Free constants as well, see prims.go
+		return PrimCore(node), nil
+	case *LCon:
+		// And so are constructors, see data.go
+		return &CFree{Name: node.LPrint()}, nil
+	case *LCase:
+		return lowerCase([]LExpr{node}, scope)
+	case *LBranch:
+		return nil, fmt.Errorf("Cannot lower branch %v outside of a @CASE", node.LPrint())
+	case *LRecord:
+		// Records, projections and tags too, see records.go
+		return lowerRecord(node, scope)
+	case *LProj:
+		record, record_err := lower(node.Record, scope)
+		if record_err != nil {
+			return nil, record_err
+		}
+		return &CApp{Fun: &CFree{Name: Select_Prefix + node.Label}, Arg: record}, nil
+	case *LTag:
+		return &CFree{Name: node.LPrint()}, nil
+	case *LError:
+		return nil, fmt.Errorf("Cannot lower a term containing a parse error: %w", node.Err)
+	}
+	return nil, fmt.Errorf("Cannot lower LExpr %v of unknown type %T", l.LPrint(), l)
+}
+
+// The Exprs of an LExpression are applied left to right.
+func lowerSeq(lexprs []LExpr, scope []string) (Core, error) {
+	if len(lexprs) == 0 {
+		return nil, fmt.Errorf("Cannot lower an empty expression ()")
+	} else if _, is_case := lexprs[0].(*LCase); is_case {
+		return lowerCase(lexprs, scope)
+	}
+	var out Core = nil
+	for _, lexpr := range lexprs {
+		if _, is_type_arg := lexpr.(*LTypeArg); is_type_arg {
+			continue
+		}
+		next, next_err := lower(lexpr, scope)
+		if next_err != nil {
+			return nil, next_err
+		}
+		if out == nil {
+			out = next
+		} else {
+			out = &CApp{Fun: out, Arg: next}
+		}
+	}
+	if out == nil {
+		return nil, fmt.Errorf("Cannot lower an expression made only of type arguments")
+	}
+	return out, nil
+}
+
+// Raise reads a Core back into an LExpr shaped like the parser's output. Binder names are
+// taken from the hints unless that would capture a free variable or shadow an enclosing
+// binder, in which case the numeric suffix is bumped (X1 -> X2).
+func Raise(c Core) LExpr {
+	used := map[string]bool{}
+	CoreFreeNames(c, used)
+	return raise(c, nil, used)
+}
+
+func raise(c Core, scope []string, used map[string]bool) LExpr {
+	switch node := c.(type) {
+	case *CLam:
+		name := FreshName(node.Name, used)
+		used[name] = true
+		body := raiseSeq(node.Body, append(scope[:len(scope):len(scope)], name), used)
+		delete(used, name)
+		return body.LAbstract(LVar{Symbol: name})
+	case *CApp:
+		concat := raiseSeq(c, scope, used)
+		return &concat
+	}
+	return raiseAtom(c, scope)
+}
+
+// Application spine as one flat LExpression, parenthesising any argument that is itself an
+// application.
+func raiseSeq(c Core, scope []string, used map[string]bool) LExpression {
+	head, args := CoreSpine(c)
+	if free, is_free := head.(*CFree); is_free {
+		if arms, is_case := ParseCaseName(free.Name); is_case && len(args) > len(arms) {
+			return raiseCase(arms, args, scope, used)
+		} else if lexprs, is_record := raiseRecordSpine(free, args, scope, used); is_record {
+			return ConcatenateLExprs(lexprs)
+		}
+	}
+	lexprs := []LExpr{raise(head, scope, used)}
+	for _, arg := range args {
+		lexprs = append(lexprs, raise(arg, scope, used))
+	}
+	return ConcatenateLExprs(lexprs)
+}
+
+func raiseAtom(c Core, scope []string) LExpr {
+	switch node := c.(type) {
+	case *CVar:
+		if node.Index < len(scope) {
+			return &LVar{Symbol: scope[len(scope)-1-node.Index]}
+		}
+		// Dangling index, only reachable for ill-formed Core
+		return &LVar{Symbol: fmt.Sprintf("DANGLING%v", node.Index)}
+	case *CFree:
+		if prim, is_prim := DecodePrim(node); is_prim {
+			return prim
+		} else if name := ConstructorName(node); len(name) > 0 {
+			return &LCon{Name: name}
+		} else if record := raiseRecordAtom(node); record != nil {
+			return record
+		}
+		return &LVar{Symbol: node.Name}
+	}
+	return &LVar{Symbol: c.CPrint()}
+}
+
+// Replace every CFree of c named in defs by its (closed) definition.
+func SubstituteFree(c Core, defs map[string]Core) Core {
+	switch node := c.(type) {
+	case *CFree:
+		if def, found := defs[node.Name]; found {
+			return def
+		}
+	case *CLam:
+		return &CLam{Name: node.Name, Body: SubstituteFree(node.Body, defs)}
+	case *CApp:
+		return &CApp{Fun: SubstituteFree(node.Fun, defs), Arg: SubstituteFree(node.Arg, defs)}
+	}
+	return c
+}
+
+// Collect the names of every CFree in c into names.
+func CoreFreeNames(c Core, names map[string]bool) {
+	switch node := c.(type) {
+	case *CFree:
+		names[node.Name] = true
+	case *CLam:
+		CoreFreeNames(node.Body, names)
+	case *CApp:
+		CoreFreeNames(node.Fun, names)
+		CoreFreeNames(node.Arg, names)
+	}
+}
+
+// Variable name based on hint that is not in used and fits the parser's variable grammar
+// (capital letters other than L followed by digits).
+func FreshName(hint string, used map[string]bool) string {
+	base := strings.TrimRight(hint, "0123456789")
+	has_suffix := len(base) < len(hint)
+	if len(base) == 0 || !IsCapLetter(base) {
+		base = "X"
+		has_suffix = false
+	}
+	if has_suffix && !used[hint] {
+		return hint
+	}
+	for n := 1; ; n++ {
+		candidate := base + strconv.Itoa(n)
+		if !used[candidate] {
+			return candidate
+		}
+	}
+}
+
+// Parse the canonical text produced by CPrint back into Core. Binder names are not part of
+// that text, so the result has none.
+func ParseCPrint(s string) (Core, error) {
+	c, rest, err := parseCPrint(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("Unexpected %q after the end of %q", rest, s)
+	}
+	return c, nil
+}
+
+func parseCPrint(s string) (Core, string, error) {
+	switch {
+	case strings.HasPrefix(s, "λ"):
+		body, rest, err := parseCPrint(s[len("λ"):])
+		if err != nil {
+			return nil, "", err
+		}
+		return &CLam{Body: body}, rest, nil
+	case strings.HasPrefix(s, "("):
+		fun, rest, fun_err := parseCPrint(s[1:])
+		if fun_err != nil {
+			return nil, "", fun_err
+		}
+		if !strings.HasPrefix(rest, " ") {
+			return nil, "", fmt.Errorf("Expected ' ' between function and argument at %q", rest)
+		}
+		arg, rest, arg_err := parseCPrint(rest[1:])
+		if arg_err != nil {
+			return nil, "", arg_err
+		}
+		if !strings.HasPrefix(rest, ")") {
+			return nil, "", fmt.Errorf("Expected ')' at %q", rest)
+		}
+		return &CApp{Fun: fun, Arg: arg}, rest[1:], nil
+	}
+	if strings.HasPrefix(s, Prim_Prefix+"\"") {
+		// String constants may contain any of the separators below
+		quoted, quote_err := strconv.QuotedPrefix(s[len(Prim_Prefix):])
+		if quote_err != nil {
+			return nil, "", fmt.Errorf("Unterminated string constant at %q", s)
+		}
+		end := len(Prim_Prefix) + len(quoted)
+		return &CFree{Name: s[:end]}, s[end:], nil
+	}
+	end := strings.IndexAny(s, " ()λ")
+	if end < 0 {
+		end = len(s)
+	}
+	atom := s[:end]
+	if len(atom) == 0 {
+		return nil, "", fmt.Errorf("Expected a term at %q", s)
+	}
+	if index, atoi_err := strconv.Atoi(atom); atoi_err == nil {
+		return &CVar{Index: index}, s[end:], nil
+	}
+	return &CFree{Name: atom}, s[end:], nil
+}
@@ -0,0 +1,573 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+	Algebraic data types - data declarations, constructors and case
+
+A declaration names a type and its constructors, each followed by the variables naming its
+fields (only there for readability, fields are positional):
+
+	data LIST = @NIL | @CONS(H1)(T1)
+
+Constructors are written like the primitives, @NIL or @CONS(1)(@NIL), and taken apart by
+@CASE applied to the scrutinee and then one branch per constructor, in any order. A branch
+binds the fields of its constructor before its body:
+
+	@CASE(X1)(@NIL.(0))(@CONS.H1.T1.(+(H1)(SUM1(T1))))
+
+Lowering needs no declarations: a constructor becomes the free constant "@NIL", a case the
+constant "@CASE.NIL/0.CONS/2" (its branches' constructors and how many fields each binds)
+applied to the scrutinee and the branches as functions of their fields. What happens next
+depends on the evaluator:
+
+  - ScottEncode compiles both into the pure calculus for the reference reducer: with
+    constructors C1 ... Cn, Ci applied to its fields X1 ... Xk is λK1...λKn.(Ki X1 ... Xk),
+    a function choosing among handlers, so a case is the scrutinee applied to its branches
+    in declaration order. ScottDecode turns such values back into constructors for display,
+    as far as it can tell: untyped, @CONS(1)(X1) of LIST and @NODE(1)(X1) of a TREE with
+    constructors @LEAF(V1) and @NODE(A1)(B1) are the same term, left encoded
+  - the Krivine and CEK machines implement them directly: a constructor is a constant the
+    machine cannot apply, and a case evaluates its scrutinee alone, then continues with the
+    branch of its constructor applied to the fields, leaving the other branches unevaluated
+
+Both check every case against the declarations first (DataEnv.Check): each constructor known,
+the branches covering the constructors of one type exactly once with the right field count.
+*/
+type DataDecl struct {
+	Name         string
+	Constructors []DataConstructor
+}
+
+type DataConstructor struct {
+	Name   string
+	Fields []string
+}
+
+// Constructor as declared, @CONS(H1)(T1).
+func (c DataConstructor) Print() string {
+	out := Prim_Prefix + c.Name
+	for _, field := range c.Fields {
+		out += "(" + field + ")"
+	}
+	return out
+}
+
+func (d DataDecl) Print() string {
+	constructors := []string{}
+	for _, constructor := range d.Constructors {
+		constructors = append(constructors, constructor.Print())
+	}
+	return "data " + d.Name + " = " + strings.Join(constructors, " | ")
+}
+
+// Keyword naming the case primitive, @CASE
+const Case_Keyword = "CASE"
+
+// Parse a declaration with its "data" keyword and whitespace removed, LIST=@NIL|@CONS(H1)(T1).
+// Errors are *ParseError positioned in source.
+func ParseDataDecl(source string) (DataDecl, error) {
+	source = strings.ToUpper(source)
+	decl := DataDecl{Constructors: []DataConstructor{}}
+	decl_err := func(offset int, format string, args ...any) error {
+		return &ParseError{Offset: offset, State: I_i, Err: fmt.Errorf(format, args...)}
+	}
+	eq := strings.Index(source, "=")
+	if eq < 0 {
+		return decl, decl_err(len(source), "Data declaration %q needs = and its constructors, data NAME = @C1 | @C2(X1)", source)
+	}
+	decl.Name = source[:eq]
+	if !IsTypeNameChar(decl.Name) {
+		return decl, decl_err(0, "Data type name %q must be letters, digits or _", decl.Name)
+	}
+	offset := eq + 1
+	for _, alternative := range strings.Split(source[eq+1:], "|") {
+		start := offset
+		offset += len(alternative) + 1
+		if !strings.HasPrefix(alternative, Prim_Prefix) {
+			return decl, decl_err(start, "Constructor %q of %v must start with @, as in @%v", alternative, decl.Name, alternative)
+		}
+		name_end := strings.IndexFunc(alternative[1:], func(r rune) bool { return !IsPrimNameChar(string(r)) }) + 1
+		if name_end == 0 {
+			name_end = len(alternative)
+		}
+		constructor := DataConstructor{Name: alternative[1:name_end], Fields: []string{}}
+		if len(constructor.Name) == 0 {
+			return decl, decl_err(start, "Constructor of %v needs a name after @", decl.Name)
+		}
+		fields := alternative[name_end:]
+		for len(fields) > 0 {
+			at := start + len(alternative) - len(fields)
+			closing := strings.Index(fields, ")")
+			if !strings.HasPrefix(fields, "(") || closing < 0 || !IsVariableName(fields[1:closing]) {
+				return decl, decl_err(at, "Fields of @%v must be variables in parentheses, as in @%v(X1)(Y1), found %q",
+					constructor.Name, constructor.Name, fields)
+			}
+			constructor.Fields = append(constructor.Fields, fields[1:closing])
+			fields = fields[closing+1:]
+		}
+		decl.Constructors = append(decl.Constructors, constructor)
+	}
+	return decl, nil
+}
+
+// Declared types, and which of them each constructor belongs to.
+type DataEnv struct {
+	// Declarations in the order they were made
+	Types        []*DataDecl
+	constructors map[string]dataRef
+}
+
+type dataRef struct {
+	Decl  *DataDecl
+	Index int
+}
+
+func CreateDataEnv() *DataEnv {
+	return &DataEnv{Types: []*DataDecl{}, constructors: map[string]dataRef{}}
+}
+
+// Add decl, whose type and constructor names must all be new.
+func (env *DataEnv) Declare(decl DataDecl) error {
+	for _, declared := range env.Types {
+		if declared.Name == decl.Name {
+			return fmt.Errorf("Data type %v is already declared", decl.Name)
+		}
+	}
+	seen := map[string]bool{}
+	for _, constructor := range decl.Constructors {
+		if _, is_prim := LookupPrim(Prim_Prefix + constructor.Name); is_prim || constructor.Name == Case_Keyword {
+			return fmt.Errorf("@%v is a primitive and cannot be a constructor of %v", constructor.Name, decl.Name)
+		} else if ref, found := env.constructors[constructor.Name]; found {
+			return fmt.Errorf("@%v is already a constructor of %v", constructor.Name, ref.Decl.Name)
+		} else if seen[constructor.Name] {
+			return fmt.Errorf("@%v is declared twice in %v", constructor.Name, decl.Name)
+		}
+		seen[constructor.Name] = true
+	}
+	stored := &decl
+	env.Types = append(env.Types, stored)
+	for i, constructor := range decl.Constructors {
+		env.constructors[constructor.Name] = dataRef{Decl: stored, Index: i}
+	}
+	return nil
+}
+
+// LCon is a constructor, @CONS.
+type LCon struct {
+	Name string
+}
+
+// LCase is the @CASE keyword, applied to a scrutinee and then its branches.
+type LCase struct{}
+
+// LBranch is a branch of a case, @CONS.H1.T1.(Body), binding the fields of the constructor.
+type LBranch struct {
+	Con    string
+	Fields []string
+	Body   LExpr
+}
+
+func (l *LCon) LPrint() string {
+	return Prim_Prefix + l.Name
+}
+
+func (l *LCase) LPrint() string {
+	return Prim_Prefix + Case_Keyword
+}
+
+func (l *LBranch) LPrint() string {
+	out := BranchHead(l.Con) + "."
+	for _, field := range l.Fields {
+		out += field + "."
+	}
+	return out + parenthesized(l.Body)
+}
+
+func (l *LCon) LAbstract(b LVar) LExpr {
+	return primAbstract(l, b)
+}
+
+func (l *LCase) LAbstract(b LVar) LExpr {
+	return primAbstract(l, b)
+}
+
+func (l *LBranch) LAbstract(b LVar) LExpr {
+	return primAbstract(l, b)
+}
+
+func (l *LCon) Copy() LExpr {
+	return &LCon{Name: l.Name}
+}
+
+func (l *LCase) Copy() LExpr {
+	return &LCase{}
+}
+
+func (l *LBranch) Copy() LExpr {
+	return &LBranch{Con: l.Con, Fields: append([]string{}, l.Fields...), Body: l.Body.Copy()}
+}
+
+func (l *LCon) LApply(b LVar, replace LExpr) LExpr {
+	return l.Copy()
+}
+
+func (l *LCase) LApply(b LVar, replace LExpr) LExpr {
+	return l.Copy()
+}
+
+// The body is in the scope of the fields, which may shadow b.
+func (l *LBranch) LApply(b LVar, replace LExpr) LExpr {
+	for _, field := range l.Fields {
+		if field == b.Symbol {
+			return l.Copy()
+		}
+	}
+	return &LBranch{Con: l.Con, Fields: append([]string{}, l.Fields...), Body: l.Body.LApply(b, replace)}
+}
+
+func (l *LCon) LEquals(l2 LExpr) bool {
+	return l.LPrint() == l2.LPrint()
+}
+
+func (l *LCase) LEquals(l2 LExpr) bool {
+	return l.LPrint() == l2.LPrint()
+}
+
+func (l *LBranch) LEquals(l2 LExpr) bool {
+	return l.LPrint() == l2.LPrint()
+}
+
+// l as a branch, looking through the parentheses around it.
+func asBranch(l LExpr) (*LBranch, bool) {
+	for {
+		switch node := l.(type) {
+		case *LBranch:
+			return node, true
+		case *LExpression:
+			if len(node.Binding.Symbol) > 0 || len(node.Exprs) != 1 {
+				return nil, false
+			}
+			l = node.Exprs[0]
+		default:
+			return nil, false
+		}
+	}
+}
+
+// One branch of a lowered case: the constructor it matches and the fields it binds.
+type CaseArm struct {
+	Con   string
+	Arity int
+}
+
+// Name of the Core constant for a case with arms, "@CASE.NIL/0.CONS/2".
+func CaseName(arms []CaseArm) string {
+	out := Prim_Prefix + Case_Keyword
+	for _, arm := range arms {
+		out += "." + arm.Con + "/" + strconv.Itoa(arm.Arity)
+	}
+	return out
+}
+
+// Arms of a case constant named by CaseName.
+func ParseCaseName(name string) ([]CaseArm, bool) {
+	parts := strings.Split(name, ".")
+	if parts[0] != Prim_Prefix+Case_Keyword || len(parts) < 2 {
+		return nil, false
+	}
+	arms := []CaseArm{}
+	for _, part := range parts[1:] {
+		con, arity_text, found := strings.Cut(part, "/")
+		arity, atoi_err := strconv.Atoi(arity_text)
+		if !found || atoi_err != nil || !IsPrimNameChar(strings.TrimPrefix(con, Tag_Prefix)) {
+			return nil, false
+		}
+		arms = append(arms, CaseArm{Con: con, Arity: arity})
+	}
+	return arms, true
+}
+
+// Name of the constructor c is the constant of, "" if it is none.
+func ConstructorName(c Core) string {
+	free, is_free := c.(*CFree)
+	if !is_free || !strings.HasPrefix(free.Name, Prim_Prefix) {
+		return ""
+	}
+	name := free.Name[len(Prim_Prefix):]
+	if _, is_prim := DecodePrim(free); is_prim || !IsPrimNameChar(name) || name == Case_Keyword {
+		return ""
+	}
+	return name
+}
+
+// @CASE applied to a scrutinee, its branches and possibly further arguments.
+func lowerCase(lexprs []LExpr, scope []string) (Core, error) {
+	if len(lexprs) < 3 {
+		return nil, fmt.Errorf("@CASE needs a scrutinee and branches, as in @CASE(E)(@C.X1.(B))")
+	}
+	scrutinee, scrutinee_err := lower(lexprs[1], scope)
+	if scrutinee_err != nil {
+		return nil, scrutinee_err
+	}
+	arms := []CaseArm{}
+	args := []Core{scrutinee}
+	rest := lexprs[2:]
+	for len(rest) > 0 {
+		branch, is_branch := asBranch(rest[0])
+		if !is_branch {
+			break
+		}
+		inner_scope := append(scope[:len(scope):len(scope)], branch.Fields...)
+		handler, handler_err := lower(branch.Body, inner_scope)
+		if handler_err != nil {
+			return nil, handler_err
+		}
+		for i := len(branch.Fields) - 1; i >= 0; i-- {
+			handler = &CLam{Name: branch.Fields[i], Body: handler}
+		}
+		arms = append(arms, CaseArm{Con: branch.Con, Arity: len(branch.Fields)})
+		args = append(args, handler)
+		rest = rest[1:]
+	}
+	if len(arms) == 0 {
+		return nil, fmt.Errorf("@CASE(%v) needs at least one branch, as in (@C.X1.(B))", lexprs[1].LPrint())
+	}
+	out := CoreApply(&CFree{Name: CaseName(arms)}, args...)
+	for _, lexpr := range rest {
+		if _, is_type_arg := lexpr.(*LTypeArg); is_type_arg {
+			continue
+		}
+		next, next_err := lower(lexpr, scope)
+		if next_err != nil {
+			return nil, next_err
+		}
+		out = &CApp{Fun: out, Arg: next}
+	}
+	return out, nil
+}
+
+// The declared type arms cover, and for each of its constructors the index of the arm
+// matching it.
+func (env *DataEnv) resolveArms(arms []CaseArm) (*DataDecl, []int, error) {
+	var decl *DataDecl = nil
+	order := []int{}
+	for i, arm := range arms {
+		ref, found := env.constructors[arm.Con]
+		if !found {
+			return nil, nil, fmt.Errorf("Unknown constructor @%v in a @CASE branch, no data declaration has it", arm.Con)
+		}
+		if decl == nil {
+			decl = ref.Decl
+			order = make([]int, len(decl.Constructors))
+			for j := range order {
+				order[j] = -1
+			}
+		} else if ref.Decl != decl {
+			return nil, nil, fmt.Errorf("@CASE mixes constructors of %v and %v", decl.Name, ref.Decl.Name)
+		}
+		if order[ref.Index] >= 0 {
+			return nil, nil, fmt.Errorf("@CASE has two branches for @%v", arm.Con)
+		}
+		declared := decl.Constructors[ref.Index]
+		if arm.Arity != len(declared.Fields) {
+			return nil, nil, fmt.Errorf("Branch @%v binds %v variables for the fields of %v",
+				arm.Con, arm.Arity, declared.Print())
+		}
+		order[ref.Index] = i
+	}
+	for j, i := range order {
+		if i < 0 {
+			return nil, nil, fmt.Errorf("@CASE on %v has no branch for %v", decl.Name, decl.Constructors[j].Print())
+		}
+	}
+	return decl, order, nil
+}
+
+// Check that every constructor and case of c agrees with the declarations.
+func (env *DataEnv) Check(c Core) error {
+	_, encode_err := env.ScottEncode(c)
+	return encode_err
+}
+
+// ScottEncode replaces the constructors and cases of c by their Scott encodings.
+func (env *DataEnv) ScottEncode(c Core) (Core, error) {
+	switch node := c.(type) {
+	case *CLam:
+		body, body_err := env.ScottEncode(node.Body)
+		if body_err != nil {
+			return nil, body_err
+		}
+		return &CLam{Name: node.Name, Body: body}, nil
+	case *CVar:
+		return c, nil
+	}
+	head, args := CoreSpine(c)
+	for i, arg := range args {
+		encoded, arg_err := env.ScottEncode(arg)
+		if arg_err != nil {
+			return nil, arg_err
+		}
+		args[i] = encoded
+	}
+	free, is_free := head.(*CFree)
+	if !is_free {
+		fun, fun_err := env.ScottEncode(head)
+		if fun_err != nil {
+			return nil, fun_err
+		}
+		return CoreApply(fun, args...), nil
+	}
+	if arms, is_case := ParseCaseName(free.Name); is_case {
+		decl, order, arms_err := env.resolveArms(arms)
+		if arms_err != nil {
+			return nil, arms_err
+		}
+		if len(args) < 1+len(arms) {
+			return nil, fmt.Errorf("@CASE on %v needs its scrutinee and %v branches", decl.Name, len(arms))
+		}
+		handlers := []Core{}
+		for _, i := range order {
+			handlers = append(handlers, args[1+i])
+		}
+		return CoreApply(args[0], append(handlers, args[1+len(arms):]...)...), nil
+	}
+	if name := ConstructorName(free); len(name) > 0 {
+		ref, found := env.constructors[name]
+		if !found {
+			return nil, fmt.Errorf("Unknown constructor @%v, no data declaration has it", name)
+		}
+		return CoreApply(scottConstructor(ref.Decl, ref.Index), args...), nil
+	}
+	return CoreApply(head, args...), nil
+}
+
+// λX1...λXk.λK1...λKn.(Ki X1 ... Xk) for constructor i of decl.
+func scottConstructor(decl *DataDecl, i int) Core {
+	n := len(decl.Constructors)
+	fields := decl.Constructors[i].Fields
+	var out Core = &CVar{Index: n - 1 - i, Name: "K1"}
+	for j, field := range fields {
+		out = &CApp{Fun: out, Arg: &CVar{Index: n + len(fields) - 1 - j, Name: field}}
+	}
+	for range n {
+		out = &CLam{Name: "K1", Body: out}
+	}
+	for j := len(fields) - 1; j >= 0; j-- {
+		out = &CLam{Name: fields[j], Body: out}
+	}
+	return out
+}
+
+// ScottDecode turns the Scott encodings of constructor applications within c back into
+// constructors, when they fit the constructors of a single declaration.
+func (env *DataEnv) ScottDecode(c Core) Core {
+	var found Core = nil
+	matches := 0
+	for _, decl := range env.Types {
+		n := len(decl.Constructors)
+		body := c
+		for i := 0; i < n && body != nil; i++ {
+			lam, is_lam := body.(*CLam)
+			if !is_lam {
+				body = nil
+				break
+			}
+			body = lam.Body
+		}
+		if body == nil {
+			continue
+		}
+		head, args := CoreSpine(body)
+		handler, is_var := head.(*CVar)
+		if !is_var || handler.Index >= n || len(args) != len(decl.Constructors[n-1-handler.Index].Fields) {
+			continue
+		}
+		decoded := []Core{}
+		for _, arg := range args {
+			if refersBelow(arg, n, 0) {
+				break
+			}
+			decoded = append(decoded, env.ScottDecode(Shift(arg, -n, 0)))
+		}
+		if len(decoded) == len(args) {
+			found = CoreApply(&CFree{Name: Prim_Prefix + decl.Constructors[n-1-handler.Index].Name}, decoded...)
+			matches += 1
+		}
+	}
+	if matches == 1 {
+		return found
+	}
+	switch node := c.(type) {
+	case *CLam:
+		return &CLam{Name: node.Name, Body: env.ScottDecode(node.Body)}
+	case *CApp:
+		return &CApp{Fun: env.ScottDecode(node.Fun), Arg: env.ScottDecode(node.Arg)}
+	}
+	return c
+}
+
+// Whether c, under depth binders of its own, uses any of the bound nearest enclosing indices.
+func refersBelow(c Core, bound int, depth int) bool {
+	switch node := c.(type) {
+	case *CVar:
+		return node.Index >= depth && node.Index < depth+bound
+	case *CLam:
+		return refersBelow(node.Body, bound, depth+1)
+	case *CApp:
+		return refersBelow(node.Fun, bound, depth) || refersBelow(node.Arg, bound, depth)
+	}
+	return false
+}
+
+// Read a stuck case back as @CASE(E)(@C.X1.(B)) ..., eta-expanding branches that are not
+// functions of all their fields.
+func raiseCase(arms []CaseArm, args []Core, scope []string, used map[string]bool) LExpression {
+	lexprs := []LExpr{&LCase{}, raise(args[0], scope, used)}
+	for i, arm := range arms {
+		handler := args[1+i]
+		inner_scope := scope[:len(scope):len(scope)]
+		fields := []string{}
+		for range arm.Arity {
+			lam, is_lam := handler.(*CLam)
+			if !is_lam {
+				lam = &CLam{Name: "X1", Body: &CApp{Fun: Shift(handler, 1, 0), Arg: &CVar{Index: 0, Name: "X1"}}}
+			}
+			name := FreshName(lam.Name, used)
+			used[name] = true
+			fields = append(fields, name)
+			inner_scope = append(inner_scope, name)
+			handler = lam.Body
+		}
+		body := raiseSeq(handler, inner_scope, used)
+		for _, field := range fields {
+			delete(used, field)
+		}
+		lexprs = append(lexprs, &LBranch{Con: arm.Con, Fields: fields, Body: &body})
+	}
+	for _, arg := range args[1+len(arms):] {
+		lexprs = append(lexprs, raise(arg, scope, used))
+	}
+	return ConcatenateLExprs(lexprs)
+}
+
+// Programs shown by "lambda eval" after Prim_Examples_Source: lists and trees.
+const Data_Examples_Source = `
+data LIST = @NIL | @CONS(H1)(T1)
+XS1 = @CONS(1)(@CONS(2)(@CONS(3)(@NIL)))
+SUM2 = λ@S1=(λX1.(@CASE(X1)(@NIL.(0))(@CONS.H1.T1.(+(H1)(S1(T1)))))).(S1)
+SUM2(XS1)
+MAP1 = λ@M1=(λF1.(λX1.(@CASE(X1)(@NIL.(@NIL))(@CONS.H1.T1.(@CONS(F1(H1))(M1(F1)(T1))))))).(M1)
+MAP1(+(10))(XS1)
+APPEND1 = λ@A1=(λX1.(λY1.(@CASE(X1)(@CONS.H1.T1.(@CONS(H1)(A1(T1)(Y1))))(@NIL.(Y1))))).(A1)
+APPEND1(XS1)(XS1)
+data TREE = @LEAF(V1) | @NODE(A1)(B1)
+T2 = @NODE(@NODE(@LEAF(1))(@LEAF(2)))(@LEAF(3))
+MIRROR1 = λ@M1=(λT1.(@CASE(T1)(@LEAF.V1.(@LEAF(V1)))(@NODE.A1.B1.(@NODE(M1(B1))(M1(A1)))))).(M1)
+FRINGE1 = λ@F1=(λT1.(@CASE(T1)(@LEAF.V1.(@CONS(V1)(@NIL)))(@NODE.A1.B1.(APPEND1(F1(A1))(F1(B1)))))).(F1)
+SUM2(FRINGE1(MIRROR1(T2)))
+`
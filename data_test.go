@@ -0,0 +1,246 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// The data environment and closed definitions of source, as lambda eval builds them, and
+// the remaining bare terms.
+func loadData(t *testing.T, source string) (*DataEnv, []Core) {
+	t.Helper()
+	decoder := CreateDecoder(strings.NewReader(source))
+	decoder.AcceptData = true
+	env := CreateDataEnv()
+	globals := map[string]Core{}
+	terms := []Core{}
+	for {
+		stmt, decode_err := decoder.Decode()
+		if decode_err == io.EOF {
+			return env, terms
+		} else if decode_err != nil {
+			t.Fatalf("%v", decode_err)
+		}
+		if stmt.Data != nil {
+			if declare_err := env.Declare(*stmt.Data); declare_err != nil {
+				t.Fatalf("%v", declare_err)
+			}
+			continue
+		}
+		c, lower_err := Lower(stmt.Expr)
+		if lower_err != nil {
+			t.Fatalf("%v: %v", stmt.Source, lower_err)
+		}
+		c = SubstituteFree(c, globals)
+		if IsVariableName(stmt.Name) {
+			globals[stmt.Name] = c
+		} else {
+			terms = append(terms, c)
+		}
+	}
+}
+
+func TestParseDataDecl(t *testing.T) {
+	cases := []struct {
+		source string
+		want   string
+	}{
+		{"LIST=@NIL|@CONS(H1)(T1)", "data LIST = @NIL | @CONS(H1)(T1)"},
+		{"bool=@yes|@no", "data BOOL = @YES | @NO"},
+		{"PAIR_2=@P(A1)(B1)", "data PAIR_2 = @P(A1)(B1)"},
+	}
+	for _, c := range cases {
+		decl, decl_err := ParseDataDecl(c.source)
+		if decl_err != nil {
+			t.Errorf("%v: %v", c.source, decl_err)
+		} else if decl.Print() != c.want {
+			t.Errorf("%v: got %v, want %v", c.source, decl.Print(), c.want)
+		}
+	}
+}
+
+func TestParseDataDeclErrors(t *testing.T) {
+	cases := []struct {
+		source string
+		offset int
+		want   string
+	}{
+		{"LIST", 4, "needs = and its constructors"},
+		{"=@NIL", 0, "must be letters, digits or _"},
+		{"L-IST=@NIL", 0, "must be letters, digits or _"},
+		{"LIST=@NIL|CONS", 10, "must start with @"},
+		{"LIST=@NIL|@", 10, "needs a name after @"},
+		{"LIST=@NIL|@CONS(H1", 15, "must be variables in parentheses"},
+		{"LIST=@CONS(h)", 10, "must be variables in parentheses"},
+	}
+	for _, c := range cases {
+		_, decl_err := ParseDataDecl(c.source)
+		parse_err, is_parse := decl_err.(*ParseError)
+		if !is_parse {
+			t.Errorf("%v: got %v, want a *ParseError", c.source, decl_err)
+		} else if !strings.Contains(parse_err.Error(), c.want) || parse_err.Offset != c.offset {
+			t.Errorf("%v: got %v at %v, want %q at %v", c.source, parse_err, parse_err.Offset, c.want, c.offset)
+		}
+	}
+}
+
+func TestDecoderReadsDataKeyword(t *testing.T) {
+	stmts := decodeAll(t, "DATA = 5\ndata1 = 6")
+	if len(stmts) != 2 || stmts[0].Name != "DATA" || stmts[1].Name != "DATA1" {
+		t.Fatalf("got %v, want the definitions DATA and DATA1", stmts)
+	}
+	for _, stmt := range stmts {
+		if stmt.Data != nil {
+			t.Errorf("%v: read as a data declaration", stmt.Source)
+		}
+	}
+	env, _ := loadData(t, "Data  list =@NIL | @CONS(H1)(T1)\n")
+	if len(env.Types) != 1 || env.Types[0].Name != "LIST" {
+		t.Errorf("got %v, want LIST declared", env.Types)
+	}
+}
+
+func TestDeclareErrors(t *testing.T) {
+	cases := []struct {
+		source string
+		want   string
+	}{
+		{"data LIST = @NIL\ndata LIST = @EMPTY", "already declared"},
+		{"data LIST = @NIL\ndata OTHER = @NIL", "already a constructor of LIST"},
+		{"data TWICE = @A | @A", "declared twice"},
+		{"data BAD = @IF", "is a primitive"},
+		{"data BAD = @CASE", "is a primitive"},
+	}
+	for _, c := range cases {
+		env := CreateDataEnv()
+		var declare_err error = nil
+		for _, line := range strings.Split(c.source, "\n") {
+			decl, decl_err := ParseDataDecl(strings.TrimPrefix(strings.ReplaceAll(line, " ", ""), "data"))
+			if decl_err != nil {
+				t.Fatalf("%v: %v", line, decl_err)
+			}
+			declare_err = env.Declare(decl)
+		}
+		if declare_err == nil || !strings.Contains(declare_err.Error(), c.want) {
+			t.Errorf("%v: got %v, want %q", c.source, declare_err, c.want)
+		}
+	}
+}
+
+func TestScottRoundTrips(t *testing.T) {
+	cases := []struct {
+		decls  string
+		source string
+	}{
+		{"data LIST = @NIL | @CONS(H1)(T1)", "@NIL"},
+		{"data LIST = @NIL | @CONS(H1)(T1)", "@CONS(1)(@NIL)"},
+		{"data LIST = @NIL | @CONS(H1)(T1)", "@CONS(1)(@CONS(2)(@CONS(3)(@NIL)))"},
+		{"data LIST = @NIL | @CONS(H1)(T1)", "λX1.(@CONS(X1)(X1))"},
+		{"data TREE = @LEAF(V1) | @NODE(A1)(B1)", "@NODE(@LEAF(1))(@NODE(@LEAF(2))(@LEAF(3)))"},
+		{"data LIST = @NIL | @CONS(H1)(T1)\ndata COLOUR = @RED | @GREEN | @BLUE",
+			"@CONS(@RED)(@CONS(@BLUE)(@NIL))"},
+	}
+	for _, c := range cases {
+		env, _ := loadData(t, c.decls)
+		source := parseCore(t, c.source)
+		encoded, encode_err := env.ScottEncode(source)
+		if encode_err != nil {
+			t.Errorf("%v: %v", c.source, encode_err)
+			continue
+		}
+		if usesConstructors(encoded) {
+			t.Errorf("%v: %v still has constructors", c.source, encoded.CPrint())
+		}
+		if decoded := env.ScottDecode(normalOrder(t, encoded)); decoded.CPrint() != source.CPrint() {
+			t.Errorf("%v: decoded %v, want %v", c.source, decoded.CPrint(), source.CPrint())
+		}
+	}
+}
+
+// Encodings shared by two declarations, here @CONS(1)(@NIL) and @NODE(1)(@NIL), are left as
+// they are rather than guessed at.
+func TestScottDecodeLeavesAmbiguousEncodings(t *testing.T) {
+	env, _ := loadData(t, "data LIST = @NIL | @CONS(H1)(T1)\ndata TREE = @LEAF(V1) | @NODE(A1)(B1)")
+	encoded, encode_err := env.ScottEncode(parseCore(t, "@CONS(1)(@NIL)"))
+	if encode_err != nil {
+		t.Fatalf("%v", encode_err)
+	}
+	nf := normalOrder(t, encoded)
+	if decoded := env.ScottDecode(nf); ConstructorName(decoded) != "" {
+		t.Errorf("decoded %v although LIST and TREE both fit", decoded.CPrint())
+	}
+}
+
+// Whether a constructor is left anywhere in c.
+func usesConstructors(c Core) bool {
+	names := map[string]bool{}
+	CoreFreeNames(c, names)
+	for name := range names {
+		if len(ConstructorName(&CFree{Name: name})) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCaseArmErrors(t *testing.T) {
+	env, _ := loadData(t, "data LIST = @NIL | @CONS(H1)(T1)\ndata TREE = @LEAF(V1) | @NODE(A1)(B1)\n")
+	cases := []struct {
+		source string
+		want   string
+	}{
+		{"@CASE(X1)(@NIL.(0))(@FOO.H1.T1.(1))", "Unknown constructor @FOO in a @CASE branch"},
+		{"@CASE(X1)(@NIL.(0))(@LEAF.V1.(1))", "@CASE mixes constructors of LIST and TREE"},
+		{"@CASE(X1)(@NIL.(0))(@NIL.(1))", "@CASE has two branches for @NIL"},
+		{"@CASE(X1)(@NIL.(0))(@CONS.H1.(H1))", "Branch @CONS binds 1 variables for the fields of @CONS(H1)(T1)"},
+		{"@CASE(X1)(@NIL.(0))", "@CASE on LIST has no branch for @CONS(H1)(T1)"},
+		{"@FOO(1)", "Unknown constructor @FOO, no data declaration has it"},
+	}
+	for _, c := range cases {
+		check_err := env.Check(parseCore(t, c.source))
+		if check_err == nil || !strings.Contains(check_err.Error(), c.want) {
+			t.Errorf("%v: got %v, want %q", c.source, check_err, c.want)
+		}
+	}
+	if check_err := env.Check(parseCore(t, "@CASE(X1)(@CONS.H1.T1.(H1))(@NIL.(0))")); check_err != nil {
+		t.Errorf("branches in any order: %v", check_err)
+	}
+}
+
+// The machines run @CASE directly and the strategies reduce its Scott encoding. Machines stop
+// at a weak head normal form, so what they return is normalized before comparing.
+func TestCaseAgreesWithScottEncoding(t *testing.T) {
+	env, terms := loadData(t, Data_Examples_Source)
+	if len(terms) == 0 {
+		t.Fatalf("no examples to evaluate")
+	}
+	for _, term := range terms {
+		encoded, encode_err := env.ScottEncode(term)
+		if encode_err != nil {
+			t.Fatalf("%v: %v", term.CPrint(), encode_err)
+		}
+		want := env.ScottDecode(normalOrder(t, encoded)).CPrint()
+		for name, run := range Eval_Machines {
+			result, eval_err := run(term, Test_Max_Steps)
+			if eval_err != nil {
+				t.Errorf("%v on %v: %v", name, term.CPrint(), eval_err)
+				continue
+			}
+			quoted, quote_err := env.ScottEncode(result.Quote())
+			if quote_err != nil {
+				t.Errorf("%v on %v: %v", name, term.CPrint(), quote_err)
+			} else if got := env.ScottDecode(normalOrder(t, quoted)).CPrint(); got != want {
+				t.Errorf("%v on %v: got %v, want %v", name, term.CPrint(), got, want)
+			}
+		}
+		for _, strategy := range []Strategy{R_NormalOrder, R_ApplicativeOrder} {
+			nf, _, reduce_err := Reduce(encoded, strategy, Test_Max_Steps)
+			if reduce_err != nil {
+				t.Errorf("%v under %v: %v", term.CPrint(), strategy, reduce_err)
+			} else if got := env.ScottDecode(nf).CPrint(); got != want {
+				t.Errorf("%v under %v: got %v, want %v", term.CPrint(), strategy, got, want)
+			}
+		}
+	}
+}
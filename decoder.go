@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+/*
+	Decoder - reads successive top-level statements from an io.Reader
+
+A statement is either a bare term or a definition "NAME = term", the first "=" outside of
+parentheses and braces separating the two. A statement opening with a let binding, LX1=(...),
+is a term, so names shaped like a binding (LX1) cannot be defined. Statements end at a
+newline or ";" outside of parentheses and braces, so a term may span lines while it is inside
+(...) or a record literal {...}, which only decoders with AcceptRecords set read (see records.go).
+A blank line always ends a statement, so a missing ")" cannot swallow the rest of a file.
+Whitespace is dropped, "#" starts a comment running to the end of the line, and both "λ"
+and "\" are accepted in place of "L", except inside "..." string literals, which are kept as
+written. A statement starting with the keyword "data", a type name and "=" declares a data
+type instead (see data.go), which only decoders with AcceptData set allow; DATA = 5 is still
+a definition. Only one statement is held in memory at a time.
+*/
+type Statement struct {
+	// Empty unless the statement is a definition
+	Name string
+	Expr LExpr
+	// Text handed to the parser once whitespace and comments are removed
+	Source string
+	Pos    Position
+	// Source position of every byte of Source (including the definition prefix)
+	Positions []Position
+	// Every error found in the statement when the Decoder is in recovery mode
+	Errors ParseErrorList
+	// Where each node of Expr was parsed from, relative to the body (Source after "NAME=")
+	Spans     map[LExpr]Span
+	BodyStart int
+	// Set instead of Expr by a data declaration
+	Data *DataDecl
+}
+
+type Decoder struct {
+	r        *bufio.Reader
+	executor TransitionExecutor
+	// Position of the next rune to be read
+	pos Position
+	// Optional sink for parse events of every statement
+	Tracer ParseTracer
+	// Recovery mode: report every error of a statement and return a partial Expr with
+	// LError nodes instead of stopping at the first error
+	Recover bool
+	// Accept data declarations, which not every consumer of statements supports
+	AcceptData bool
+	// Parse records, projections and tags
+	AcceptRecords bool
+}
+
+func CreateDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r:        bufio.NewReader(r),
+		executor: TransitionExecutor_Init(),
+		pos:      Position{Line: 1, Column: 1, Offset: 0},
+	}
+}
+
+// Decode returns the next statement, or io.EOF once the input is exhausted. A statement
+// that fails to parse yields a *ParseError positioned in the original input (a
+// ParseErrorList in recovery mode); decoding may carry on with the following statement.
+func (d *Decoder) Decode() (Statement, error) {
+	stmt, read_err := d.readStatement()
+	if read_err != nil {
+		return stmt, read_err
+	}
+	if stmt.Data != nil {
+		return d.decodeData(stmt)
+	}
+	body := stmt.Source
+	body_start := 0
+	if eq := DefinitionSplit(stmt.Source); eq >= 0 {
+		stmt.Name = strings.ToUpper(stmt.Source[:eq])
+		body = stmt.Source[eq+1:]
+		body_start = eq + 1
+		stmt.BodyStart = body_start
+		if !IsDefinitionName(stmt.Name) {
+			name_err := d.positioned(stmt, &ParseError{
+				Offset: 0,
+				State:  I_i,
+				Err:    fmt.Errorf("Definition name %q must be letters, digits or _", stmt.Name),
+			}, 0)
+			if !d.Recover {
+				return stmt, name_err
+			}
+			stmt.Errors = append(stmt.Errors, name_err)
+		}
+	}
+	if len(body) == 0 {
+		empty_err := d.positioned(stmt, &ParseError{
+			Offset: 0,
+			State:  I_i,
+			Err:    fmt.Errorf("Statement %q has no term to parse", stmt.Source),
+		}, body_start)
+		if !d.Recover {
+			return stmt, empty_err
+		}
+		stmt.Errors = append(stmt.Errors, empty_err)
+		return stmt, stmt.Errors
+	}
+	if d.Recover {
+		p := Parser_Init()
+		p.Tracer = d.Tracer
+		p.Recover = true
+		p.Records = d.AcceptRecords
+		p, parse_err := d.executor.Run(p, body)
+		if parse_err != nil {
+			p = d.executor.RecoverFrom(p, parse_err, "")
+		}
+		for _, body_err := range p.Errors {
+			stmt.Errors = append(stmt.Errors, d.positioned(stmt, body_err, body_start))
+		}
+		stmt.Expr = SingleLExpr(p.Exprs)
+		stmt.Spans = p.Spans
+		if len(stmt.Errors) > 0 {
+			return stmt, stmt.Errors
+		}
+		return stmt, nil
+	}
+	p := Parser_Init()
+	p.Tracer = d.Tracer
+	p.Records = d.AcceptRecords
+	p, parse_err := d.executor.Run(p, body)
+	if parse_err != nil {
+		return stmt, d.positioned(stmt, parse_err, body_start)
+	}
+	stmt.Expr = SingleLExpr(p.Exprs)
+	stmt.Spans = p.Spans
+	return stmt, nil
+}
+
+// The declaration making up a statement that started with "data".
+func (d *Decoder) decodeData(stmt Statement) (Statement, error) {
+	decl, decl_err := ParseDataDecl(stmt.Source)
+	if decl_err == nil && !d.AcceptData {
+		decl_err = fmt.Errorf("Data declarations are only supported by lambda eval")
+	}
+	if decl_err != nil {
+		located := d.positioned(stmt, decl_err, 0)
+		if !d.Recover {
+			return stmt, located
+		}
+		stmt.Errors = append(stmt.Errors, located)
+		return stmt, stmt.Errors
+	}
+	stmt.Data = &decl
+	return stmt, nil
+}
+
+// Input position of an offset within the statement body.
+func (stmt Statement) Locate(offset int) Position {
+	offset += stmt.BodyStart
+	if offset < len(stmt.Positions) {
+		return stmt.Positions[offset]
+	} else if len(stmt.Positions) > 0 {
+		// At the end of the statement: point just past its last character
+		pos := stmt.Positions[len(stmt.Positions)-1]
+		pos.Column += 1
+		pos.Offset += 1
+		return pos
+	}
+	return stmt.Pos
+}
+
+// Resolve a parse error's offset within the statement body back to the input position.
+func (d *Decoder) positioned(stmt Statement, err error, body_start int) *ParseError {
+	var parse_err *ParseError
+	if !errors.As(err, &parse_err) {
+		parse_err = &ParseError{Offset: 0, State: I_i, Err: err}
+	}
+	located := *parse_err
+	stmt.BodyStart = body_start
+	located.Pos = stmt.Locate(located.Offset)
+	if paren_err, is_paren := located.Err.(*ParenError); is_paren {
+		located_paren := *paren_err
+		located_paren.Pos = located.Pos
+		located.Err = &located_paren
+	}
+	return &located
+}
+
+// Gather the next non-empty statement. Returns io.EOF when no statement remains.
+func (d *Decoder) readStatement() (Statement, error) {
+	stmt := Statement{}
+	var source strings.Builder
+	depth := 0
+	in_comment := false
+	blank_line := false
+	strings_tracker := ParenTracker{}
+	for {
+		r, size, read_err := d.r.ReadRune()
+		if read_err == io.EOF {
+			break
+		}
+		if read_err != nil {
+			return stmt, read_err
+		}
+		pos := d.pos
+		d.pos.Offset += size
+		d.pos.Column += 1
+		if r == '\n' {
+			d.pos.Line += 1
+			d.pos.Column = 1
+		}
+		if in_comment {
+			if r != '\n' {
+				continue
+			}
+			in_comment = false
+		}
+		if r == '\n' {
+			// Literals never span lines, leave an unterminated one to the parser
+			strings_tracker = ParenTracker{}
+		}
+		in_string := strings_tracker.InString
+		strings_tracker.Update(string(r))
+		if in_string || strings_tracker.InString {
+			blank_line = false
+			stmt.append(&source, r, pos)
+			continue
+		}
+		if r == '#' {
+			// A comment line does not count as blank
+			in_comment = true
+			blank_line = false
+			continue
+		}
+		if r == '\n' && blank_line && source.Len() > 0 {
+			break
+		}
+		if r == '\n' {
+			blank_line = true
+		}
+		if (r == '\n' || r == ';') && depth <= 0 {
+			if source.Len() > 0 {
+				break
+			}
+			continue
+		}
+		if unicode.IsSpace(r) {
+			continue
+		}
+		blank_line = false
+		if source.Len() == 0 && stmt.Data == nil && (r == 'd' || r == 'D') && d.readDeclarationKeyword("ATA") {
+			stmt.Data = &DataDecl{}
+			continue
+		}
+		if r == 'λ' || r == '\\' {
+			r = 'L'
+		}
+		if r == '(' || r == '{' {
+			depth += 1
+		} else if r == ')' || r == '}' {
+			depth -= 1
+		}
+		stmt.append(&source, r, pos)
+	}
+	if source.Len() == 0 {
+		return stmt, io.EOF
+	}
+	stmt.Source = source.String()
+	return stmt, nil
+}
+
+// Bytes of input readDeclarationKeyword looks at, enough for the keyword and a type name.
+const Keyword_Lookahead = 128
+
+// Whether the input continues with rest (in any case), whitespace, a type name and =,
+// consuming rest if so. Anything else, as in DATA = 5, is left to be read as a statement.
+func (d *Decoder) readDeclarationKeyword(rest string) bool {
+	peeked, _ := d.r.Peek(Keyword_Lookahead)
+	if len(peeked) <= len(rest) || !strings.EqualFold(string(peeked[:len(rest)]), rest) ||
+		!unicode.IsSpace(rune(peeked[len(rest)])) {
+		return false
+	}
+	i := len(rest)
+	for i < len(peeked) && unicode.IsSpace(rune(peeked[i])) {
+		i += 1
+	}
+	name_start := i
+	for i < len(peeked) && (unicode.IsLetter(rune(peeked[i])) || unicode.IsDigit(rune(peeked[i])) || peeked[i] == '_') {
+		i += 1
+	}
+	for i < len(peeked) && unicode.IsSpace(rune(peeked[i])) {
+		i += 1
+	}
+	if i == name_start || i >= len(peeked) || peeked[i] != '=' {
+		return false
+	}
+	discarded, _ := d.r.Discard(len(rest))
+	d.pos.Offset += discarded
+	d.pos.Column += discarded
+	return true
+}
+
+// Add r, read at pos, to the statement's source.
+func (stmt *Statement) append(source *strings.Builder, r rune, pos Position) {
+	if source.Len() == 0 {
+		stmt.Pos = pos
+	}
+	before := source.Len()
+	source.WriteRune(r)
+	for i := before; i < source.Len(); i++ {
+		stmt.Positions = append(stmt.Positions, pos)
+	}
+}
+
+// Fold a parse result into one term, only wrapping when there is more than one.
+func SingleLExpr(lexprs []LExpr) LExpr {
+	if len(lexprs) == 1 {
+		return lexprs[0]
+	}
+	concat := ConcatenateLExprs(lexprs)
+	return &concat
+}
+
+func IsDefinitionName(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for _, r := range s {
+		if !(r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Offset of the "=" ending the name of a definition, -1 if source is a bare term. The "=" of
+// string literals and of the == operator never is: a run of "=" only starts with a definition's
+// when its length is odd (X1===(...) defines X1 as an application of ==).
+func DefinitionSplit(source string) int {
+	depth := 0
+	tracker := ParenTracker{}
+	for i, r := range source {
+		in_string := tracker.InString
+		tracker.Update(string(r))
+		if in_string || tracker.InString {
+			continue
+		}
+		run := len(source[i:]) - len(strings.TrimLeft(source[i:], "="))
+		is_operator := strings.HasSuffix(source[:i], "=") || run%2 == 0
+		switch r {
+		case '(', '{':
+			depth += 1
+		case ')', '}':
+			depth -= 1
+		case '=':
+			if is_operator {
+				continue
+			}
+			if depth == 0 && IsLetBinding(source[:i]) {
+				return -1
+			} else if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Text before "=" in a let term, L followed by a binding variable (LX1=(...).(...)), an
+// optional @ marking it recursive (L@X1=...) and an optional type annotation (LX1:T=...).
+func IsLetBinding(s string) bool {
+	s = strings.ToUpper(s)
+	if !strings.HasPrefix(s, "L") {
+		return false
+	}
+	s = "L" + strings.TrimPrefix(s[1:], "@")
+	if colon := strings.Index(s, ":"); colon > 0 {
+		s = s[:colon]
+	}
+	if len(s) < 3 {
+		return false
+	}
+	letters := strings.TrimRightFunc(s[1:], unicode.IsDigit)
+	return len(letters) > 0 && len(letters) < len(s)-1 && IsCapLetter(letters)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Sources of the statements the decoder gathers from input, before any parsing.
+func readSources(t *testing.T, input string) []string {
+	t.Helper()
+	decoder := CreateDecoder(strings.NewReader(input))
+	sources := []string{}
+	for {
+		stmt, read_err := decoder.readStatement()
+		if read_err == io.EOF {
+			return sources
+		} else if read_err != nil {
+			t.Fatalf("%q: %v", input, read_err)
+		}
+		sources = append(sources, stmt.Source)
+	}
+}
+
+func TestDecoderSplitsStatements(t *testing.T) {
+	cases := []struct {
+		input string
+		want  []string
+	}{
+		{"X1;Y1\nZ1", []string{"X1", "Y1", "Z1"}},
+		{";;X1;\n\n;Y1;", []string{"X1", "Y1"}},
+		// Inside parentheses and braces neither ; nor a newline ends the statement
+		{"F1(X1;\n  Y1)\nZ1", []string{"F1(X1;Y1)", "Z1"}},
+		{"{A=(1),\nB=(2)};Z1", []string{"{A=(1),B=(2)}", "Z1"}},
+		// Except for a blank line, so a missing ) cannot swallow the rest
+		{"F1(X1\n\nY1", []string{"F1(X1", "Y1"}},
+		// Nor inside a string literal, which keeps its whitespace
+		{"++(\"a; b\")(\"c\")", []string{"++(\"a; b\")(\"c\")"}},
+		{"λX1.(X1) \\Y1.(Y1)", []string{"LX1.(X1)LY1.(Y1)"}},
+	}
+	for _, c := range cases {
+		if got := readSources(t, c.input); strings.Join(got, "|") != strings.Join(c.want, "|") {
+			t.Errorf("%q: got %q, want %q", c.input, got, c.want)
+		}
+	}
+}
+
+func TestDecoderSkipsCommentsAndBlankLines(t *testing.T) {
+	cases := []struct {
+		input string
+		want  []string
+	}{
+		{"# a comment\n\n\nX1 # after X1\n\n  \nY1", []string{"X1", "Y1"}},
+		// A comment line inside parentheses is not a blank line
+		{"F1(X1\n# between\nY1)", []string{"F1(X1Y1)"}},
+		{"\"#not a comment\"#one", []string{"\"#not a comment\""}},
+		{"# only a comment", []string{}},
+		{"", []string{}},
+	}
+	for _, c := range cases {
+		if got := readSources(t, c.input); strings.Join(got, "|") != strings.Join(c.want, "|") {
+			t.Errorf("%q: got %q, want %q", c.input, got, c.want)
+		}
+	}
+}
+
+func TestStatementPositions(t *testing.T) {
+	stmts := decodeAll(t, "  X1 = LY1.(Y1)\n  # skipped\n\t\"é\" ; Z1")
+	if len(stmts) != 3 {
+		t.Fatalf("got %v statements, want 3", len(stmts))
+	}
+	definition, literal, last := stmts[0], stmts[1], stmts[2]
+	if want := (Position{Line: 1, Column: 3, Offset: 2}); definition.Pos != want {
+		t.Errorf("X1 at %+v, want %+v", definition.Pos, want)
+	}
+	if len(definition.Positions) != len(definition.Source) {
+		t.Errorf("%v positions for %q", len(definition.Positions), definition.Source)
+	}
+	// Locate goes by offsets in the body, LY1.(Y1) after "X1="
+	if want := (Position{Line: 1, Column: 8, Offset: 7}); definition.Locate(0) != want {
+		t.Errorf("body starts at %+v, want %+v", definition.Locate(0), want)
+	}
+	if want := (Position{Line: 1, Column: 16, Offset: 15}); definition.Locate(len("LY1.(Y1)")) != want {
+		t.Errorf("end of the body at %+v, want %+v", definition.Locate(len("LY1.(Y1)")), want)
+	}
+	// Every byte of é is at the rune's position
+	if len(literal.Positions) != 4 || literal.Positions[1] != literal.Positions[2] || literal.Positions[3].Column != 4 {
+		t.Errorf("got %+v for %q", literal.Positions, literal.Source)
+	}
+	if want := (Position{Line: 3, Column: 8, Offset: 36}); last.Pos != want {
+		t.Errorf("Z1 at %+v, want %+v", last.Pos, want)
+	}
+}
+
+func TestDecoderPositionsErrors(t *testing.T) {
+	decoder := CreateDecoder(strings.NewReader("Y1\nZ1 = (X1\n\nX1"))
+	if _, decode_err := decoder.Decode(); decode_err != nil {
+		t.Fatalf("%v", decode_err)
+	}
+	_, decode_err := decoder.Decode()
+	var parse_err *ParseError
+	if !errors.As(decode_err, &parse_err) {
+		t.Fatalf("got %v, want a *ParseError", decode_err)
+	}
+	if want := (Position{Line: 2, Column: 6, Offset: 8}); parse_err.Pos != want {
+		t.Errorf("unclosed ( at %+v, want %+v", parse_err.Pos, want)
+	}
+	// The blank line ends the broken statement, and the next one still decodes
+	if stmt, next_err := decoder.Decode(); next_err != nil || stmt.Source != "X1" {
+		t.Errorf("got %q and %v after the error, want X1", stmt.Source, next_err)
+	}
+}
+
+func TestDefinitionSplit(t *testing.T) {
+	cases := []struct {
+		source string
+		want   int
+	}{
+		{"X1=Y1", 2},
+		{"F_1=LX1.(X1)", 3},
+		{"LX1.(X1)", -1},
+		// Let bindings are terms
+		{"LX1=(Y1).(X1)", -1},
+		{"L@F1=(F1).(F1)", -1},
+		{"LX1:INT=(1).(X1)", -1},
+		// == is an operator, and an odd run of = starts with a definition's
+		{"==(1)(2)", -1},
+		{"X1===(1)(2)", 2},
+		{"\"a=b\"", -1},
+		{"F1(X1=Y1)", -1},
+		{"{A=(1)}", -1},
+	}
+	for _, c := range cases {
+		if got := DefinitionSplit(c.source); got != c.want {
+			t.Errorf("DefinitionSplit(%q) = %v, want %v", c.source, got, c.want)
+		}
+	}
+}
+
+func TestIsLetBinding(t *testing.T) {
+	cases := map[string]bool{
+		"LX1":     true,
+		"lx1":     true,
+		"L@F1":    true,
+		"LX1:INT": true,
+		"LXY12":   true,
+		"LX":      false,
+		"L1":      false,
+		"L@":      false,
+		"X1":      false,
+		"LL1":     false,
+	}
+	for s, want := range cases {
+		if got := IsLetBinding(s); got != want {
+			t.Errorf("IsLetBinding(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestDecoderRecoversEveryError(t *testing.T) {
+	decoder := CreateDecoder(strings.NewReader("X1?Y1!LZ1.(Z1)\nLX1.(X1)"))
+	decoder.Recover = true
+	stmt, decode_err := decoder.Decode()
+	var errs ParseErrorList
+	if !errors.As(decode_err, &errs) || len(errs) != 2 {
+		t.Fatalf("got %v, want two errors", decode_err)
+	}
+	for i, column := range []int{3, 6} {
+		if errs[i].Pos.Line != 1 || errs[i].Pos.Column != column {
+			t.Errorf("error %v at %+v, want column %v", i, errs[i].Pos, column)
+		}
+	}
+	if stmt.Expr == nil || !strings.Contains(stmt.Expr.LPrint(), "<error>") {
+		t.Errorf("want a partial term with errors in it, got %v", stmt.Expr)
+	}
+	if next, next_err := decoder.Decode(); next_err != nil || next.Source != "LX1.(X1)" {
+		t.Errorf("got %q and %v after the errors, want LX1.(X1)", next.Source, next_err)
+	}
+}
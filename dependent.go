@@ -0,0 +1,508 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+	Dependent types - the lambda-Pi calculus with universes
+
+Types are terms. ΠX1:A.(B) is the type of functions taking an X1 of type A to a B that may
+mention X1, and the universes * (also *0), *1, *2, ... are the types of types, *n having type
+*n+1. A Π lives in the larger universe of its domain and codomain, and universes are not
+cumulative: a type in * is not also in *1. Binder annotations are
+read as terms (LVar.Domain), so λX1:NAT1., ΠA1:*. and λH1:P1X1. all work; an annotation
+containing a "." needs parentheses: λF1:(ΠX1:A1.(A1)).
+
+The checker is bidirectional: Infer synthesizes the type of variables, annotated lambdas,
+Π, universes and applications, and Check pushes a known Π type into a lambda, so lambdas
+in argument position need no annotation. Types are kept as Core in which Π A (λX.B) and the
+universes are encoded with the free constants "Π" and "*n" (see Lower); two types are
+definitionally equal when their normal forms under normal order reduction are
+alpha-equivalent. Globals (earlier definitions) are unfolded before normalizing.
+
+Let is checked like the application it lowers to, so the body does not see the value of
+the bound variable, only its type.
+*/
+type LPi struct {
+	Binding LVar
+	Body    LExpr
+}
+
+type LUniverse struct {
+	Level int
+}
+
+func (l *LPi) LPrint() string {
+	return "Π" + l.Binding.BindingPrint() + "." + parenthesized(l.Body)
+}
+
+func (l *LPi) LAbstract(b LVar) LExpr {
+	out_str := "λ" + b.BindingPrint() + ".(" + l.LPrint() + ")"
+	return &LExpression{
+		Binding: b,
+		Exprs:   []LExpr{l},
+		Repr:    out_str,
+	}
+}
+
+func (l *LPi) Copy() LExpr {
+	return &LPi{Binding: l.Binding, Body: l.Body.Copy()}
+}
+
+func (l *LPi) LApply(b LVar, replace LExpr) LExpr {
+	body := l.Body.Copy()
+	if l.Binding.Symbol != b.Symbol {
+		body = l.Body.LApply(b, replace)
+	}
+	return &LPi{Binding: l.Binding, Body: body}
+}
+
+func (l *LPi) LEquals(l2 LExpr) bool {
+	return l.LPrint() == l2.LPrint()
+}
+
+func (l *LUniverse) LPrint() string {
+	return UniverseName(l.Level)
+}
+
+func (l *LUniverse) LAbstract(b LVar) LExpr {
+	out_str := "λ" + b.BindingPrint() + ".(" + l.LPrint() + ")"
+	return &LExpression{
+		Binding: b,
+		Exprs:   []LExpr{l},
+		Repr:    out_str,
+	}
+}
+
+func (l *LUniverse) Copy() LExpr {
+	return &LUniverse{Level: l.Level}
+}
+
+func (l *LUniverse) LApply(b LVar, replace LExpr) LExpr {
+	return l.Copy()
+}
+
+func (l *LUniverse) LEquals(l2 LExpr) bool {
+	return l.LPrint() == l2.LPrint()
+}
+
+// Name of universe level as written and as its Core constant, "*" for level 0.
+func UniverseName(level int) string {
+	if level == 0 {
+		return "*"
+	}
+	return "*" + strconv.Itoa(level)
+}
+
+const Pi_Constant = "Π"
+
+// Core encoding of ΠX:domain.codomain, codomain being under the binder.
+func CorePi(name string, domain Core, codomain Core) Core {
+	return CoreApply(&CFree{Name: Pi_Constant}, domain, &CLam{Name: name, Body: codomain})
+}
+
+// Domain and codomain binder of an encoded Π.
+func DecodePi(c Core) (Core, *CLam, bool) {
+	head, args := CoreSpine(c)
+	free, is_free := head.(*CFree)
+	if !is_free || free.Name != Pi_Constant || len(args) != 2 {
+		return nil, nil, false
+	}
+	codomain, is_lam := args[1].(*CLam)
+	return args[0], codomain, is_lam
+}
+
+func DecodeUniverse(c Core) (int, bool) {
+	free, is_free := c.(*CFree)
+	if !is_free || !strings.HasPrefix(free.Name, "*") {
+		return 0, false
+	}
+	if free.Name == "*" {
+		return 0, true
+	}
+	level, atoi_err := strconv.Atoi(free.Name[1:])
+	return level, atoi_err == nil
+}
+
+// Closed definition available to later terms under its name.
+type DependentGlobal struct {
+	Type  Core
+	Value Core
+}
+
+type depBinding struct {
+	Name string
+	// Valid in the context of the bindings before it
+	Type Core
+}
+
+type DependentChecker struct {
+	// Spans recorded by the parser, used to locate errors
+	Spans   map[LExpr]Span
+	Globals map[string]DependentGlobal
+	// Normal order steps allowed for each conversion check
+	MaxSteps int
+}
+
+func CreateDependentChecker(spans map[LExpr]Span, globals map[string]DependentGlobal) *DependentChecker {
+	if globals == nil {
+		globals = map[string]DependentGlobal{}
+	}
+	return &DependentChecker{Spans: spans, Globals: globals, MaxSteps: 100000}
+}
+
+// Type of l, in normal form, or a *TypeError.
+func (dc *DependentChecker) Infer(l LExpr) (Core, error) {
+	return dc.infer(l, []depBinding{}, Span{})
+}
+
+// Check l against the type t, given as a term.
+func (dc *DependentChecker) Check(l LExpr, t LExpr) error {
+	expected, type_err := dc.checkType(t, []depBinding{}, Span{})
+	if type_err != nil {
+		return type_err
+	}
+	return dc.check(l, expected, []depBinding{}, Span{})
+}
+
+func (dc *DependentChecker) span(l LExpr, outer Span) Span {
+	if span, found := dc.Spans[l]; found {
+		return span
+	}
+	return outer
+}
+
+func scopeNames(ctx []depBinding) []string {
+	names := []string{}
+	for _, binding := range ctx {
+		names = append(names, binding.Name)
+	}
+	return names
+}
+
+// Replace the globals in c by their values.
+func (dc *DependentChecker) unfold(c Core) Core {
+	switch node := c.(type) {
+	case *CFree:
+		if global, found := dc.Globals[node.Name]; found {
+			return global.Value
+		}
+	case *CLam:
+		return &CLam{Name: node.Name, Body: dc.unfold(node.Body)}
+	case *CApp:
+		return &CApp{Fun: dc.unfold(node.Fun), Arg: dc.unfold(node.Arg)}
+	}
+	return c
+}
+
+func (dc *DependentChecker) normalize(c Core, l LExpr, span Span) (Core, error) {
+	nf, _, reduce_err := Reduce(dc.unfold(c), R_NormalOrder, dc.MaxSteps)
+	if reduce_err != nil {
+		return nil, &TypeError{Kind: TY_Malformed, Span: span, Term: l.LPrint(),
+			Message: fmt.Sprintf("normalizing a type of %v: %v", l.LPrint(), reduce_err)}
+	}
+	return nf, nil
+}
+
+// l as a normalized Core term in ctx.
+func (dc *DependentChecker) evaluate(l LExpr, ctx []depBinding, span Span) (Core, error) {
+	c, lower_err := lower(l, scopeNames(ctx))
+	if lower_err != nil {
+		return nil, &TypeError{Kind: TY_Malformed, Span: span, Term: l.LPrint(), Message: lower_err.Error()}
+	}
+	return dc.normalize(c, l, span)
+}
+
+// Print a Core type of ctx as a term.
+func (dc *DependentChecker) show(c Core, ctx []depBinding) string {
+	used := map[string]bool{}
+	CoreFreeNames(c, used)
+	names := scopeNames(ctx)
+	for _, name := range names {
+		used[name] = true
+	}
+	return RaiseDependent(c, names, used).LPrint()
+}
+
+// Check that l is a type, i.e. that its type is a universe, and return it normalized.
+func (dc *DependentChecker) checkType(l LExpr, ctx []depBinding, outer Span) (Core, error) {
+	_, level_err := dc.universeOf(l, ctx, outer)
+	if level_err != nil {
+		return nil, level_err
+	}
+	return dc.evaluate(l, ctx, dc.span(l, outer))
+}
+
+// Level of the universe l is a type in.
+func (dc *DependentChecker) universeOf(l LExpr, ctx []depBinding, outer Span) (int, error) {
+	t, infer_err := dc.infer(l, ctx, outer)
+	if infer_err != nil {
+		return 0, infer_err
+	}
+	level, is_universe := DecodeUniverse(t)
+	if !is_universe {
+		return 0, &TypeError{Kind: TY_NotType, Span: dc.span(l, outer), Term: l.LPrint(),
+			Message: fmt.Sprintf("%v is used as a type but has type %v, not a universe", l.LPrint(), dc.show(t, ctx))}
+	}
+	return level, nil
+}
+
+func (dc *DependentChecker) infer(l LExpr, ctx []depBinding, outer Span) (Core, error) {
+	span := dc.span(l, outer)
+	switch node := l.(type) {
+	case *LVar:
+		for i := len(ctx) - 1; i >= 0; i-- {
+			if ctx[i].Name == node.Symbol {
+				return Shift(ctx[i].Type, len(ctx)-i, 0), nil
+			}
+		}
+		if global, found := dc.Globals[node.Symbol]; found {
+			return global.Type, nil
+		}
+		return nil, &TypeError{Kind: TY_Unbound, Span: span, Term: node.Symbol,
+			Message: fmt.Sprintf("%v is not bound and is not a definition", node.Symbol)}
+	case *LUniverse:
+		return &CFree{Name: UniverseName(node.Level + 1)}, nil
+	case *LPi:
+		if node.Binding.Domain == nil {
+			return nil, dc.unannotated(node.Binding, node, span)
+		}
+		domain_level, domain_err := dc.universeOf(node.Binding.Domain, ctx, span)
+		if domain_err != nil {
+			return nil, domain_err
+		}
+		domain, eval_err := dc.evaluate(node.Binding.Domain, ctx, span)
+		if eval_err != nil {
+			return nil, eval_err
+		}
+		inner_ctx := append(ctx[:len(ctx):len(ctx)], depBinding{Name: node.Binding.Symbol, Type: domain})
+		body_level, body_err := dc.universeOf(node.Body, inner_ctx, span)
+		if body_err != nil {
+			return nil, body_err
+		}
+		return &CFree{Name: UniverseName(max(domain_level, body_level))}, nil
+	case *LExpression:
+		if len(node.Binding.Symbol) == 0 {
+			return dc.inferSeq(node, node.Exprs, ctx, span)
+		}
+		if node.Binding.Domain == nil {
+			return nil, dc.unannotated(node.Binding, node, span)
+		}
+		domain, domain_err := dc.checkType(node.Binding.Domain, ctx, span)
+		if domain_err != nil {
+			return nil, domain_err
+		}
+		inner_ctx := append(ctx[:len(ctx):len(ctx)], depBinding{Name: node.Binding.Symbol, Type: domain})
+		body, body_err := dc.inferSeq(node, node.Exprs, inner_ctx, span)
+		if body_err != nil {
+			return nil, body_err
+		}
+		return CorePi(node.Binding.Symbol, domain, body), nil
+	case *LLet:
+		if node.Recursive {
+			// General recursion inhabits every type, which would make the checker prove anything
+			return nil, &TypeError{Kind: TY_Malformed, Span: span, Term: l.LPrint(),
+				Message: fmt.Sprintf("recursive binding %v cannot be checked with dependent types, "+
+					"it would inhabit every type", node.Binding.Symbol)}
+		}
+		bound, bound_err := dc.infer(node.Bound, ctx, span)
+		if bound_err != nil {
+			return nil, bound_err
+		}
+		inner_ctx := append(ctx[:len(ctx):len(ctx)], depBinding{Name: node.Binding.Symbol, Type: bound})
+		body, body_err := dc.infer(node.Body, inner_ctx, span)
+		if body_err != nil {
+			return nil, body_err
+		}
+		value, value_err := dc.evaluate(node.Bound, ctx, span)
+		if value_err != nil {
+			return nil, value_err
+		}
+		return dc.normalize(Beta(body, value), l, span)
+	}
+	return nil, &TypeError{Kind: TY_Malformed, Span: span, Term: l.LPrint(),
+		Message: fmt.Sprintf("cannot type %v with dependent types", l.LPrint())}
+}
+
+func (dc *DependentChecker) unannotated(binding LVar, l LExpr, span Span) *TypeError {
+	return &TypeError{Kind: TY_Unannotated, Span: span, Term: l.LPrint(),
+		Message: fmt.Sprintf("the type of %v in %v cannot be inferred, annotate it with a term (%v:A.)",
+			binding.Symbol, l.LPrint(), binding.Symbol)}
+}
+
+// Left-nested application of exprs, the body of node.
+func (dc *DependentChecker) inferSeq(node LExpr, exprs []LExpr, ctx []depBinding, span Span) (Core, error) {
+	if len(exprs) == 0 {
+		return nil, &TypeError{Kind: TY_Malformed, Span: span, Term: node.LPrint(), Message: "empty expression"}
+	}
+	fun_type, fun_err := dc.infer(exprs[0], ctx, span)
+	if fun_err != nil {
+		return nil, fun_err
+	}
+	fun_text := exprs[0].LPrint()
+	fun_span := dc.span(exprs[0], span)
+	for _, arg := range exprs[1:] {
+		domain, codomain, is_pi := DecodePi(fun_type)
+		if !is_pi {
+			return nil, &TypeError{Kind: TY_NotFunction, Span: fun_span, Term: fun_text,
+				Message: fmt.Sprintf("%v has type %v and cannot be applied to %v", fun_text, dc.show(fun_type, ctx), arg.LPrint())}
+		}
+		if arg_err := dc.check(arg, domain, ctx, span); arg_err != nil {
+			return nil, arg_err
+		}
+		value, value_err := dc.evaluate(arg, ctx, span)
+		if value_err != nil {
+			return nil, value_err
+		}
+		result, result_err := dc.normalize(Beta(codomain.Body, value), node, span)
+		if result_err != nil {
+			return nil, result_err
+		}
+		fun_type = result
+		fun_text += arg.LPrint()
+		arg_span := dc.span(arg, span)
+		fun_span = Span{Offset: fun_span.Offset, End: max(fun_span.End, arg_span.End)}
+	}
+	return fun_type, nil
+}
+
+// Check l against expected, a normalized type of ctx.
+func (dc *DependentChecker) check(l LExpr, expected Core, ctx []depBinding, outer Span) error {
+	span := dc.span(l, outer)
+	if node, is_lam := l.(*LExpression); is_lam && len(node.Binding.Symbol) > 0 {
+		if domain, codomain, is_pi := DecodePi(expected); is_pi {
+			if node.Binding.Domain != nil {
+				annotated, domain_err := dc.checkType(node.Binding.Domain, ctx, span)
+				if domain_err != nil {
+					return domain_err
+				}
+				if annotated.CPrint() != domain.CPrint() {
+					return &TypeError{Kind: TY_Mismatch, Span: dc.span(node.Binding.Domain, span), Term: node.LPrint(),
+						Message: fmt.Sprintf("%v is annotated %v but is checked against %v",
+							node.Binding.Symbol, dc.show(annotated, ctx), dc.show(expected, ctx))}
+				}
+			}
+			inner_ctx := append(ctx[:len(ctx):len(ctx)], depBinding{Name: node.Binding.Symbol, Type: domain})
+			if len(node.Exprs) == 1 {
+				return dc.check(node.Exprs[0], codomain.Body, inner_ctx, span)
+			}
+			found, body_err := dc.inferSeq(node, node.Exprs, inner_ctx, span)
+			if body_err != nil {
+				return body_err
+			}
+			return dc.compare(node, found, codomain.Body, inner_ctx, span)
+		}
+	}
+	if node, is_seq := l.(*LExpression); is_seq && len(node.Binding.Symbol) == 0 && len(node.Exprs) == 1 {
+		return dc.check(node.Exprs[0], expected, ctx, span)
+	}
+	return dc.convert(l, expected, ctx, span)
+}
+
+// Infer the type of l and compare it with expected.
+func (dc *DependentChecker) convert(l LExpr, expected Core, ctx []depBinding, span Span) error {
+	found, infer_err := dc.infer(l, ctx, span)
+	if infer_err != nil {
+		return infer_err
+	}
+	return dc.compare(l, found, expected, ctx, span)
+}
+
+// Definitional equality of two normalized types, found being the type of l.
+func (dc *DependentChecker) compare(l LExpr, found Core, expected Core, ctx []depBinding, span Span) error {
+	if found.CPrint() != expected.CPrint() {
+		return &TypeError{Kind: TY_Mismatch, Span: dc.span(l, span), Term: l.LPrint(),
+			Message: fmt.Sprintf("%v has type %v but %v is expected", l.LPrint(), dc.show(found, ctx), dc.show(expected, ctx))}
+	}
+	return nil
+}
+
+// Read an encoded Core type back as a term, with Π and universes in their own syntax. scope
+// names the free de Bruijn indices of c.
+func RaiseDependent(c Core, scope []string, used map[string]bool) LExpr {
+	if domain, codomain, is_pi := DecodePi(c); is_pi {
+		raised_domain := RaiseDependent(domain, scope, used)
+		name := FreshName(codomain.Name, used)
+		used[name] = true
+		body := RaiseDependent(codomain.Body, append(scope[:len(scope):len(scope)], name), used)
+		delete(used, name)
+		return &LPi{Binding: LVar{Symbol: name, Domain: raised_domain}, Body: body}
+	}
+	if level, is_universe := DecodeUniverse(c); is_universe {
+		return &LUniverse{Level: level}
+	}
+	switch node := c.(type) {
+	case *CLam:
+		name := FreshName(node.Name, used)
+		used[name] = true
+		body := RaiseDependent(node.Body, append(scope[:len(scope):len(scope)], name), used)
+		delete(used, name)
+		return body.LAbstract(LVar{Symbol: name})
+	case *CApp:
+		head, args := CoreSpine(c)
+		lexprs := []LExpr{RaiseDependent(head, scope, used)}
+		for _, arg := range args {
+			lexprs = append(lexprs, RaiseDependent(arg, scope, used))
+		}
+		concat := ConcatenateLExprs(lexprs)
+		return &concat
+	}
+	return raiseAtom(c, scope)
+}
+
+// Definition names usable as variables in later statements (letters then digits, no L).
+func IsVariableName(s string) bool {
+	letters := strings.TrimRight(s, "0123456789")
+	return len(letters) > 0 && len(letters) < len(s) && IsCapLetter(letters)
+}
+
+// Type of a decoded statement, positioning any error in the decoded input. A definition
+// named like a variable is added to globals for the statements after it.
+func CheckDependentStatement(stmt Statement, globals map[string]DependentGlobal) (LExpr, error) {
+	dc := CreateDependentChecker(stmt.Spans, globals)
+	t, check_err := dc.Infer(stmt.Expr)
+	if type_err, is_type_err := check_err.(*TypeError); is_type_err {
+		located := *type_err
+		located.Pos = stmt.Locate(located.Span.Offset)
+		return nil, &located
+	} else if check_err != nil {
+		return nil, check_err
+	}
+	if IsVariableName(stmt.Name) {
+		value, lower_err := Lower(stmt.Expr)
+		if lower_err != nil {
+			return nil, lower_err
+		}
+		globals[stmt.Name] = DependentGlobal{Type: t, Value: dc.unfold(value)}
+	}
+	used := map[string]bool{}
+	CoreFreeNames(t, used)
+	return RaiseDependent(t, []string{}, used), nil
+}
+
+// Statements checked by "lambda pi" when no file is given. Each definition named like a
+// variable can be used by the ones after it.
+const Pi_Examples_Source = `
+# Polymorphism is a Π over a universe
+ID1 = LA1:*.(LX1:A1.(X1))
+IDTYPE1 = ΠA1:*.(ΠX1:A1.(A1))
+
+# Church numerals, with the type of the result as an explicit argument
+NAT1 = ΠA1:*.(ΠF1:(ΠX1:A1.(A1)).(ΠX1:A1.(A1)))
+ZERO1 = LA1:*.(LF1:(ΠX1:A1.(A1)).(LX1:A1.(X1)))
+SUCC1 = LN1:NAT1.(LA1:*.(LF1:(ΠX1:A1.(A1)).(LX1:A1.(F1(N1A1F1X1)))))
+ADD1 = LM1:NAT1.(LN1:NAT1.(LA1:*.(LF1:(ΠX1:A1.(A1)).(LX1:A1.(M1A1F1(N1A1F1X1))))))
+ONE1 = SUCC1ZERO1
+TWO1 = SUCC1ONE1
+
+# Leibniz equality: X1 = Y1 when every property of X1 holds of Y1, and its reflexivity.
+# NAT1 quantifies over *, so it is itself in *1. Variables cannot contain L, hence REFN1
+EQ1 = LA1:*1.(LX1:A1.(LY1:A1.(ΠP1:(ΠZ1:A1.(*)).(ΠH1:P1X1.(P1Y1)))))
+REFN1 = LA1:*1.(LX1:A1.(LP1:(ΠZ1:A1.(*)).(LH1:P1X1.(H1))))
+
+# 1 + 1 = 2 holds by computation: the conversion check normalizes both sides
+PROOF1 = (LE1:EQ1NAT1(ADD1ONE1ONE1)TWO1.(E1))(REFN1NAT1TWO1)
+`
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// Check the statements of source in order, later ones seeing the definitions of earlier ones,
+// returning the type and error of each.
+func checkDependent(t *testing.T, source string) ([]LExpr, []error) {
+	t.Helper()
+	globals := map[string]DependentGlobal{}
+	types, errs := []LExpr{}, []error{}
+	for _, stmt := range decodeAll(t, source) {
+		got, check_err := CheckDependentStatement(stmt, globals)
+		types = append(types, got)
+		errs = append(errs, check_err)
+	}
+	return types, errs
+}
+
+func TestDependentExamples(t *testing.T) {
+	stmts := decodeAll(t, Pi_Examples_Source)
+	_, errs := checkDependent(t, Pi_Examples_Source)
+	for i, check_err := range errs {
+		if check_err != nil {
+			t.Errorf("%v: %v", stmts[i].Name, check_err)
+		}
+	}
+	if last := stmts[len(stmts)-1]; last.Name != "PROOF1" {
+		t.Errorf("examples end with %v, not PROOF1", last.Name)
+	}
+}
+
+func TestDependentRejections(t *testing.T) {
+	// 1 + 1 = 1 does not convert to the 1 + 1 = 2 that REFN1 NAT1 TWO1 proves
+	_, errs := checkDependent(t, Pi_Examples_Source+"(LE1:EQ1NAT1(ADD1ONE1ONE1)ONE1.(E1))(REFN1NAT1TWO1)\n")
+	var type_err *TypeError
+	if last := errs[len(errs)-1]; !errors.As(last, &type_err) || type_err.Kind != TY_Mismatch {
+		t.Errorf("false equality: got %v, want a mismatch", last)
+	}
+	_, errs = checkDependent(t, "LA1:*.(LX1:A1.(X1X1))\n")
+	if !errors.As(errs[0], &type_err) || type_err.Kind != TY_NotFunction {
+		t.Errorf("applying a non-Π: got %v, want not a function", errs[0])
+	}
+}
+
+func TestUniverses(t *testing.T) {
+	types, errs := checkDependent(t, "*\n*1\nΠA1:*.(A1)\nΠA1:*1.(A1)\n")
+	for i, want := range []string{"*1", "*2", "*1", "*2"} {
+		if errs[i] != nil {
+			t.Errorf("statement %v: %v", i+1, errs[i])
+		} else if types[i].LPrint() != want {
+			t.Errorf("statement %v: got %v, want %v", i+1, types[i].LPrint(), want)
+		}
+	}
+}
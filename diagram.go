@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+/*
+	TransitionDiagram - static picture of a TransitionExecutor
+
+Mappers are opaque functions, so edges are discovered by probing every mapper with one
+sample character per CharClass. Closing parentheses are probed twice since P_i and LP1
+only leave on a ")" that brings the ParenTracker back to 0. Mappers are probed with Records
+set, so the diagram includes the states of record literals, projections and tags.
+*/
+type CharClass struct {
+	Label  string
+	Sample string
+	// ParenTracker counter to present to the mapper, including Sample
+	Depth int
+}
+
+var Diagram_Char_Classes = []CharClass{
+	{Label: "L", Sample: "L", Depth: 0},
+	{Label: "A-Z", Sample: "A", Depth: 0},
+	{Label: "0-9", Sample: "1", Depth: 0},
+	{Label: ".", Sample: ".", Depth: 0},
+	{Label: ":", Sample: ":", Depth: 0},
+	{Label: "=", Sample: "=", Depth: 0},
+	{Label: "Λ", Sample: "Λ", Depth: 0},
+	{Label: "Π", Sample: "Π", Depth: 0},
+	{Label: "*", Sample: "*", Depth: 0},
+	{Label: "[", Sample: "[", Depth: 0},
+	{Label: "]", Sample: "]", Depth: 0},
+	{Label: "+ - <", Sample: "+", Depth: 0},
+	{Label: "~", Sample: "~", Depth: 0},
+	{Label: "@", Sample: "@", Depth: 0},
+	{Label: "\"", Sample: "\"", Depth: 0},
+	{Label: "{", Sample: "{", Depth: 0},
+	{Label: "}", Sample: "}", Depth: 0},
+	{Label: ",", Sample: ",", Depth: 0},
+	{Label: "'", Sample: "'", Depth: 0},
+	{Label: "(", Sample: "(", Depth: 1},
+	{Label: ") closing", Sample: ")", Depth: 0},
+	{Label: ") nested", Sample: ")", Depth: 1},
+	{Label: "other", Sample: "?", Depth: 0},
+}
+
+const Diagram_EOF_Label = "EOF"
+
+type DiagramEdge struct {
+	From      ParserState
+	To        ParserState
+	Labels    []string
+	Callbacks []string
+}
+
+type TransitionDiagram struct {
+	States []ParserState
+	Edges  []DiagramEdge
+	// States never entered when starting from I_i
+	Unreachable []ParserState
+	// Keys in TransitionCallbackMap matching no edge, i.e. callbacks that can never fire
+	UnusedCallbacks []Transition
+}
+
+func (t *TransitionExecutor) Diagram() TransitionDiagram {
+	// DUMMY is the wildcard of callback keys, never a state the parser is in
+	diagram := TransitionDiagram{}
+	for _, state := range All_Parser_States {
+		if state != DUMMY {
+			diagram.States = append(diagram.States, state)
+		}
+	}
+	edge_index := map[Transition]int{}
+	add_edge := func(ts Transition, label string) {
+		i, found := edge_index[ts]
+		if !found {
+			callbacks := []string{}
+			for _, cb := range t.FilterCallbacks(ts) {
+				callbacks = append(callbacks, CallbackName(cb))
+			}
+			diagram.Edges = append(diagram.Edges, DiagramEdge{From: ts.S_i, To: ts.S_f, Callbacks: callbacks})
+			i = len(diagram.Edges) - 1
+			edge_index[ts] = i
+		}
+		diagram.Edges[i].Labels = append(diagram.Edges[i].Labels, label)
+	}
+	for _, state := range All_Parser_States {
+		mapper, found := t.TransitionMap[state]
+		if !found {
+			continue
+		}
+		for _, class := range Diagram_Char_Classes {
+			p := Parser_Init()
+			p.TState = Transition{S_i: DUMMY, S_f: state}
+			p.Records = true
+			p.NestTracker.Counter = class.Depth
+			next, err := mapper(p, class.Sample)
+			if err != nil {
+				continue
+			}
+			add_edge(next, class.Label)
+		}
+		if state.IsTerminal() {
+			add_edge(Transition{S_i: state, S_f: E_0}, Diagram_EOF_Label)
+		}
+	}
+	// Reachability from I_i
+	reached := map[ParserState]bool{I_i: true}
+	frontier := []ParserState{I_i}
+	for len(frontier) > 0 {
+		state := frontier[0]
+		frontier = frontier[1:]
+		for _, edge := range diagram.Edges {
+			if edge.From == state && !reached[edge.To] {
+				reached[edge.To] = true
+				frontier = append(frontier, edge.To)
+			}
+		}
+	}
+	for _, state := range diagram.States {
+		if !reached[state] {
+			diagram.Unreachable = append(diagram.Unreachable, state)
+		}
+	}
+	for key := range t.TransitionCallbackMap {
+		used := slices.ContainsFunc(diagram.Edges, func(edge DiagramEdge) bool {
+			return (key.S_i == DUMMY || key.S_i == edge.From) && (key.S_f == DUMMY || key.S_f == edge.To)
+		})
+		if !used {
+			diagram.UnusedCallbacks = append(diagram.UnusedCallbacks, key)
+		}
+	}
+	slices.SortFunc(diagram.UnusedCallbacks, func(a, b Transition) int {
+		if a.S_i != b.S_i {
+			return int(a.S_i) - int(b.S_i)
+		}
+		return int(a.S_f) - int(b.S_f)
+	})
+	return diagram
+}
+
+func (e DiagramEdge) Label() string {
+	label := strings.Join(e.Labels, ", ")
+	if len(e.Callbacks) > 0 {
+		label += " / " + strings.Join(e.Callbacks, ", ")
+	}
+	return label
+}
+
+// Graphviz rendering. Terminal states are double circles, unreachable states are greyed out.
+func (d TransitionDiagram) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph ParserState {\n\trankdir=LR;\n\tstart [shape=point];\n")
+	for _, state := range d.States {
+		attrs := []string{"shape=circle"}
+		if state.IsTerminal() || state == E_0 {
+			attrs[0] = "shape=doublecircle"
+		}
+		if slices.Contains(d.Unreachable, state) {
+			attrs = append(attrs, "style=dashed", "color=gray", "fontcolor=gray")
+		}
+		fmt.Fprintf(&b, "\t%v [%v];\n", state.ToString(), strings.Join(attrs, ", "))
+	}
+	b.WriteString("\tstart -> I_i;\n")
+	for _, edge := range d.Edges {
+		fmt.Fprintf(&b, "\t%v -> %v [label=%q];\n", edge.From.ToString(), edge.To.ToString(), edge.Label())
+	}
+	for _, key := range d.UnusedCallbacks {
+		fmt.Fprintf(&b, "\t// callbacks on %v -> %v never fire\n", key.S_i.ToString(), key.S_f.ToString())
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid stateDiagram-v2 rendering, with unreachable states and dead callbacks as notes.
+func (d TransitionDiagram) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("stateDiagram-v2\n\t[*] --> I_i\n")
+	for _, edge := range d.Edges {
+		fmt.Fprintf(&b, "\t%v --> %v : %v\n", edge.From.ToString(), edge.To.ToString(),
+			strings.ReplaceAll(edge.Label(), ":", "#colon;"))
+	}
+	b.WriteString("\tE_0 --> [*]\n")
+	for _, state := range d.Unreachable {
+		fmt.Fprintf(&b, "\tnote right of %v : unreachable from I_i\n", state.ToString())
+	}
+	for _, key := range d.UnusedCallbacks {
+		// Notes create the state they are attached to, so never attach one to DUMMY
+		anchor := key.S_f
+		if anchor == DUMMY {
+			anchor = key.S_i
+		}
+		fmt.Fprintf(&b, "\tnote left of %v : callbacks keyed on %v -> %v never fire\n",
+			anchor.ToString(), key.S_i.ToString(), key.S_f.ToString())
+	}
+	return b.String()
+}
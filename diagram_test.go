@@ -0,0 +1,54 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDiagramCoversEveryState(t *testing.T) {
+	executor := TransitionExecutor_Init()
+	diagram := executor.Diagram()
+	for _, state := range diagram.Unreachable {
+		t.Errorf("state %v is unreachable from I_i", state.ToString())
+	}
+	for _, key := range diagram.UnusedCallbacks {
+		t.Errorf("callbacks on %v -> %v never fire", key.S_i.ToString(), key.S_f.ToString())
+	}
+}
+
+func TestDiagramNeverDrawsDummy(t *testing.T) {
+	executor := TransitionExecutor_Init()
+	diagram := executor.Diagram()
+	// Force notes onto the diagram, including callbacks keyed on the wildcard
+	diagram.Unreachable = append(diagram.Unreachable, LT)
+	diagram.UnusedCallbacks = append(diagram.UnusedCallbacks,
+		Transition{S_i: LT, S_f: DUMMY}, Transition{S_i: DUMMY, S_f: LT})
+	for name, rendered := range map[string]string{"DOT": diagram.DOT(), "Mermaid": diagram.Mermaid()} {
+		for _, line := range strings.Split(rendered, "\n") {
+			if strings.Contains(line, "DUMMY") && !strings.Contains(line, "never fire") {
+				t.Errorf("%v draws DUMMY: %q", name, line)
+			}
+			if strings.Contains(line, "note") && strings.Contains(line, "of DUMMY") {
+				t.Errorf("%v attaches a note to DUMMY: %q", name, line)
+			}
+		}
+	}
+}
+
+// Negative literals start a number wherever a new expression can start.
+func TestDiagramReachesNumbersThroughNegativeSign(t *testing.T) {
+	executor := TransitionExecutor_Init()
+	diagram := executor.Diagram()
+	for _, from := range []ParserState{I_i, V_f, U} {
+		found := slices.ContainsFunc(diagram.Edges, func(edge DiagramEdge) bool {
+			return edge.From == from && edge.To == NUM && slices.Contains(edge.Labels, "~")
+		})
+		if !found {
+			t.Errorf("no ~ edge from %v to NUM", from.ToString())
+		}
+	}
+	if slices.Contains(diagram.Unreachable, NUM) {
+		t.Errorf("NUM is unreachable")
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+	TypeConstraint - why inference needed two types to be equal
+
+The Inferencer records one for every equation it solves: an application F A asks the type of F
+to be (type of A) → result, an annotated let asks the bound term to have the annotated type.
+When an equation cannot be solved the clash is rarely its fault alone: the types it relates were
+fixed by earlier constraints, each of which bound some type variable. Following those bindings
+back from the failing constraint gives the chain reported in TypeError.Trace, in the order the
+constraints were met, which Explain renders over the source with each subterm underlined.
+*/
+type TypeConstraint struct {
+	// The equation as stated, before solving
+	Left  LType
+	Right LType
+	// Subterm whose typing called for the equation, and why
+	Term   string
+	Span   Span
+	Pos    Position
+	Reason string
+	// Left = Right printed with the variable names of the error it is part of
+	Text string
+}
+
+// Indices of the constraints the failure of constraint last depends on: last itself, and
+// transitively every constraint that bound a type variable of one already in the chain.
+func (inf *Inferencer) chain(last int) []int {
+	in_chain := map[int]bool{last: true}
+	pending := []int{last}
+	for len(pending) > 0 {
+		constraint := inf.Constraints[pending[0]]
+		pending = pending[1:]
+		for _, id := range append(typeVarOrder(constraint.Left), typeVarOrder(constraint.Right)...) {
+			if index, bound := inf.origin[id]; bound && !in_chain[index] {
+				in_chain[index] = true
+				pending = append(pending, index)
+			}
+		}
+	}
+	indices := []int{}
+	for index := range in_chain {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+	return indices
+}
+
+// Guess at the culprit when head, after position-1 arguments of type applied, cannot take
+// argument arg of type given.
+func suggestArgument(head string, position int, arg string, applied LType, given LType,
+	kind TypeErrorKind, pretty func(LType) string) string {
+	switch node := applied.(type) {
+	case *TArrow:
+		return fmt.Sprintf("argument %v of %v, %v, is likely wrong: %v expects %v there but %v has type %v",
+			position, head, arg, head, pretty(node.From), arg, pretty(given))
+	case *TVar:
+		if kind == TY_Occurs && position == 1 && head == arg {
+			return fmt.Sprintf("%v is applied to itself, which no simple type allows; the argument %v is likely wrong", head, arg)
+		}
+		return fmt.Sprintf("argument %v of %v, %v, is likely wrong: its type would have to contain the type of %v",
+			position, head, arg, head)
+	}
+	if position == 1 {
+		return fmt.Sprintf("%v has type %v and is not a function; the wrong term is likely in function position", head, pretty(applied))
+	}
+	return fmt.Sprintf("%v is given more arguments than its type allows; argument %v, %v, is likely one too many", head, position, arg)
+}
+
+// Explain renders e over several lines: the error, then the constraints that led to it with
+// their subterms underlined in source (the text Span offsets are relative to), and the
+// suggestion with the subterm it blames underlined.
+func (e *TypeError) Explain(source string) string {
+	lines := []string{e.Error()}
+	// The failing constraint ends the trace, underlined there
+	if len(e.Trace) == 0 {
+		lines = append(lines, highlight(source, e.Span, "    ")...)
+	} else {
+		lines = append(lines, "  because:")
+	}
+	for i, constraint := range e.Trace {
+		where := fmt.Sprintf("offset %v", constraint.Span.Offset)
+		if constraint.Pos.Line > 0 {
+			where = constraint.Pos.ToString()
+		}
+		lines = append(lines, fmt.Sprintf("    %v. %v: %v, so %v", i+1, where, constraint.Reason, constraint.Text))
+		lines = append(lines, highlight(source, constraint.Span, "         ")...)
+	}
+	if len(e.Suggestion) > 0 {
+		lines = append(lines, "  suggestion: "+e.Suggestion)
+		if e.SuggestionSpan.End > e.SuggestionSpan.Offset {
+			lines = append(lines, highlight(source, e.SuggestionSpan, "    ")...)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Widest excerpt of the source shown by highlight, in runes.
+const Highlight_Width = 72
+
+// source on one line and carets under span on the next, both prefixed by indent. Long sources
+// are cut down to a window around the span.
+func highlight(source string, span Span, indent string) []string {
+	if span.Offset < 0 || span.End > len(source) || span.Offset > span.End {
+		return []string{}
+	}
+	before, under, after := source[:span.Offset], source[span.Offset:span.End], source[span.End:]
+	if utf8.RuneCountInString(source) > Highlight_Width {
+		margin := max(0, (Highlight_Width-utf8.RuneCountInString(under))/2)
+		if runes := []rune(before); len(runes) > margin {
+			before = "…" + string(runes[len(runes)-margin:])
+		}
+		if runes := []rune(after); len(runes) > margin {
+			after = string(runes[:margin]) + "…"
+		}
+	}
+	carets := strings.Repeat(" ", utf8.RuneCountInString(before)) + strings.Repeat("^", max(1, utf8.RuneCountInString(under)))
+	return []string{indent + before + under + after, indent + carets}
+}
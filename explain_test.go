@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// FLIP applied to + and then to arguments in the wrong order: the clash is on "a", but it
+// comes from the constraint binding F1 to + three applications earlier.
+func TestExplainFlipApplication(t *testing.T) {
+	source := `(LF1.(LX1.(LY1.(F1Y1X1))))(+)(1)("a")`
+	stmt, decode_err := CreateDecoder(strings.NewReader(source)).Decode()
+	if decode_err != nil && decode_err != io.EOF {
+		t.Fatal(decode_err)
+	}
+	_, infer_err := InferStatement(stmt, nil)
+	var type_err *TypeError
+	if !errors.As(infer_err, &type_err) || type_err.Kind != TY_Unify {
+		t.Fatalf("got %v, want a unification failure", infer_err)
+	}
+	explained := type_err.Explain(stmt.Source[stmt.BodyStart:])
+	lines := strings.Split(explained, "\n")
+	for _, want := range []string{
+		"  because:",
+		"    1. line 1, column 17: F1 is applied to Y1, so a = b → c",
+		"    3. line 1, column 1: λF1.λX1.λY1.(F1Y1X1) is applied to +, so a → d → b → e = (INT → INT → INT) → f",
+		"    5. line 1, column 1: λF1.λX1.λY1.(F1Y1X1)+1 is applied to \"a\", so g = STR → h",
+		"  suggestion: argument 3 of λF1.λX1.λY1.(F1Y1X1), \"a\", is likely wrong: " +
+			"λF1.λX1.λY1.(F1Y1X1) expects INT there but \"a\" has type STR",
+	} {
+		if !strings.Contains(explained, want+"\n") {
+			t.Errorf("missing %q in:\n%v", want, explained)
+		}
+	}
+	// The suggestion ends with the argument underlined
+	if got := lines[len(lines)-2:]; got[0] != "    "+source || got[1] != "    "+strings.Repeat(" ", 32)+"^^^^^" {
+		t.Errorf("suggestion underlined as\n%v", strings.Join(got, "\n"))
+	}
+}
+
+func TestHighlightCutsLongSources(t *testing.T) {
+	if got := highlight("LX1.(X1X1)", Span{Offset: 5, End: 9}, ""); got[0] != "LX1.(X1X1)" || got[1] != "     ^^^^" {
+		t.Errorf("got %q", got)
+	}
+	source := strings.Repeat("A", 100) + "λX1" + strings.Repeat("B", 100)
+	got := highlight(source, Span{Offset: 100, End: 104}, "")
+	if !strings.HasPrefix(got[0], "…") || !strings.HasSuffix(got[0], "…") || !strings.Contains(got[0], "λX1") {
+		t.Errorf("excerpt %q", got[0])
+	}
+	if caret := strings.Index(got[1], "^"); []rune(got[0])[caret] != 'λ' || strings.Count(got[1], "^") != 3 {
+		t.Errorf("carets %q under %q", got[1], got[0])
+	}
+	if got := highlight("X1", Span{Offset: 1, End: 5}, ""); len(got) != 0 {
+		t.Errorf("span past the end highlighted as %q", got)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+/*
+	Fixpoints - @FIX and recursive lets
+
+@FIX G stands for a term F with F = G F, which is all a recursive definition needs:
+λ@F1=(E).(B) lowers to (λF1.(B))(@FIX(λF1.(E))). How far the equation may be unfolded
+depends on the strategy, as it does for the Y and Z combinators:
+
+  - call-by-name, normal order and head reduction unfold @FIX G to G(@FIX G) whenever it is
+    reached, since G only evaluates its argument where the recursive call is needed
+  - call-by-value and applicative order first reduce G to a lambda λF1.(E), after which
+    @FIX G is a value like any other lambda, and only unfold it once it is applied to an
+    evaluated argument: @FIX G V ⇒ G (@FIX G) V, the Z combinator's delay without its extra
+    lambda. That is only sound when E needs F1 solely under a lambda of its own (a function
+    calling itself); a definition such as λ@X1=(+(X1)(1)).(X1) would have to know X1 to
+    compute it. Such an @FIX is left stuck, and Reduce reports it as ErrIllFounded rather
+    than returning the stuck term as if it were a value
+
+Applicative order also reduces under lambdas, where a recursive call on the bound variable
+of a function, F1(-(N1)(1)) or M1(F1)(T1), would unfold forever. So an @FIX applied to
+arguments any of which mentions a variable bound outside of it, which is only ever the case
+under a lambda, is not unfolded: the call stays in the normal form of the function, to be
+unfolded once the function is applied to values.
+*/
+var ErrIllFounded = errors.New("ill-founded recursion")
+
+// Whether s evaluates arguments before passing them.
+func strictStrategy(s Strategy) bool {
+	return s == R_CallByValue || s == R_ApplicativeOrder
+}
+
+// One step of @FIX g under s, which for a strict s only evaluates g.
+func stepFix(g Core, s Strategy) (Core, bool) {
+	fix := CoreApply(PrimCore(&LPrim{Op: PRIM_Fix}), g)
+	if !strictStrategy(s) {
+		return &CApp{Fun: g, Arg: fix}, true
+	}
+	if _, is_lam := g.(*CLam); is_lam && s == R_CallByValue {
+		return fix, false
+	}
+	next, stepped := Step(g, s)
+	if !stepped {
+		return fix, false
+	}
+	return CoreApply(PrimCore(&LPrim{Op: PRIM_Fix}), next), true
+}
+
+// One step of c = @FIX G A1 ... An under a strict s, evaluating G and then each argument
+// left to right before unfolding to G (@FIX G) A1 ... An. The unfolding waits while an
+// argument mentions a variable bound outside of it, or G needs its own value. The last
+// result is false when c is no such application.
+func stepFixApplication(c Core, s Strategy) (Core, bool, bool) {
+	if !strictStrategy(s) {
+		return c, false, false
+	}
+	head, args := CoreSpine(c)
+	prim, is_prim := DecodePrim(head)
+	op, is_op := prim.(*LPrim)
+	if !is_prim || !is_op || op.Op != PRIM_Fix || len(args) < 2 {
+		return c, false, false
+	}
+	if fix, stepped := stepFix(args[0], s); stepped {
+		return CoreApply(fix, args[1:]...), true, true
+	}
+	for i, arg := range args[1:] {
+		if next, stepped := Step(arg, s); stepped {
+			args[i+1] = next
+			return CoreApply(head, args...), true, true
+		}
+	}
+	lam, is_lam := args[0].(*CLam)
+	if !is_lam || usedUnguarded(lam.Body, 0) || slices.ContainsFunc(args[1:], func(arg Core) bool {
+		return boundOutside(arg, 0)
+	}) {
+		return c, false, true
+	}
+	return CoreApply(lam, append([]Core{CoreApply(head, lam)}, args[1:]...)...), true, true
+}
+
+// Whether index occurs in c outside of any lambda of c.
+func usedUnguarded(c Core, index int) bool {
+	switch node := c.(type) {
+	case *CVar:
+		return node.Index == index
+	case *CApp:
+		return usedUnguarded(node.Fun, index) || usedUnguarded(node.Arg, index)
+	}
+	return false
+}
+
+// Whether c mentions a variable bound outside of it, depth being the binders of c entered.
+func boundOutside(c Core, depth int) bool {
+	switch node := c.(type) {
+	case *CVar:
+		return node.Index >= depth
+	case *CLam:
+		return boundOutside(node.Body, depth+1)
+	case *CApp:
+		return boundOutside(node.Fun, depth) || boundOutside(node.Arg, depth)
+	}
+	return false
+}
+
+// The ill-founded @FIX a strict strategy s got stuck on in c, if any: one in a position Step
+// would have evaluated next.
+func illFounded(c Core, s Strategy) (*CLam, bool) {
+	if !strictStrategy(s) {
+		return nil, false
+	}
+	switch node := c.(type) {
+	case *CLam:
+		if s == R_CallByValue {
+			return nil, false
+		}
+		return illFounded(node.Body, s)
+	case *CApp:
+		head, args := CoreSpine(c)
+		if prim, is_prim := DecodePrim(head); is_prim && len(args) == 1 {
+			if op, is_op := prim.(*LPrim); is_op && op.Op == PRIM_Fix {
+				if lam, is_lam := args[0].(*CLam); is_lam && usedUnguarded(lam.Body, 0) {
+					return lam, true
+				}
+			}
+		}
+		if lam, found := illFounded(node.Fun, s); found {
+			return lam, true
+		}
+		return illFounded(node.Arg, s)
+	}
+	return nil, false
+}
+
+// Error for a term Reduce stopped at under s, nil unless it is stuck on ill-founded recursion.
+func illFoundedError(c Core, s Strategy) error {
+	lam, found := illFounded(c, s)
+	if !found {
+		return nil
+	}
+	return fmt.Errorf("%w: %v is needed to compute its own definition under %v",
+		ErrIllFounded, lam.Name, s.ToString())
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRecursionUnderEveryStrategy(t *testing.T) {
+	cases := []struct {
+		source string
+		want   string
+	}{
+		{"L@F1=(LN1.(@IF(<(N1)(1))(0)(+(N1)(F1(-(N1)(1)))))).(F1(3))", "6"},
+		{"@FIX(LF1.(LN1.(@IF(<(N1)(1))(\"\")(++(\"*\")(F1(-(N1)(1)))))))(3)", "\"***\""},
+		// Curried, the recursive call under two binders
+		{"L@A1=(LX1.(LY1.(@IF(<(X1)(1))(Y1)(A1(-(X1)(1))(+(Y1)(2)))))).(A1(3)(0))", "6"},
+	}
+	for _, strategy := range []Strategy{R_NormalOrder, R_CallByName, R_CallByValue, R_ApplicativeOrder} {
+		for _, c := range cases {
+			nf, _, reduce_err := Reduce(parseCore(t, c.source), strategy, Test_Max_Steps)
+			if reduce_err != nil {
+				t.Errorf("%v under %v: %v", c.source, strategy.ToString(), reduce_err)
+			} else if got := Raise(nf).LPrint(); got != c.want {
+				t.Errorf("%v under %v: got %v, want %v", c.source, strategy.ToString(), got, c.want)
+			}
+		}
+	}
+}
+
+// Applicative order normalizes a recursive function without unfolding the calls under its
+// binders.
+func TestApplicativeRecursiveFunction(t *testing.T) {
+	term := parseCore(t, "L@F1=(LN1.(@IF(<(N1)(1))(0)(+(N1)(F1(-(N1)(1)))))).(F1)")
+	nf, _, reduce_err := Reduce(term, R_ApplicativeOrder, Test_Max_Steps)
+	if reduce_err != nil {
+		t.Fatal(reduce_err)
+	}
+	if head, args := CoreSpine(nf); head.CPrint() != PrimName(&LPrim{Op: PRIM_Fix}) || len(args) != 1 {
+		t.Errorf("got %v, want the @FIX itself", nf.CPrint())
+	}
+}
+
+func TestIllFoundedRecursion(t *testing.T) {
+	term := parseCore(t, "L@X1=(+(X1)(1)).(X1)")
+	for _, strategy := range []Strategy{R_CallByValue, R_ApplicativeOrder} {
+		if _, _, reduce_err := Reduce(term, strategy, Test_Max_Steps); !errors.Is(reduce_err, ErrIllFounded) {
+			t.Errorf("%v: got %v, want ErrIllFounded", strategy.ToString(), reduce_err)
+		}
+	}
+	// Lazily, a definition needing itself only where it is not used is fine
+	lazy := parseCore(t, "L@X1=(@IF(@TRUE)(1)(X1)).(X1)")
+	for _, strategy := range []Strategy{R_NormalOrder, R_CallByName} {
+		if nf, _, reduce_err := Reduce(lazy, strategy, Test_Max_Steps); reduce_err != nil || Raise(nf).LPrint() != "1" {
+			t.Errorf("%v: got %v, %v", strategy.ToString(), nf, reduce_err)
+		}
+	}
+}
+
+func TestEvalExamplesUnderEveryStrategy(t *testing.T) {
+	for name := range Eval_Strategies {
+		var out strings.Builder
+		if eval_err := RunCommand([]string{"eval", name}, &out); eval_err != nil {
+			t.Errorf("%v: %v\n%v", name, eval_err, out.String())
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+)
+
+/*
+	Hash-consed term store
+
+Every HTerm is created through a TermStore, which returns the existing node whenever one
+with the same kind, index or name and children already exists. Terms are nameless like Core
+(binder names are dropped, Core() reads back with fresh hints), so alpha-equivalent terms
+are the very same node: equality is pointer comparison, copying is free, and a reduction
+trace only allocates the subterms that actually changed.
+
+Each node carries a structural Hash computed from its children's hashes. It does not depend
+on pointers or on the order terms were interned in, so it is stable across stores and runs.
+Nodes are never mutated after creation and a store is safe for concurrent use.
+
+A store never forgets a node on its own, so it grows with every distinct subterm of every
+reduction run through it. Use one store per job, or Reset it between jobs: terms interned
+before a Reset stay valid but are no longer shared with the ones interned after it.
+*/
+type HKind int
+
+const (
+	_ HKind = iota
+	H_Var
+	H_Free
+	H_Lam
+	H_App
+)
+
+type HTerm struct {
+	Kind HKind
+	// De Bruijn index of H_Var
+	Index int
+	// Name of H_Free
+	Name string
+	// Body of H_Lam, function of H_App
+	Left *HTerm
+	// Argument of H_App
+	Right *HTerm
+	Hash  uint64
+	// Number of nodes in the term as a tree, i.e. without sharing
+	Size int
+	// One more than the largest index pointing outside the term, 0 if it has none
+	Loose int
+}
+
+type hconsKey struct {
+	Kind  HKind
+	Index int
+	Name  string
+	Left  *HTerm
+	Right *HTerm
+}
+
+type TermStoreStats struct {
+	// Distinct nodes held
+	Nodes int
+	// Constructions answered by an existing node
+	Hits   int
+	Misses int
+}
+
+type TermStore struct {
+	mutex sync.Mutex
+	nodes map[hconsKey]*HTerm
+	stats TermStoreStats
+}
+
+func CreateTermStore() *TermStore {
+	return &TermStore{nodes: map[hconsKey]*HTerm{}}
+}
+
+// Counters of the store so far.
+func (s *TermStore) Stats() TermStoreStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats
+}
+
+// Drop every node and zero the counters.
+func (s *TermStore) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.nodes = map[hconsKey]*HTerm{}
+	s.stats = TermStoreStats{}
+}
+
+func (s *TermStore) intern(key hconsKey) *HTerm {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if node, exists := s.nodes[key]; exists {
+		s.stats.Hits += 1
+		return node
+	}
+	s.stats.Misses += 1
+	node := &HTerm{Kind: key.Kind, Index: key.Index, Name: key.Name, Left: key.Left, Right: key.Right, Size: 1}
+	var left_hash, right_hash uint64
+	switch key.Kind {
+	case H_Var:
+		node.Loose = key.Index + 1
+	case H_Lam:
+		left_hash = key.Left.Hash
+		node.Size += key.Left.Size
+		node.Loose = max(key.Left.Loose-1, 0)
+	case H_App:
+		left_hash, right_hash = key.Left.Hash, key.Right.Hash
+		node.Size += key.Left.Size + key.Right.Size
+		node.Loose = max(key.Left.Loose, key.Right.Loose)
+	}
+	node.Hash = hashNode(key.Kind, key.Index, key.Name, left_hash, right_hash)
+	s.nodes[key] = node
+	s.stats.Nodes = len(s.nodes)
+	return node
+}
+
+// Hash of a node from its own fields and the hashes of its children.
+func hashNode(kind HKind, index int, name string, left uint64, right uint64) uint64 {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+	h.Write([]byte{byte(kind)})
+	switch kind {
+	case H_Var:
+		binary.LittleEndian.PutUint64(buf, uint64(index))
+		h.Write(buf)
+	case H_Free:
+		h.Write([]byte(name))
+	case H_Lam:
+		binary.LittleEndian.PutUint64(buf, left)
+		h.Write(buf)
+	case H_App:
+		binary.LittleEndian.PutUint64(buf, left)
+		h.Write(buf)
+		binary.LittleEndian.PutUint64(buf, right)
+		h.Write(buf)
+	}
+	return h.Sum64()
+}
+
+// The Hash Intern(c) would have, computed without a store.
+func CoreHash(c Core) uint64 {
+	switch node := c.(type) {
+	case *CVar:
+		return hashNode(H_Var, node.Index, "", 0, 0)
+	case *CFree:
+		return hashNode(H_Free, 0, node.Name, 0, 0)
+	case *CLam:
+		return hashNode(H_Lam, 0, "", CoreHash(node.Body), 0)
+	case *CApp:
+		return hashNode(H_App, 0, "", CoreHash(node.Fun), CoreHash(node.Arg))
+	}
+	panic(fmt.Sprintf("Cannot hash Core of unknown type %T", c))
+}
+
+func (s *TermStore) Var(index int) *HTerm {
+	return s.intern(hconsKey{Kind: H_Var, Index: index})
+}
+
+func (s *TermStore) Free(name string) *HTerm {
+	return s.intern(hconsKey{Kind: H_Free, Name: name})
+}
+
+func (s *TermStore) Lam(body *HTerm) *HTerm {
+	return s.intern(hconsKey{Kind: H_Lam, Left: body})
+}
+
+func (s *TermStore) App(fun *HTerm, arg *HTerm) *HTerm {
+	return s.intern(hconsKey{Kind: H_App, Left: fun, Right: arg})
+}
+
+// Intern a Core term, sharing every subterm already in the store.
+func (s *TermStore) Intern(c Core) *HTerm {
+	switch node := c.(type) {
+	case *CVar:
+		return s.Var(node.Index)
+	case *CFree:
+		return s.Free(node.Name)
+	case *CLam:
+		return s.Lam(s.Intern(node.Body))
+	case *CApp:
+		return s.App(s.Intern(node.Fun), s.Intern(node.Arg))
+	}
+	panic(fmt.Sprintf("Cannot intern Core of unknown type %T", c))
+}
+
+// Unshared Core copy of h, binder names left for Raise to choose.
+func (h *HTerm) Core() Core {
+	switch h.Kind {
+	case H_Var:
+		return &CVar{Index: h.Index}
+	case H_Free:
+		return &CFree{Name: h.Name}
+	case H_Lam:
+		return &CLam{Body: h.Left.Core()}
+	}
+	return &CApp{Fun: h.Left.Core(), Arg: h.Right.Core()}
+}
+
+// Same text as CPrint of the equivalent Core.
+func (h *HTerm) CPrint() string {
+	switch h.Kind {
+	case H_Var:
+		return strconv.Itoa(h.Index)
+	case H_Free:
+		return h.Name
+	case H_Lam:
+		return "λ" + h.Left.CPrint()
+	}
+	return "(" + h.Left.CPrint() + " " + h.Right.CPrint() + ")"
+}
+
+// Key for memoizing an operation at a given binder depth.
+type hdepthKey struct {
+	Term  *HTerm
+	Depth int
+}
+
+// Shift adds d to every index of h that points past cutoff binders. Subterms without such
+// indices are returned as they are, and each shared subterm is shifted once.
+func (s *TermStore) Shift(h *HTerm, d int, cutoff int) *HTerm {
+	memo := map[hdepthKey]*HTerm{}
+	var shift func(h *HTerm, cutoff int) *HTerm
+	shift = func(h *HTerm, cutoff int) *HTerm {
+		if h.Loose <= cutoff {
+			return h
+		}
+		key := hdepthKey{Term: h, Depth: cutoff}
+		if out, done := memo[key]; done {
+			return out
+		}
+		var out *HTerm
+		switch h.Kind {
+		case H_Var:
+			out = s.Var(h.Index + d)
+		case H_Lam:
+			out = s.Lam(shift(h.Left, cutoff+1))
+		case H_App:
+			out = s.App(shift(h.Left, cutoff), shift(h.Right, cutoff))
+		}
+		memo[key] = out
+		return out
+	}
+	if d == 0 {
+		return h
+	}
+	return shift(h, cutoff)
+}
+
+// Contract the redex (λ.body) arg.
+func (s *TermStore) Beta(body *HTerm, arg *HTerm) *HTerm {
+	memo := map[hdepthKey]*HTerm{}
+	var subst func(h *HTerm, depth int) *HTerm
+	subst = func(h *HTerm, depth int) *HTerm {
+		if h.Loose <= depth {
+			return h
+		}
+		key := hdepthKey{Term: h, Depth: depth}
+		if out, done := memo[key]; done {
+			return out
+		}
+		var out *HTerm
+		switch h.Kind {
+		case H_Var:
+			if h.Index == depth {
+				out = s.Shift(arg, depth, 0)
+			} else {
+				out = s.Var(h.Index - 1)
+			}
+		case H_Lam:
+			out = s.Lam(subst(h.Left, depth+1))
+		case H_App:
+			out = s.App(subst(h.Left, depth), subst(h.Right, depth))
+		}
+		memo[key] = out
+		return out
+	}
+	return subst(body, 0)
+}
+
+// One normal order step, false if h is already in normal form.
+func (s *TermStore) Step(h *HTerm) (*HTerm, bool) {
+	switch h.Kind {
+	case H_Lam:
+		body, stepped := s.Step(h.Left)
+		if !stepped {
+			return h, false
+		}
+		return s.Lam(body), true
+	case H_App:
+		if h.Left.Kind == H_Lam {
+			return s.Beta(h.Left.Left, h.Right), true
+		}
+		if fun, stepped := s.Step(h.Left); stepped {
+			return s.App(fun, h.Right), true
+		}
+		if arg, stepped := s.Step(h.Right); stepped {
+			return s.App(h.Left, arg), true
+		}
+	}
+	return h, false
+}
+
+// Every term of the normal order reduction of h, h first and the normal form last.
+// max_steps <= 0 means no limit.
+func (s *TermStore) Trace(h *HTerm, max_steps int) ([]*HTerm, error) {
+	trace := []*HTerm{h}
+	for {
+		next, stepped := s.Step(h)
+		if !stepped {
+			return trace, nil
+		}
+		if max_steps > 0 && len(trace) > max_steps {
+			return trace, fmt.Errorf("%w: %v steps of hash-consed %v", ErrStepLimit, len(trace)-1, R_NormalOrder.ToString())
+		}
+		h = next
+		trace = append(trace, h)
+	}
+}
+
+// Normal form of h by normal order reduction, with the number of steps taken.
+func (s *TermStore) Reduce(h *HTerm, max_steps int) (*HTerm, int, error) {
+	steps := 0
+	for {
+		next, stepped := s.Step(h)
+		if !stepped {
+			return h, steps, nil
+		}
+		if max_steps > 0 && steps >= max_steps {
+			return h, steps, fmt.Errorf("%w: %v steps of hash-consed %v", ErrStepLimit, steps, R_NormalOrder.ToString())
+		}
+		h = next
+		steps += 1
+	}
+}
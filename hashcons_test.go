@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestHashConsSharesAlphaEquivalentTerms(t *testing.T) {
+	store := CreateTermStore()
+	// λX1.λY1.(X1 Y1) and λA1.λB1.(A1 B1) are the same node, down to the shared subterms
+	a := store.Intern(&CLam{Name: "X1", Body: &CLam{Name: "Y1", Body: CoreApply(&CVar{Index: 1, Name: "X1"}, &CVar{Index: 0, Name: "Y1"})}})
+	b := store.Intern(&CLam{Name: "A1", Body: &CLam{Name: "B1", Body: CoreApply(&CVar{Index: 1, Name: "A1"}, &CVar{Index: 0, Name: "B1"})}})
+	if a != b {
+		t.Errorf("alpha-equivalent terms interned as two nodes")
+	}
+	if a.CPrint() != "λλ(1 0)" || a.Size != 5 || a.Loose != 0 || a.Left.Left.Loose != 2 {
+		t.Errorf("got %v of size %v, loose %v", a.CPrint(), a.Size, a.Loose)
+	}
+	stats := store.Stats()
+	if stats.Nodes != 5 || stats.Misses != 5 || stats.Hits != 5 {
+		t.Errorf("got %+v, want 5 nodes, 5 misses and 5 hits", stats)
+	}
+	if other := store.Intern(&CLam{Name: "X1", Body: &CVar{Index: 0, Name: "X1"}}); other == a {
+		t.Errorf("distinct terms interned as the same node")
+	}
+}
+
+func TestHashConsHashIsStructural(t *testing.T) {
+	prelude := LoadPrelude()
+	first, second := CreateTermStore(), CreateTermStore()
+	// Interning in a different order must not change the hashes
+	second.Intern(prelude.Cores["K"])
+	for _, name := range []string{"S", "PLUS", "POW", "PRED"} {
+		if first.Intern(prelude.Cores[name]).Hash != second.Intern(prelude.Cores[name]).Hash {
+			t.Errorf("%v hashes differently in two stores", name)
+		}
+	}
+	if first.Intern(prelude.Cores["TRUE"]).Hash == first.Intern(prelude.Cores["FALSE"]).Hash {
+		t.Errorf("TRUE and FALSE hash alike")
+	}
+}
+
+func TestHashConsAgreesWithNormalOrder(t *testing.T) {
+	store := CreateTermStore()
+	for _, c := range preludeCases() {
+		if c.Prims {
+			// Only beta steps, the increments stay stuck
+			continue
+		}
+		t.Run(c.Name, func(t *testing.T) {
+			nf, steps, reduce_err := store.Reduce(store.Intern(c.Term), Test_Max_Steps)
+			if reduce_err != nil {
+				t.Fatal(reduce_err)
+			}
+			checkNormalResult(t, c.Term, nf.Core())
+			if nf.CPrint() != nf.Core().CPrint() {
+				t.Errorf("printed %v, its Core prints %v", nf.CPrint(), nf.Core().CPrint())
+			}
+			trace, trace_err := store.Trace(store.Intern(c.Term), Test_Max_Steps)
+			if trace_err != nil {
+				t.Fatal(trace_err)
+			}
+			if len(trace) != steps+1 || trace[len(trace)-1] != nf {
+				t.Errorf("trace of %v terms ending in %v, want %v ending in %v", len(trace),
+					trace[len(trace)-1].CPrint(), steps+1, nf.CPrint())
+			}
+		})
+	}
+}
+
+func TestHashConsStepLimit(t *testing.T) {
+	store := CreateTermStore()
+	omega := store.Intern(LoadPrelude().Cores["OMEGA"])
+	if _, _, reduce_err := store.Reduce(omega, 100); !errors.Is(reduce_err, ErrStepLimit) {
+		t.Errorf("got %v, want the step limit", reduce_err)
+	}
+	if _, trace_err := store.Trace(omega, 100); !errors.Is(trace_err, ErrStepLimit) {
+		t.Errorf("got %v, want the step limit", trace_err)
+	}
+}
+
+func TestHashConsReset(t *testing.T) {
+	store := CreateTermStore()
+	before := store.Intern(LoadPrelude().Cores["S"])
+	store.Reset()
+	if stats := store.Stats(); stats != (TermStoreStats{}) {
+		t.Errorf("got %+v after a reset", stats)
+	}
+	after := store.Intern(LoadPrelude().Cores["S"])
+	if after == before || after.Hash != before.Hash || after.CPrint() != before.CPrint() {
+		t.Errorf("reset store gave %v, before it %v", after.CPrint(), before.CPrint())
+	}
+}
+
+func TestHashConsConcurrentInterning(t *testing.T) {
+	store := CreateTermStore()
+	term := LoadPrelude().Cores["POW"]
+	nodes := make([]*HTerm, 8)
+	var wg sync.WaitGroup
+	for i := range nodes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			nodes[i] = store.Intern(term)
+			store.Stats()
+		}(i)
+	}
+	wg.Wait()
+	for _, node := range nodes {
+		if node != nodes[0] {
+			t.Fatalf("concurrent interning gave distinct nodes")
+		}
+	}
+}
@@ -0,0 +1,515 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+/*
+	Hindley–Milner type inference
+
+Infers the principal type of an unannotated term with Algorithm J: every binder and every
+application result gets a fresh type variable, and the constraints met along the way are
+solved on the spot by unification into one substitution shared by the whole run. Only let
+bindings (λX1=(E).(B)) are generalized, over the type variables of E that do not occur in
+the enclosing scope, so X1 may be used at several types within B; a lambda-bound variable
+has the same type at every use. Binder annotations (λX1:A.) are honoured, their base types
+being constants that only unify with themselves, as are INT, BOOL and STR, the types of the
+primitive literals (see PrimType).
+
+Failures are *TypeErrors naming the subterm at which the constraint could not be solved:
+TY_Occurs when a type would have to contain itself (X1X1, the Y combinator), TY_Unify when
+two types clash, e.g. a base type applied as a function. Every constraint is recorded with the
+subterm that called for it, so the error also carries the chain of earlier constraints that
+fixed the clashing types (see explain.go).
+*/
+type TVar struct {
+	ID int
+}
+
+func (t *TVar) TPrint() string {
+	return "t" + strconv.Itoa(t.ID)
+}
+
+func (t *TVar) TEquals(t2 LType) bool {
+	tvar, is_tvar := t2.(*TVar)
+	return is_tvar && tvar.ID == t.ID
+}
+
+// ∀Vars. Type
+type TypeScheme struct {
+	Vars []int
+	Type LType
+}
+
+// Monomorphic scheme, no variable quantified.
+func MonoScheme(t LType) *TypeScheme {
+	return &TypeScheme{Vars: []int{}, Type: t}
+}
+
+func (s *TypeScheme) TPrint() string {
+	return PrettyType(s.Type)
+}
+
+type inferBinding struct {
+	Name   string
+	Scheme *TypeScheme
+}
+
+type Inferencer struct {
+	// Spans recorded by the parser, used to locate errors
+	Spans map[LExpr]Span
+	// Schemes of free variables
+	Context map[string]*TypeScheme
+	// Every constraint met so far, in order
+	Constraints []TypeConstraint
+	subst       map[int]LType
+	// Index in Constraints of the constraint whose solution bound each type variable
+	origin  map[int]int
+	solving int
+	next    int
+}
+
+func CreateInferencer(spans map[LExpr]Span, context map[string]*TypeScheme) *Inferencer {
+	if context == nil {
+		context = map[string]*TypeScheme{}
+	}
+	return &Inferencer{Spans: spans, Context: context, subst: map[int]LType{}, origin: map[int]int{}}
+}
+
+// Principal type scheme of l, every type variable left in its type being quantified.
+func InferType(l LExpr, spans map[LExpr]Span, context map[string]*TypeScheme) (*TypeScheme, error) {
+	inf := CreateInferencer(spans, context)
+	t, infer_err := inf.Infer(l)
+	if infer_err != nil {
+		return nil, infer_err
+	}
+	return inf.generalize(t, []inferBinding{}), nil
+}
+
+// Infer the type of a decoded statement, positioning any error in the decoded input.
+func InferStatement(stmt Statement, context map[string]*TypeScheme) (*TypeScheme, error) {
+	scheme, infer_err := InferType(stmt.Expr, stmt.Spans, context)
+	if type_err, is_type_err := infer_err.(*TypeError); is_type_err {
+		located := *type_err
+		located.Pos = stmt.Locate(located.Span.Offset)
+		located.Trace = make([]TypeConstraint, len(type_err.Trace))
+		for i, constraint := range type_err.Trace {
+			constraint.Pos = stmt.Locate(constraint.Span.Offset)
+			located.Trace[i] = constraint
+		}
+		return nil, &located
+	}
+	return scheme, infer_err
+}
+
+// Type of l under the current substitution. Call Resolve on the result for its final form.
+func (inf *Inferencer) Infer(l LExpr) (LType, error) {
+	t, infer_err := inf.infer(l, []inferBinding{}, Span{})
+	if infer_err != nil {
+		return nil, infer_err
+	}
+	return inf.Resolve(t), nil
+}
+
+func (inf *Inferencer) fresh() *TVar {
+	inf.next += 1
+	return &TVar{ID: inf.next}
+}
+
+// Follow the substitution at the root of t only.
+func (inf *Inferencer) prune(t LType) LType {
+	for {
+		tvar, is_tvar := t.(*TVar)
+		if !is_tvar {
+			return t
+		}
+		bound, found := inf.subst[tvar.ID]
+		if !found {
+			return t
+		}
+		t = bound
+	}
+}
+
+// Apply the substitution throughout t.
+func (inf *Inferencer) Resolve(t LType) LType {
+	t = inf.prune(t)
+	if arrow, is_arrow := t.(*TArrow); is_arrow {
+		return &TArrow{From: inf.Resolve(arrow.From), To: inf.Resolve(arrow.To)}
+	}
+	resolved, _ := rowTypeMap(t, inf.Resolve)
+	return resolved
+}
+
+func (inf *Inferencer) occurs(id int, t LType) bool {
+	switch node := inf.prune(t).(type) {
+	case *TVar:
+		return node.ID == id
+	case *TArrow:
+		return inf.occurs(id, node.From) || inf.occurs(id, node.To)
+	}
+	for _, part := range rowTypeParts(inf.prune(t)) {
+		if inf.occurs(id, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// Why two types could not be unified, turned into a TypeError by the caller who knows the
+// term the constraint came from.
+type unifyError struct {
+	Kind TypeErrorKind
+	// The innermost pair of types that clash, or the variable and the type containing it
+	Left  LType
+	Right LType
+	// For TY_MissingLabel the label, Left having it and Right lacking it
+	Label string
+}
+
+func (e *unifyError) Error() string {
+	return fmt.Sprintf("%v: %v and %v", e.Kind.ToString(), e.Left.TPrint(), e.Right.TPrint())
+}
+
+func (inf *Inferencer) unify(a LType, b LType) *unifyError {
+	a, b = inf.prune(a), inf.prune(b)
+	if avar, is_var := a.(*TVar); is_var {
+		if a.TEquals(b) {
+			return nil
+		}
+		if inf.occurs(avar.ID, b) {
+			return &unifyError{Kind: TY_Occurs, Left: a, Right: inf.Resolve(b)}
+		}
+		inf.subst[avar.ID] = b
+		inf.origin[avar.ID] = inf.solving
+		return nil
+	}
+	if _, is_var := b.(*TVar); is_var {
+		return inf.unify(b, a)
+	}
+	a_arrow, a_is_arrow := a.(*TArrow)
+	b_arrow, b_is_arrow := b.(*TArrow)
+	if a_is_arrow && b_is_arrow {
+		if from_err := inf.unify(a_arrow.From, b_arrow.From); from_err != nil {
+			return from_err
+		}
+		return inf.unify(a_arrow.To, b_arrow.To)
+	}
+	if row_err, is_row := inf.unifyRowTypes(a, b); is_row {
+		return row_err
+	}
+	if a.TEquals(b) {
+		return nil
+	}
+	return &unifyError{Kind: TY_Unify, Left: inf.Resolve(a), Right: inf.Resolve(b)}
+}
+
+// Span of l, falling back to the enclosing term's for nodes built after parsing.
+func (inf *Inferencer) span(l LExpr, outer Span) Span {
+	if span, found := inf.Spans[l]; found {
+		return span
+	}
+	return outer
+}
+
+func (inf *Inferencer) instantiate(s *TypeScheme) LType {
+	if len(s.Vars) == 0 {
+		// Kept as is, so constraints on it still mention the variables that explain them
+		return s.Type
+	}
+	renaming := map[int]LType{}
+	for _, id := range s.Vars {
+		renaming[id] = inf.fresh()
+	}
+	var rename func(t LType) LType
+	rename = func(t LType) LType {
+		switch node := inf.prune(t).(type) {
+		case *TVar:
+			if replacement, found := renaming[node.ID]; found {
+				return replacement
+			}
+			return node
+		case *TArrow:
+			return &TArrow{From: rename(node.From), To: rename(node.To)}
+		}
+		renamed, _ := rowTypeMap(inf.prune(t), rename)
+		return renamed
+	}
+	return rename(s.Type)
+}
+
+func (inf *Inferencer) freeVars(t LType, out map[int]bool) {
+	switch node := inf.prune(t).(type) {
+	case *TVar:
+		out[node.ID] = true
+	case *TArrow:
+		inf.freeVars(node.From, out)
+		inf.freeVars(node.To, out)
+	}
+	for _, part := range rowTypeParts(inf.prune(t)) {
+		inf.freeVars(part, out)
+	}
+}
+
+// Quantify the variables of t that are not free in scope, in order of first appearance.
+func (inf *Inferencer) generalize(t LType, scope []inferBinding) *TypeScheme {
+	t = inf.Resolve(t)
+	in_scope := map[int]bool{}
+	for _, binding := range scope {
+		quantified := map[int]bool{}
+		for _, id := range binding.Scheme.Vars {
+			quantified[id] = true
+		}
+		free := map[int]bool{}
+		inf.freeVars(binding.Scheme.Type, free)
+		for id := range free {
+			if !quantified[id] {
+				in_scope[id] = true
+			}
+		}
+	}
+	scheme := MonoScheme(t)
+	for _, id := range typeVarOrder(t) {
+		if !in_scope[id] {
+			scheme.Vars = append(scheme.Vars, id)
+		}
+	}
+	return scheme
+}
+
+func (inf *Inferencer) infer(l LExpr, scope []inferBinding, outer Span) (LType, error) {
+	span := inf.span(l, outer)
+	switch node := l.(type) {
+	case *LVar:
+		for i := len(scope) - 1; i >= 0; i-- {
+			if scope[i].Name == node.Symbol {
+				return inf.instantiate(scope[i].Scheme), nil
+			}
+		}
+		if scheme, found := inf.Context[node.Symbol]; found {
+			return inf.instantiate(scheme), nil
+		}
+		return nil, &TypeError{Kind: TY_Unbound, Span: span, Term: node.Symbol,
+			Message: fmt.Sprintf("%v is not bound by a lambda or let and has no type in the context", node.Symbol)}
+	case *LExpression:
+		if len(node.Binding.Symbol) == 0 {
+			return inf.inferSeq(node, node.Exprs, scope, span)
+		}
+		var binding_type LType = inf.fresh()
+		if node.Binding.Type != nil {
+			binding_type = node.Binding.Type
+		}
+		inner_scope := append(scope[:len(scope):len(scope)], inferBinding{Name: node.Binding.Symbol, Scheme: MonoScheme(binding_type)})
+		body, body_err := inf.inferSeq(node, node.Exprs, inner_scope, span)
+		if body_err != nil {
+			return nil, body_err
+		}
+		return &TArrow{From: binding_type, To: body}, nil
+	case *LLet:
+		bound_scope := scope
+		var recursive LType
+		if node.Recursive {
+			// Monomorphic within its own definition
+			recursive = inf.fresh()
+			if node.Binding.Type != nil {
+				recursive = node.Binding.Type
+			}
+			bound_scope = append(scope[:len(scope):len(scope)], inferBinding{Name: node.Binding.Symbol, Scheme: MonoScheme(recursive)})
+		}
+		bound, bound_err := inf.infer(node.Bound, bound_scope, span)
+		if bound_err != nil {
+			return nil, bound_err
+		}
+		if node.Recursive {
+			index, unify_err := inf.constrain(recursive, bound, node.Bound.LPrint(), inf.span(node.Bound, span),
+				fmt.Sprintf("%v is defined recursively as %v", node.Binding.Symbol, node.Bound.LPrint()))
+			if unify_err != nil {
+				return nil, inf.failureAt(unify_err, index, func(pretty func(LType) string) (string, string) {
+					return fmt.Sprintf("%v is used as %v within its own definition", node.Binding.Symbol, pretty(recursive)),
+						fmt.Sprintf("a use of %v within %v is likely wrong", node.Binding.Symbol, node.Bound.LPrint())
+				})
+			}
+		}
+		if node.Binding.Type != nil {
+			index, unify_err := inf.constrain(node.Binding.Type, bound, node.Bound.LPrint(), inf.span(node.Bound, span),
+				fmt.Sprintf("%v is annotated %v", node.Binding.Symbol, node.Binding.Type.TPrint()))
+			if unify_err != nil {
+				return nil, inf.failureAt(unify_err, index, func(pretty func(LType) string) (string, string) {
+					return fmt.Sprintf("%v is annotated %v", node.Binding.Symbol, pretty(node.Binding.Type)),
+						fmt.Sprintf("either the annotation of %v or its bound term %v is wrong", node.Binding.Symbol, node.Bound.LPrint())
+				})
+			}
+		}
+		inner_scope := append(scope[:len(scope):len(scope)], inferBinding{Name: node.Binding.Symbol, Scheme: inf.generalize(bound, scope)})
+		return inf.infer(node.Body, inner_scope, span)
+	case *LRecord:
+		return inf.inferRecord(node, scope, span)
+	case *LProj:
+		return inf.inferProj(node, scope, span)
+	case *LTag:
+		return inf.inferTag(node), nil
+	}
+	if prim_type := PrimType(l, func() LType { return inf.fresh() }); prim_type != nil {
+		return prim_type, nil
+	} else if prim, is_prim := l.(*LPrim); is_prim && prim.Op.IsControl() {
+		return nil, &TypeError{Kind: TY_Malformed, Span: span, Term: l.LPrint(),
+			Message: fmt.Sprintf("cannot type %v, its type depends on the answer type of the enclosing @RESET", l.LPrint())}
+	}
+	return nil, &TypeError{Kind: TY_Malformed, Span: span, Term: l.LPrint(),
+		Message: fmt.Sprintf("cannot type %v", l.LPrint())}
+}
+
+// Left-nested application of exprs, the body of node.
+func (inf *Inferencer) inferSeq(node LExpr, exprs []LExpr, scope []inferBinding, span Span) (LType, error) {
+	if len(exprs) == 0 {
+		return nil, &TypeError{Kind: TY_Malformed, Span: span, Term: node.LPrint(), Message: "empty expression"}
+	}
+	consumed := 1
+	var fun_type LType
+	var fun_err error
+	if _, is_case := exprs[0].(*LCase); is_case {
+		fun_type, consumed, fun_err = inf.inferMatch(exprs, scope, span)
+	} else {
+		fun_type, fun_err = inf.infer(exprs[0], scope, span)
+	}
+	if fun_err != nil {
+		return nil, fun_err
+	}
+	fun_text := SeqPrint(exprs[:consumed])
+	fun_span := Span{Offset: inf.span(exprs[0], span).Offset, End: inf.span(exprs[consumed-1], span).End}
+	head := fun_text
+	for position, arg := range exprs[consumed:] {
+		position += consumed - 1
+		arg_type, arg_err := inf.infer(arg, scope, span)
+		if arg_err != nil {
+			return nil, arg_err
+		}
+		arg_text := arg.LPrint()
+		arg_span := inf.span(arg, span)
+		app_span := Span{Offset: fun_span.Offset, End: max(fun_span.End, arg_span.End)}
+		result := inf.fresh()
+		// Unification may bind variables before it fails, so take the types as they were
+		applied, given := inf.Resolve(fun_type), inf.Resolve(arg_type)
+		index, unify_err := inf.constrain(fun_type, &TArrow{From: arg_type, To: result}, fun_text+arg_text, app_span,
+			fmt.Sprintf("%v is applied to %v", fun_text, arg_text))
+		if unify_err != nil {
+			type_err := inf.failureAt(unify_err, index, func(pretty func(LType) string) (string, string) {
+				return fmt.Sprintf("%v has type %v and is applied to %v of type %v", fun_text, pretty(applied), arg_text, pretty(given)),
+					suggestArgument(head, position+1, arg_text, applied, given, unify_err.Kind, pretty)
+			})
+			type_err.SuggestionSpan = arg_span
+			return nil, type_err
+		}
+		fun_type = result
+		fun_text += arg.LPrint()
+		fun_span = app_span
+	}
+	return fun_type, nil
+}
+
+// Record left = right as required by term, and solve it.
+func (inf *Inferencer) constrain(left LType, right LType, term string, span Span, reason string) (int, *unifyError) {
+	inf.solving = len(inf.Constraints)
+	inf.Constraints = append(inf.Constraints, TypeConstraint{Left: left, Right: right, Term: term, Span: span, Reason: reason})
+	return inf.solving, inf.unify(left, right)
+}
+
+// TypeError for the failure of constraint index. describe gives where the constraint came from
+// and the likely culprit, printing types through pretty so they share the error's variable names.
+func (inf *Inferencer) failureAt(unify_err *unifyError, index int,
+	describe func(pretty func(LType) string) (string, string)) *TypeError {
+	failed := inf.Constraints[index]
+	namer := typeNamer{}
+	left, right := namer.Print(unify_err.Left), namer.Print(unify_err.Right)
+	context, suggestion := describe(namer.Print)
+	message := fmt.Sprintf("in %v: cannot unify %v with %v; %v", failed.Term, left, right, context)
+	if unify_err.Kind == TY_Occurs {
+		message = fmt.Sprintf("in %v: %v occurs in %v, the type would be infinite; %v", failed.Term, left, right, context)
+	} else if unify_err.Kind == TY_MissingLabel {
+		message = fmt.Sprintf("in %v: %v lacks label %v of %v; %v", failed.Term, right, unify_err.Label, left, context)
+	}
+	trace := []TypeConstraint{}
+	for _, i := range inf.chain(index) {
+		constraint := inf.Constraints[i]
+		constraint.Text = namer.Print(constraint.Left) + " = " + namer.Print(constraint.Right)
+		trace = append(trace, constraint)
+	}
+	return &TypeError{Kind: unify_err.Kind, Span: failed.Span, Term: failed.Term,
+		Expected: unify_err.Left, Found: unify_err.Right, Message: message, Trace: trace, Suggestion: suggestion}
+}
+
+// IDs of the type variables of t, in order of first appearance.
+func typeVarOrder(t LType) []int {
+	order := []int{}
+	seen := map[int]bool{}
+	var walk func(t LType)
+	walk = func(t LType) {
+		switch node := t.(type) {
+		case *TVar:
+			if !seen[node.ID] {
+				seen[node.ID] = true
+				order = append(order, node.ID)
+			}
+		case *TArrow:
+			walk(node.From)
+			walk(node.To)
+		}
+		for _, part := range rowTypeParts(t) {
+			walk(part)
+		}
+	}
+	walk(t)
+	return order
+}
+
+// Type with its variables renamed a, b, c, ... in order of first appearance, and spaced
+// arrows: (a → b → c) → b → a → c.
+func PrettyType(t LType) string {
+	return PrettyTypes(t)[0]
+}
+
+// Several types sharing one renaming, so a variable has the same name in each.
+func PrettyTypes(ts ...LType) []string {
+	namer := typeNamer{}
+	out := []string{}
+	for _, t := range ts {
+		out = append(out, namer.Print(t))
+	}
+	return out
+}
+
+// Names of type variables by ID, handed out in the order Print meets them.
+type typeNamer map[int]string
+
+func (names typeNamer) Print(t LType) string {
+	switch node := t.(type) {
+	case *TVar:
+		if _, named := names[node.ID]; !named {
+			names[node.ID] = typeVarName(len(names))
+		}
+		return names[node.ID]
+	case *TArrow:
+		from := names.Print(node.From)
+		if _, is_arrow := node.From.(*TArrow); is_arrow {
+			from = "(" + from + ")"
+		}
+		return from + " → " + names.Print(node.To)
+	case *TRecord:
+		return "{" + rowPrint(node.Row, names.Print, ": ", ", ", " | ") + "}"
+	case *TVariant:
+		return "[" + rowPrint(node.Row, names.Print, ": ", ", ", " | ") + "]"
+	case *TRowExtend:
+		return "(" + rowPrint(node, names.Print, ": ", ", ", " | ") + ")"
+	}
+	return t.TPrint()
+}
+
+// a ... z, then a1 ... z1 and so on.
+func typeVarName(i int) string {
+	name := string(rune('a' + i%26))
+	if i >= 26 {
+		name += strconv.Itoa(i / 26)
+	}
+	return name
+}
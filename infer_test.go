@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func inferTerm(t *testing.T, source string) (*TypeScheme, error) {
+	t.Helper()
+	executor := TransitionExecutor_Init()
+	p, parse_err := executor.Parse(source)
+	if parse_err != nil {
+		t.Fatalf("%v: %v", source, parse_err)
+	}
+	return InferType(SingleLExpr(p.Exprs), nil, nil)
+}
+
+func TestInferPrincipalTypes(t *testing.T) {
+	prelude := LoadPrelude()
+	cases := []struct {
+		name string
+		term LExpr
+		want string
+	}{
+		{"FLIP", prelude.Exprs["FLIP"], "(a → b → c) → b → a → c"},
+		{"K", prelude.Exprs["K"], "a → b → a"},
+		{"S", prelude.Exprs["S"], "(a → b → c) → (a → b) → a → c"},
+	}
+	for _, c := range cases {
+		scheme, infer_err := InferType(c.term, nil, nil)
+		if infer_err != nil {
+			t.Errorf("%v: %v", c.name, infer_err)
+		} else if got := PrettyType(scheme.Type); got != c.want {
+			t.Errorf("%v: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestInferSources(t *testing.T) {
+	cases := []struct {
+		source string
+		want   string
+	}{
+		// A let-bound identity is generalized, and used at BOOL and INT
+		{"LI1=(LX1.(X1)).(@IF(I1(@TRUE))(I1(1))(2))", "INT"},
+		// Annotations are honoured, even where the term alone would be more general
+		{"LX1:INT.(X1)", "INT → INT"},
+		{"LX1:INT->BOOL.(X1)", "(INT → BOOL) → INT → BOOL"},
+		{"LF1.(LX1:STR.(F1(X1)))", "(STR → a) → STR → a"},
+	}
+	for _, c := range cases {
+		scheme, infer_err := inferTerm(t, c.source)
+		if infer_err != nil {
+			t.Errorf("%v: %v", c.source, infer_err)
+		} else if got := PrettyType(scheme.Type); got != c.want {
+			t.Errorf("%v: got %v, want %v", c.source, got, c.want)
+		}
+	}
+}
+
+func TestInferFailures(t *testing.T) {
+	cases := []struct {
+		source string
+		want   TypeErrorKind
+	}{
+		{"LX1.(X1X1)", TY_Occurs},
+		{"LF1.((LX1.(F1(X1X1)))(LX1.(F1(X1X1))))", TY_Occurs},
+		{"+(1)(\"a\")", TY_Unify},
+		{"(LX1:INT.(X1))(@TRUE)", TY_Unify},
+		{"LX1:INT.(++(X1)(\"a\"))", TY_Unify},
+	}
+	for _, c := range cases {
+		_, infer_err := inferTerm(t, c.source)
+		var type_err *TypeError
+		if !errors.As(infer_err, &type_err) || type_err.Kind != c.want {
+			t.Errorf("%v: got %v, want a %v error", c.source, infer_err, c.want.ToString())
+		}
+	}
+	scheme, infer_err := InferType(LoadPrelude().Exprs["Y"], nil, nil)
+	var type_err *TypeError
+	if !errors.As(infer_err, &type_err) || type_err.Kind != TY_Occurs {
+		t.Errorf("Y: got %v, %v, want an occurs check", scheme, infer_err)
+	}
+}
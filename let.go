@@ -0,0 +1,76 @@
+package main
+
+/*
+	LLet - local definitions
+
+λX1=(E).(B) binds X1 to E within B. It means the same as (λX1.(B))(E), and lowers to exactly
+that, but keeps the binding visible to type inference, which generalizes the type of E before
+checking B (let-polymorphism). The bound term always needs its parentheses, since a "." inside
+it would otherwise be ambiguous with the one ending the binding.
+
+λ@X1=(E).(B) is the recursive form, letrec: X1 is also in scope within E. It lowers to
+(λX1.(B))(@FIX(λX1.(E))), the fixpoint primitive unfolding the definition on demand.
+*/
+type LLet struct {
+	Binding   LVar
+	Bound     LExpr
+	Body      LExpr
+	Recursive bool
+}
+
+// Print l inside parentheses, unless its own Repr already is.
+func parenthesized(l LExpr) string {
+	if seq, is_seq := l.(*LExpression); is_seq && len(seq.Binding.Symbol) == 0 && len(seq.Exprs) != 1 {
+		return seq.LPrint()
+	}
+	return "(" + l.LPrint() + ")"
+}
+
+func (l *LLet) LPrint() string {
+	marker := ""
+	if l.Recursive {
+		marker = "@"
+	}
+	return "λ" + marker + l.Binding.BindingPrint() + "=" + parenthesized(l.Bound) + "." + parenthesized(l.Body)
+}
+
+func (l *LLet) LAbstract(b LVar) LExpr {
+	out_str := "λ" + b.BindingPrint() + ".(" + l.LPrint() + ")"
+	return &LExpression{
+		Binding: b,
+		Exprs:   []LExpr{l},
+		Repr:    out_str,
+	}
+}
+
+func (l *LLet) Copy() LExpr {
+	return &LLet{
+		Binding:   l.Binding,
+		Bound:     l.Bound.Copy(),
+		Body:      l.Body.Copy(),
+		Recursive: l.Recursive,
+	}
+}
+
+// The bound term is outside the scope of the binding unless it is recursive, the body only
+// when it is not shadowed.
+func (l *LLet) LApply(b LVar, replace LExpr) LExpr {
+	body := l.Body.Copy()
+	bound := l.Bound.Copy()
+	if l.Binding.Symbol != b.Symbol {
+		body = l.Body.LApply(b, replace)
+	}
+	if l.Binding.Symbol != b.Symbol || !l.Recursive {
+		bound = l.Bound.LApply(b, replace)
+	}
+	return &LLet{
+		Binding:   l.Binding,
+		Bound:     bound,
+		Body:      body,
+		Recursive: l.Recursive,
+	}
+}
+
+func (l *LLet) LEquals(l2 LExpr) bool {
+	return l.LPrint() == l2.LPrint()
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+/*
+	Environment machines
+
+Instead of substituting, both machines pair a term with an environment (Env) recording what
+its free de Bruijn indices stand for. A beta step just pushes the argument onto the
+environment, so bodies are never copied.
+
+  - Krivine machine: call-by-name. Arguments are pushed onto the stack unevaluated as
+    closures and only evaluated when their variable reaches head position.
+  - CEK machine: call-by-value. The continuation (K) is an explicit stack of frames saying
+    what to do with the value currently being computed.
+
+Both stop at a weak head normal form and return it as a MachineResult, which can be read
+back as Core (Quote) or LExpr (ReadBack) by substituting the environments back in. Free
+constants are stuck, except for primitive operators, which both apply to literals with the
+delta rules of the reference reducer (@IF evaluating only its condition), @FIX, which both
+unfold as the reducer does under their strategy (see fix.go), and the cases of data types
+(see data.go), which both take apart directly. Only the CEK machine runs the control
+operators @RESET and @SHIFT, whose continuations are its frames (see control.go).
+*/
+type Closure struct {
+	Term Core
+	Env  *Env
+}
+
+// Env is an immutable linked list, index 0 being the most recently bound value.
+type Env struct {
+	Value *Closure
+	Next  *Env
+}
+
+func (e *Env) Extend(value *Closure) *Env {
+	return &Env{Value: value, Next: e}
+}
+
+func (e *Env) Lookup(index int) *Closure {
+	for ; e != nil; e = e.Next {
+		if index == 0 {
+			return e.Value
+		}
+		index -= 1
+	}
+	return nil
+}
+
+type MachineStats struct {
+	// Machine transitions of any kind
+	Steps int
+	// Transitions that bound an argument to a lambda
+	Betas int
+	// Deepest the stack (Krivine) or continuation (CEK) got
+	MaxStack int
+}
+
+// A closure applied to Spine, with no further step for the machine to take.
+type MachineResult struct {
+	Head  *Closure
+	Spine []*Closure
+	Stats MachineStats
+}
+
+func (r MachineResult) Quote() Core {
+	out := QuoteClosure(r.Head)
+	for _, arg := range r.Spine {
+		out = &CApp{Fun: out, Arg: QuoteClosure(arg)}
+	}
+	return out
+}
+
+func (r MachineResult) ReadBack() LExpr {
+	return Raise(r.Quote())
+}
+
+// Substitute the environment of cl back into its term.
+func QuoteClosure(cl *Closure) Core {
+	return quoteUnder(cl.Term, cl.Env, 0)
+}
+
+func quoteUnder(c Core, env *Env, depth int) Core {
+	switch node := c.(type) {
+	case *CVar:
+		if node.Index < depth {
+			return node
+		}
+		value := env.Lookup(node.Index - depth)
+		if value == nil {
+			return &CVar{Index: node.Index, Name: node.Name}
+		}
+		return Shift(QuoteClosure(value), depth, 0)
+	case *CLam:
+		return &CLam{Name: node.Name, Body: quoteUnder(node.Body, env, depth+1)}
+	case *CApp:
+		return &CApp{Fun: quoteUnder(node.Fun, env, depth), Arg: quoteUnder(node.Arg, env, depth)}
+	}
+	return c
+}
+
+func stepLimitError(machine string, stats MachineStats) error {
+	return fmt.Errorf("%w: %v transitions of the %v machine", ErrStepLimit, stats.Steps, machine)
+}
+
+// Krivine machine, call-by-name evaluation of c to weak head normal form. max_steps <= 0
+// means no limit.
+func KrivineEval(c Core, max_steps int) (MachineResult, error) {
+	stats := MachineStats{}
+	result, eval_err := krivineRun(&Closure{Term: c, Env: nil}, &stats, max_steps)
+	result.Stats = stats
+	return result, eval_err
+}
+
+// Evaluate cl on an empty stack, counting into stats. Case scrutinees are evaluated by a
+// nested run sharing the same stats and limit.
+func krivineRun(cl *Closure, stats *MachineStats, max_steps int) (MachineResult, error) {
+	term := cl.Term
+	env := cl.Env
+	stack := []*Closure{}
+	for {
+		if max_steps > 0 && stats.Steps >= max_steps {
+			return MachineResult{}, stepLimitError("Krivine", *stats)
+		}
+		stats.Steps += 1
+		switch node := term.(type) {
+		case *CApp:
+			stack = append(stack, &Closure{Term: node.Arg, Env: env})
+			stats.MaxStack = max(stats.MaxStack, len(stack))
+			term = node.Fun
+			continue
+		case *CLam:
+			if len(stack) > 0 {
+				arg := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				env = env.Extend(arg)
+				term = node.Body
+				stats.Betas += 1
+				continue
+			}
+		case *CVar:
+			if value := env.Lookup(node.Index); value != nil {
+				term = value.Term
+				env = value.Env
+				continue
+			}
+		case *CFree:
+			if node.Name == PrimName(&LPrim{Op: PRIM_Fix}) && len(stack) > 0 {
+				// @FIX G ⇒ G (@FIX G)
+				g := stack[len(stack)-1]
+				stack[len(stack)-1] = &Closure{Term: &CApp{Fun: node, Arg: g.Term}, Env: g.Env}
+				term = g.Term
+				env = g.Env
+				continue
+			}
+			if op, is_op := machineOperator(node); is_op && len(stack) >= op.Arity() {
+				result, delta_err := krivineDelta(op, stack, stats, max_steps)
+				if delta_err != nil {
+					return MachineResult{}, delta_err
+				} else if result == nil {
+					break
+				}
+				stack = stack[:len(stack)-op.Arity()]
+				term = result.Term
+				env = result.Env
+				continue
+			}
+			arms, is_case := ParseCaseName(node.Name)
+			if !is_case || len(stack) <= len(arms) {
+				break
+			}
+			scrutinee, scrutinee_err := krivineRun(stack[len(stack)-1], stats, max_steps)
+			if scrutinee_err != nil {
+				return MachineResult{}, scrutinee_err
+			}
+			con := ConstructorName(scrutinee.Head.Term)
+			arm := slices.IndexFunc(arms, func(arm CaseArm) bool { return arm.Con == con })
+			if arm < 0 || len(scrutinee.Spine) != arms[arm].Arity {
+				break
+			}
+			// The branch, in place of the case, applied to the fields
+			branch := stack[len(stack)-2-arm]
+			stack = stack[:len(stack)-1-len(arms)]
+			for i := len(scrutinee.Spine) - 1; i >= 0; i-- {
+				stack = append(stack, scrutinee.Spine[i])
+			}
+			stats.MaxStack = max(stats.MaxStack, len(stack))
+			term = branch.Term
+			env = branch.Env
+			continue
+		}
+		// Lambda with nothing to apply it to, or a free variable: weak head normal form
+		spine := make([]*Closure, len(stack))
+		for i := range stack {
+			spine[i] = stack[len(stack)-1-i]
+		}
+		return MachineResult{Head: &Closure{Term: term, Env: env}, Spine: spine}, nil
+	}
+}
+
+// Operator c stands for, other than @FIX and the control operators.
+func machineOperator(c Core) (PrimOp, bool) {
+	prim, is_prim := DecodePrim(c)
+	op, is_op := prim.(*LPrim)
+	if !is_prim || !is_op || op.Op == PRIM_Fix || op.Op.IsControl() {
+		return 0, false
+	}
+	return op.Op, true
+}
+
+// What op applied to the closures on top of stack reduces to, its strict arguments evaluated
+// by nested runs; nil when they are not literals it is defined on.
+func krivineDelta(op PrimOp, stack []*Closure, stats *MachineStats, max_steps int) (*Closure, error) {
+	strict := op.Arity()
+	if op == PRIM_If {
+		strict = 1
+	}
+	literals := []LExpr{}
+	for i := 0; i < strict; i++ {
+		arg, arg_err := krivineRun(stack[len(stack)-1-i], stats, max_steps)
+		if arg_err != nil {
+			return nil, arg_err
+		}
+		literal := DecodeLiteral(arg.Head.Term)
+		if literal == nil || len(arg.Spine) > 0 {
+			return nil, nil
+		}
+		literals = append(literals, literal)
+	}
+	if op == PRIM_If {
+		cond, is_bool := literals[0].(*LBool)
+		if !is_bool {
+			return nil, nil
+		} else if cond.Value {
+			return stack[len(stack)-2], nil
+		}
+		return stack[len(stack)-3], nil
+	}
+	result, delta_err := Delta(op, literals[0], literals[1])
+	if delta_err != nil {
+		return nil, nil
+	}
+	return &Closure{Term: PrimCore(result), Env: nil}, nil
+}
+
+// Result of a strict operator applied to as many literals as it takes, false for any other c.
+func deltaLiterals(c Core) (Core, bool) {
+	head, args := CoreSpine(c)
+	op, is_op := machineOperator(head)
+	if !is_op || op == PRIM_If || len(args) != op.Arity() {
+		return c, false
+	}
+	literals := []LExpr{}
+	for _, arg := range args {
+		literal := DecodeLiteral(arg)
+		if literal == nil {
+			return c, false
+		}
+		literals = append(literals, literal)
+	}
+	result, delta_err := Delta(op, literals[0], literals[1])
+	if delta_err != nil {
+		return c, false
+	}
+	return PrimCore(result), true
+}
+
+type FrameKind int
+
+const (
+	_         FrameKind = iota
+	K_Arg               // Function being evaluated, argument Term in Env still to evaluate
+	K_Apply             // Argument being evaluated, function Value waiting for it
+	K_Case              // Scrutinee being evaluated, the case application Term in Env waiting for it
+	K_ApplyTo           // Function being evaluated, its argument already the value Value
+	K_If                // Condition being evaluated, the @IF application Term in Env waiting for it
+	K_Reset             // Body of an @RESET being evaluated, the delimiter @SHIFT captures up to
+)
+
+type Frame struct {
+	Kind  FrameKind
+	Term  Core
+	Env   *Env
+	Value *Closure
+}
+
+// CEK machine, call-by-value evaluation of c to a value. Lambdas are values, and so are
+// stuck applications of free variables (kept as closures over a nil Env).
+func CEKEval(c Core, max_steps int) (MachineResult, error) {
+	stats := MachineStats{}
+	term := c
+	var env *Env = nil
+	kont := []Frame{}
+	var value *Closure = nil
+	for {
+		if max_steps > 0 && stats.Steps >= max_steps {
+			return MachineResult{Stats: stats}, stepLimitError("CEK", stats)
+		}
+		stats.Steps += 1
+		// Evaluating term in env until it produces a value
+		if value == nil {
+			switch node := term.(type) {
+			case *CApp:
+				if head, args := CoreSpine(node); isCaseApplication(head, args) {
+					// Only the scrutinee is evaluated before the branch is chosen
+					kont = append(kont, Frame{Kind: K_Case, Term: node, Env: env})
+					stats.MaxStack = max(stats.MaxStack, len(kont))
+					term = args[0]
+					continue
+				} else if op, is_op := machineOperator(head); is_op && op == PRIM_If && len(args) == 3 {
+					// Only the condition is evaluated before the branch is chosen
+					kont = append(kont, Frame{Kind: K_If, Term: node, Env: env})
+					stats.MaxStack = max(stats.MaxStack, len(kont))
+					term = args[0]
+					continue
+				} else if head.CPrint() == PrimName(&LPrim{Op: PRIM_Reset}) && len(args) == 1 {
+					kont = append(kont, Frame{Kind: K_Reset})
+					stats.MaxStack = max(stats.MaxStack, len(kont))
+					term = args[0]
+					continue
+				}
+				kont = append(kont, Frame{Kind: K_Arg, Term: node.Arg, Env: env})
+				stats.MaxStack = max(stats.MaxStack, len(kont))
+				term = node.Fun
+			case *CVar:
+				value = env.Lookup(node.Index)
+				if value == nil {
+					value = &Closure{Term: node, Env: nil}
+				}
+			default:
+				value = &Closure{Term: term, Env: env}
+			}
+			continue
+		}
+		// Returning value to the innermost frame
+		if len(kont) == 0 {
+			return MachineResult{Head: value, Spine: []*Closure{}, Stats: stats}, nil
+		}
+		frame := kont[len(kont)-1]
+		kont = kont[:len(kont)-1]
+		switch frame.Kind {
+		case K_Arg:
+			kont = append(kont, Frame{Kind: K_Apply, Value: value})
+			term = frame.Term
+			env = frame.Env
+			value = nil
+		case K_Apply, K_ApplyTo:
+			fun, arg := frame.Value, value
+			if frame.Kind == K_ApplyTo {
+				fun, arg = value, frame.Value
+			}
+			if lam, is_lam := fun.Term.(*CLam); is_lam {
+				env = fun.Env.Extend(arg)
+				term = lam.Body
+				value = nil
+				stats.Betas += 1
+			} else if g, is_lam := arg.Term.(*CLam); is_lam && fun.Term.CPrint() == PrimName(&LPrim{Op: PRIM_Fix}) {
+				// @FIX G ⇒ G (λV1.(@FIX G V1)), unless G needs its own value
+				if usedUnguarded(g.Body, 0) {
+					return MachineResult{Stats: stats}, fmt.Errorf("%w: %v is needed to compute its own definition under the CEK machine",
+						ErrIllFounded, g.Name)
+				}
+				delayed := &CLam{Name: "V1", Body: &CApp{
+					Fun: &CApp{Fun: fun.Term, Arg: Shift(g, 1, 0)},
+					Arg: &CVar{Index: 0, Name: "V1"},
+				}}
+				env = arg.Env.Extend(&Closure{Term: delayed, Env: arg.Env})
+				term = g.Body
+				value = nil
+				stats.Betas += 1
+			} else if fun.Term.CPrint() == PrimName(&LPrim{Op: PRIM_Shift}) {
+				// The frames up to the nearest @RESET become a function, which arg is applied to
+				// in their place
+				k, rest, capture_err := captureContinuation(kont)
+				if capture_err != nil {
+					return MachineResult{Stats: stats}, capture_err
+				}
+				kont = append(rest, Frame{Kind: K_ApplyTo, Value: k})
+				value = arg
+			} else if fun.Term.CPrint() == PrimName(&LPrim{Op: PRIM_Reset}) {
+				// Delimits nothing once its body is a value
+				value = arg
+			} else {
+				// Stuck application, build the neutral value directly
+				neutral, _ := deltaLiterals(&CApp{Fun: QuoteClosure(fun), Arg: QuoteClosure(arg)})
+				value = &Closure{Term: neutral, Env: nil}
+			}
+		case K_If:
+			_, args := CoreSpine(frame.Term)
+			cond, is_bool := DecodeLiteral(value.Term).(*LBool)
+			if !is_bool {
+				// Stuck on the condition, the @IF is a neutral value
+				quoted := []Core{QuoteClosure(value)}
+				for _, branch := range args[1:] {
+					quoted = append(quoted, QuoteClosure(&Closure{Term: branch, Env: frame.Env}))
+				}
+				value = &Closure{Term: CoreApply(PrimCore(&LPrim{Op: PRIM_If}), quoted...), Env: nil}
+				continue
+			}
+			term = args[2]
+			if cond.Value {
+				term = args[1]
+			}
+			env = frame.Env
+			value = nil
+		case K_Reset:
+			// The body returned normally, its value is the @RESET's
+		case K_Case:
+			case_head, args := CoreSpine(frame.Term)
+			arms, _ := ParseCaseName(case_head.CPrint())
+			head, fields := CoreSpine(value.Term)
+			con := ConstructorName(head)
+			arm := slices.IndexFunc(arms, func(arm CaseArm) bool { return arm.Con == con })
+			if arm < 0 || len(fields) != arms[arm].Arity {
+				// Stuck on the scrutinee, the case is a neutral value
+				quoted := []Core{QuoteClosure(value)}
+				for _, branch := range args[1:] {
+					quoted = append(quoted, QuoteClosure(&Closure{Term: branch, Env: frame.Env}))
+				}
+				value = &Closure{Term: CoreApply(&CFree{Name: CaseName(arms)}, quoted...), Env: nil}
+				continue
+			}
+			// The branch applied to the fields, values already: constructor applications are
+			// neutral, closed Core
+			for i := len(fields) - 1; i >= 0; i-- {
+				kont = append(kont, Frame{Kind: K_ApplyTo, Value: &Closure{Term: fields[i], Env: nil}})
+			}
+			stats.MaxStack = max(stats.MaxStack, len(kont))
+			term = args[1+arm]
+			env = frame.Env
+			value = nil
+		}
+	}
+}
+
+// Whether head applied to args is a whole case: scrutinee and one argument per branch.
+func isCaseApplication(head Core, args []Core) bool {
+	free, is_free := head.(*CFree)
+	if !is_free {
+		return false
+	}
+	arms, is_case := ParseCaseName(free.Name)
+	return is_case && len(args) == 1+len(arms)
+}
+
+// Evaluate a parsed term with the Krivine machine and read the result back.
+func EvalKrivine(l LExpr, max_steps int) (LExpr, MachineStats, error) {
+	c, lower_err := Lower(l)
+	if lower_err != nil {
+		return nil, MachineStats{}, lower_err
+	}
+	result, eval_err := KrivineEval(c, max_steps)
+	if eval_err != nil {
+		return nil, result.Stats, eval_err
+	}
+	return result.ReadBack(), result.Stats, nil
+}
+
+// Evaluate a parsed term with the CEK machine and read the result back.
+func EvalCEK(l LExpr, max_steps int) (LExpr, MachineStats, error) {
+	c, lower_err := Lower(l)
+	if lower_err != nil {
+		return nil, MachineStats{}, lower_err
+	}
+	result, eval_err := CEKEval(c, max_steps)
+	if eval_err != nil {
+		return nil, result.Stats, eval_err
+	}
+	return result.ReadBack(), result.Stats, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"testing"
+)
+
+const Test_Max_Steps = 1000000
+
+// A closed prelude term with a normal form. Lazy terms only have one because an argument
+// that diverges is never needed, so strict evaluators skip them. Prims terms need delta rules.
+type preludeCase struct {
+	NamedTerm
+	Lazy  bool
+	Prims bool
+}
+
+// Closed terms over the prelude, shared by the tests of every evaluator. Those named
+// "count ..." apply a Church numeral to an increment on literals, so that evaluators
+// stopping at a weak head normal form still compute the number all the way down.
+func preludeCases() []preludeCase {
+	prelude := LoadPrelude()
+	def := func(name string) Core {
+		return prelude.Cores[name]
+	}
+	n := ChurchNumeral
+	increment := &CLam{Name: "X1", Body: CoreApply(
+		PrimCore(&LPrim{Op: PRIM_Add}), &CVar{Index: 0, Name: "X1"}, PrimCore(&LInt{Value: 1}),
+	)}
+	count := func(numeral Core) Core {
+		return CoreApply(numeral, increment, PrimCore(&LInt{Value: 0}))
+	}
+	return []preludeCase{
+		{NamedTerm: NamedTerm{Name: "identity", Term: def("I")}},
+		{NamedTerm: NamedTerm{Name: "S K K", Term: CoreApply(def("S"), def("K"), def("K"))}},
+		{NamedTerm: NamedTerm{Name: "B I I", Term: CoreApply(def("B"), def("I"), def("I"))}},
+		{NamedTerm: NamedTerm{Name: "flip K", Term: CoreApply(def("FLIP"), def("K"), n(1), n(2))}},
+		{NamedTerm: NamedTerm{Name: "not true", Term: CoreApply(def("NOT"), def("TRUE"))}},
+		{NamedTerm: NamedTerm{Name: "and true false", Term: CoreApply(def("AND"), def("TRUE"), def("FALSE"))}},
+		{NamedTerm: NamedTerm{Name: "or false true", Term: CoreApply(def("OR"), def("FALSE"), def("TRUE"))}},
+		{NamedTerm: NamedTerm{Name: "fst pair", Term: CoreApply(def("FST"), CoreApply(def("PAIR"), n(1), n(2)))}},
+		{NamedTerm: NamedTerm{Name: "snd pair", Term: CoreApply(def("SND"), CoreApply(def("PAIR"), n(1), n(2)))}},
+		{NamedTerm: NamedTerm{Name: "succ 2", Term: CoreApply(def("SUCC"), n(2))}},
+		{NamedTerm: NamedTerm{Name: "plus 2 3", Term: CoreApply(def("PLUS"), n(2), n(3))}},
+		{NamedTerm: NamedTerm{Name: "mult 3 4", Term: CoreApply(def("MULT"), n(3), n(4))}},
+		{NamedTerm: NamedTerm{Name: "pow 2 3", Term: CoreApply(def("POW"), n(2), n(3))}},
+		{NamedTerm: NamedTerm{Name: "pred 4", Term: CoreApply(def("PRED"), n(4))}},
+		{NamedTerm: NamedTerm{Name: "iszero 0", Term: CoreApply(def("ISZERO"), def("ZERO"))}},
+		{NamedTerm: NamedTerm{Name: "iszero 2", Term: CoreApply(def("ISZERO"), n(2))}},
+		{NamedTerm: NamedTerm{Name: "2 2 I", Term: CoreApply(n(2), n(2), def("I"))}},
+		{NamedTerm: NamedTerm{Name: "count mult 3 4", Term: count(CoreApply(def("MULT"), n(3), n(4)))}, Prims: true},
+		{NamedTerm: NamedTerm{Name: "count pow 2 5", Term: count(CoreApply(def("POW"), n(2), n(5)))}, Prims: true},
+		{NamedTerm: NamedTerm{Name: "count pred 7", Term: count(CoreApply(def("PRED"), n(7)))}, Prims: true},
+		{NamedTerm: NamedTerm{Name: "K I omega", Term: CoreApply(def("K"), def("I"), def("OMEGA"))}, Lazy: true},
+		{NamedTerm: NamedTerm{Name: "false omega", Term: CoreApply(def("FALSE"), def("OMEGA"), n(3))}, Lazy: true},
+	}
+}
+
+func normalOrder(t *testing.T, c Core) Core {
+	t.Helper()
+	nf, _, reduce_err := Reduce(c, R_NormalOrder, Test_Max_Steps)
+	if reduce_err != nil {
+		t.Fatalf("normal order: %v", reduce_err)
+	}
+	return nf
+}
+
+// An evaluator stopping at a weak head normal form is right when what it stopped at is one,
+// and has the normal form of the term it started from.
+func checkWeakHeadResult(t *testing.T, term Core, got Core) {
+	t.Helper()
+	if !IsWeakHeadNormal(got) {
+		t.Errorf("%v is not in weak head normal form", got.CPrint())
+	}
+	want := normalOrder(t, term)
+	if got_nf := normalOrder(t, got); got_nf.CPrint() != want.CPrint() {
+		t.Errorf("result %v normalizes to %v, want %v", got.CPrint(), got_nf.CPrint(), want.CPrint())
+	}
+}
+
+// An evaluator computing normal forms is right when it finds the one of normal order.
+func checkNormalResult(t *testing.T, term Core, got Core) {
+	t.Helper()
+	if want := normalOrder(t, term); got.CPrint() != want.CPrint() {
+		t.Errorf("got %v, want %v", got.CPrint(), want.CPrint())
+	}
+}
+
+func TestMachinesAgreeWithNormalOrder(t *testing.T) {
+	machines := []struct {
+		name   string
+		strict bool
+		eval   func(c Core, max_steps int) (MachineResult, error)
+	}{
+		{"Krivine", false, KrivineEval},
+		{"CEK", true, CEKEval},
+	}
+	for _, machine := range machines {
+		for _, c := range preludeCases() {
+			if c.Lazy && machine.strict {
+				continue
+			}
+			t.Run(machine.name+"/"+c.Name, func(t *testing.T) {
+				result, eval_err := machine.eval(c.Term, Test_Max_Steps)
+				if eval_err != nil {
+					t.Fatal(eval_err)
+				}
+				checkWeakHeadResult(t, c.Term, result.Quote())
+			})
+		}
+	}
+}
+
+func TestMachinesCountToLiterals(t *testing.T) {
+	for _, c := range preludeCases() {
+		if !c.Prims {
+			continue
+		}
+		want := normalOrder(t, c.Term)
+		if _, is_int := DecodeLiteral(want).(*LInt); !is_int {
+			t.Fatalf("%v: normal order gives %v, not an integer", c.Name, want.CPrint())
+		}
+		krivine, krivine_err := KrivineEval(c.Term, Test_Max_Steps)
+		cek, cek_err := CEKEval(c.Term, Test_Max_Steps)
+		if krivine_err != nil || cek_err != nil {
+			t.Fatalf("%v: %v, %v", c.Name, krivine_err, cek_err)
+		}
+		for name, got := range map[string]Core{"Krivine": krivine.Quote(), "CEK": cek.Quote()} {
+			if got.CPrint() != want.CPrint() {
+				t.Errorf("%v: %v gives %v, want %v", c.Name, name, got.CPrint(), want.CPrint())
+			}
+		}
+	}
+}
+
+func TestCEKDivergesWhereStrict(t *testing.T) {
+	for _, c := range preludeCases() {
+		if !c.Lazy {
+			continue
+		}
+		if _, eval_err := CEKEval(c.Term, 10000); eval_err == nil {
+			t.Errorf("%v: call-by-value reached a result without evaluating the diverging argument", c.Name)
+		}
+	}
+}
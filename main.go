@@ -3,7 +3,9 @@ package main
 import (
 	"errors"
 	"fmt"
+	"os"
 	"strconv"
+	"unicode/utf8"
 )
 
 // NOTE: For copying: λ
@@ -57,7 +59,7 @@ func (l *LExpression) LPrint() string {
 
 func (l *LExpression) LAbstract(b LVar) LExpr {
 	out_exprs := []LExpr{l}
-	out_str := "λ" + b.LPrint() + "." + l.LPrint()
+	out_str := "λ" + b.BindingPrint() + "." + l.LPrint()
 
 	out_le := LExpression{
 		Binding: b,
@@ -74,7 +76,7 @@ func (l *LExpression) Copy() LExpr {
 		copy_expr[i] = expr.Copy()
 	}
 	return &LExpression{
-		Binding: LVar{Symbol: l.Binding.Symbol},
+		Binding: l.Binding,
 		Exprs:   copy_expr,
 		Repr:    l.Repr,
 	}
@@ -82,14 +84,12 @@ func (l *LExpression) Copy() LExpr {
 
 func (l *LExpression) LApply(b LVar, replace LExpr) LExpr {
 	new_exprs := make([]LExpr, len(l.Exprs))
-	new_repr := "λ" + l.Binding.LPrint() + ".("
 	for i, expr := range l.Exprs {
 		new_exprs[i] = expr.LApply(b, replace)
-		new_repr += new_exprs[i].LPrint()
 	}
-	new_repr += ")"
+	new_repr := "λ" + l.Binding.BindingPrint() + ".(" + SeqPrint(new_exprs) + ")"
 	return &LExpression{
-		Binding: LVar{Symbol: l.Binding.Symbol},
+		Binding: l.Binding,
 		Exprs:   new_exprs,
 		Repr:    new_repr,
 	}
@@ -102,12 +102,10 @@ func (l *LExpression) LEquals(l2 LExpr) bool {
 
 func LApplyInit(l1 LExpression, l2 LExpr) LExpression {
 	new_exprs := make([]LExpr, len(l1.Exprs))
-	new_repr := "("
 	for i, expr := range l1.Exprs {
 		new_exprs[i] = expr.LApply(l1.Binding, l2)
-		new_repr += new_exprs[i].LPrint()
 	}
-	new_repr += ")"
+	new_repr := "(" + SeqPrint(new_exprs) + ")"
 	return LExpression{
 		Binding: LVar{Symbol: ""},
 		Exprs:   new_exprs,
@@ -117,11 +115,7 @@ func LApplyInit(l1 LExpression, l2 LExpr) LExpression {
 }
 
 func ConcatenateLExprs(lexprs []LExpr) LExpression {
-	new_repr := "("
-	for _, expr := range lexprs {
-		new_repr += expr.LPrint()
-	}
-	new_repr += ")"
+	new_repr := "(" + SeqPrint(lexprs) + ")"
 	return LExpression{
 		Binding: LVar{},
 		Exprs:   lexprs,
@@ -131,16 +125,39 @@ func ConcatenateLExprs(lexprs []LExpr) LExpression {
 
 type LVar struct {
 	Symbol string
+	// Type annotation of a binding (λX1:A.), nil when untyped or for variable occurrences
+	Type LType
+	// The same annotation read as a term, for dependent types (λX1:NAT1., ΠA1:*.), nil
+	// when it does not parse as one
+	Domain LExpr
 }
 
 func (l *LVar) LPrint() string {
 	return l.Symbol
 }
 
+// Binding as written after λ, including its type annotation if any.
+func (l *LVar) BindingPrint() string {
+	if l.Type == nil && l.Domain == nil {
+		return l.Symbol
+	} else if l.Type == nil {
+		switch l.Domain.(type) {
+		case *LVar, *LUniverse:
+			return l.Symbol + ":" + l.Domain.LPrint()
+		}
+		return l.Symbol + ":" + parenthesized(l.Domain)
+	}
+	if _, is_forall := l.Type.(*TForall); is_forall {
+		// The "." of ∀A. would otherwise read as the end of the binding
+		return l.Symbol + ":(" + l.Type.TPrint() + ")"
+	}
+	return l.Symbol + ":" + l.Type.TPrint()
+}
+
 func (l *LVar) LAbstract(b LVar) LExpr {
 	Exprs := []LExpr{}
 	Exprs = append(Exprs, l)
-	out_str := "λ" + b.LPrint() + ".(" + l.LPrint() + ")"
+	out_str := "λ" + b.BindingPrint() + ".(" + l.LPrint() + ")"
 	out_expr := LExpression{
 		Binding: b,
 		Exprs:   Exprs,
@@ -202,14 +219,111 @@ func (l *LVar) LEquals(l2 LExpr) bool {
 // Goal: Successfully parse (a(b)Lc.(c)(d(e))f)
 type ParenTracker struct {
 	Counter int
+	// Offset and column of every "(" not yet closed, innermost last (Line is left 0)
+	Opened []Position
+	// Offset of the next string handed to Update
+	Position int
+	// Number of runes handed to Update so far
+	Runes int
+	// Inside a "..." string literal, whose parentheses do not count
+	InString bool
+	escaped  bool
 }
 
 func (p *ParenTracker) Update(s string) {
-	if s == "(" {
+	if p.InString {
+		if p.escaped {
+			p.escaped = false
+		} else if s == "\\" {
+			p.escaped = true
+		} else if s == "\"" {
+			p.InString = false
+		}
+	} else if s == "\"" {
+		p.InString = true
+	} else if s == "(" {
 		p.Counter += 1
+		p.Opened = append(p.Opened, Position{Column: p.Runes + 1, Offset: p.Position})
 	} else if s == ")" {
 		p.Counter -= 1
+		if len(p.Opened) > 0 {
+			p.Opened = p.Opened[:len(p.Opened)-1]
+		}
 	}
+	p.Position += len(s)
+	p.Runes += utf8.RuneCountInString(s)
+}
+
+// True when the last ")" handed to Update had no "(" to close.
+func (p *ParenTracker) IsStray() bool {
+	return p.Counter < 0
+}
+
+// Forget a stray ")" so that parsing can carry on as if it was never read.
+func (p *ParenTracker) DropStray() {
+	if p.Counter < 0 {
+		p.Counter = 0
+	}
+}
+
+// Diagnostic for the ")" just handed to Update when IsStray holds.
+func (p *ParenTracker) StrayError() *ParseError {
+	offset := p.Position - 1
+	return &ParseError{
+		Offset: offset,
+		Char:   ")",
+		Err: &ParenError{
+			Unclosed:   false,
+			Offset:     offset,
+			Column:     p.Runes,
+			Suggestion: "remove it, or add a matching '(' before it",
+		},
+	}
+}
+
+// Diagnostics for every "(" still open, outermost first.
+func (p *ParenTracker) UnclosedErrors() ParseErrorList {
+	unclosed := ParseErrorList{}
+	for i, opened := range p.Opened {
+		suggestion := "add ')' before the end of input"
+		if missing := len(p.Opened) - i; missing > 1 {
+			suggestion = fmt.Sprintf("add %v ')' before the end of input", missing)
+		}
+		unclosed = append(unclosed, &ParseError{
+			Offset: opened.Offset,
+			Char:   "(",
+			Err: &ParenError{
+				Unclosed:   true,
+				Offset:     opened.Offset,
+				Column:     opened.Column,
+				Suggestion: suggestion,
+			},
+		})
+	}
+	return unclosed
+}
+
+// ParenError describes an unbalanced parenthesis. Offset is a byte offset in the parsed
+// string and Column the 1-based rune count up to it, as every λ takes two bytes; Pos is
+// filled in by front-ends that know where the string came from.
+type ParenError struct {
+	// "(" never closed, otherwise a ")" with nothing to close
+	Unclosed   bool
+	Offset     int
+	Column     int
+	Pos        Position
+	Suggestion string
+}
+
+func (e *ParenError) Error() string {
+	where := fmt.Sprintf("column %v", e.Column)
+	if e.Pos.Line > 0 {
+		where = e.Pos.ToString()
+	}
+	if e.Unclosed {
+		return fmt.Sprintf("unclosed '(' opened at %v (%v)", where, e.Suggestion)
+	}
+	return fmt.Sprintf("unexpected ')' at %v (%v)", where, e.Suggestion)
 }
 
 func IsCapLetter(s string) bool {
@@ -240,8 +354,22 @@ type LambdaParser struct {
 	PState             string
 	ParenthesesTracker ParenTracker
 	LambdaBinding      string
-	CollectionStr      string
-	LExprArr           []LExpr
+	// Type annotation text read in state LT, and its parsed form once "." ends it
+	Annotation    string
+	BindingType   LType
+	CollectionStr string
+	LExprArr      []LExpr
+	// Optional sink for parse events, nil keeps the parse silent
+	Tracer ParseTracer
+	// Number of parentheticals enclosing this parse
+	Nesting int
+	// Offset and rune count of SrcStr before the first char of CollectionStr in state P
+	CollectionStart Position
+	// Recovery mode: collect errors into Errors and resynchronize instead of aborting
+	Recover bool
+	Errors  ParseErrorList
+	// Error node input is swallowed into while resynchronizing, nil otherwise
+	Skipping *LError
 }
 
 func CreateParser(s string) LambdaParser {
@@ -268,25 +396,33 @@ func (lp *LambdaParser) PParse(s string) error {
 	var p_err error
 	p_err = nil
 
-	if (lp.PState == "N") && (s == "(") && (lp.ParenthesesTracker.Counter == 0) {
-		lp.PState = "P"
-	} else if (lp.PState == "P") && (s != ")") {
+	// NOTE: ParenthesesTracker already includes s, so the opening ( of a top level
+	// parenthetical leaves the counter at 1 and its closing ) brings it back to 0
+	closing := (s == ")") && (lp.ParenthesesTracker.Counter == 0)
+	if (lp.PState == "N") && (s == "(") && (lp.ParenthesesTracker.Counter == 1) {
+		lp.StartCollection()
+	} else if (lp.PState == "P") && !closing {
 		lp.CollectionStr += s
-	} else if (lp.PState == "P") && (s == ")") && (lp.ParenthesesTracker.Counter == 0) {
+	} else if (lp.PState == "P") && closing {
 		nested_parser := CreateParser(lp.CollectionStr)
+		nested_parser.Tracer = lp.Tracer
+		nested_parser.Nesting = lp.Nesting + 1
+		nested_parser.Recover = lp.Recover
 		parsed_contents, nest_err := nested_parser.DriveParse()
 		if nest_err != nil {
 			// TODO: Wrap nest_err in more information
 			return nest_err
 		}
+		lp.AdoptErrors(nested_parser.Errors)
 		lexpression := ConcatenateLExprs(parsed_contents)
 		if len(lp.LambdaBinding) == 0 {
 			lp.LExprArr = append(lp.LExprArr, &lexpression)
 		}
 		if len(lp.LambdaBinding) != 0 {
-			new_lexpr := (&lexpression).LAbstract(LVar{lp.LambdaBinding})
+			new_lexpr := (&lexpression).LAbstract(LVar{Symbol: lp.LambdaBinding, Type: lp.BindingType})
 			lp.LExprArr = append(lp.LExprArr, new_lexpr)
 			lp.LambdaBinding = ""
+			lp.BindingType = nil
 		}
 		lp.CollectionStr = ""
 		lp.PState = "N"
@@ -297,6 +433,15 @@ func (lp *LambdaParser) PParse(s string) error {
 	return p_err
 }
 
+// Enter state P on the "(" just read, its contents are collected from the next char on.
+func (lp *LambdaParser) StartCollection() {
+	lp.PState = "P"
+	lp.CollectionStart = Position{
+		Offset: lp.ParenthesesTracker.Position,
+		Column: lp.ParenthesesTracker.Runes,
+	}
+}
+
 func (lp *LambdaParser) LParse(s string) error {
 	var l_err error
 	l_err = nil
@@ -309,8 +454,28 @@ func (lp *LambdaParser) LParse(s string) error {
 		lp.PState = "L2"
 	} else if (lp.PState == "L2") && (s == ".") {
 		lp.PState = "L3"
+	} else if (lp.PState == "L2") && (s == ":") {
+		lp.PState = "LT"
+	} else if (lp.PState == "LT") && (s == ".") && (lp.ParenthesesTracker.Counter == 0) {
+		// Annotations may contain parentheses, only a "." outside of them ends one
+		binding_type, type_err := ParseType(lp.Annotation)
+		if type_err != nil {
+			var parse_err *ParseError
+			if errors.As(type_err, &parse_err) {
+				// The tracker has already counted the "."
+				located := *parse_err
+				located.Offset += lp.ParenthesesTracker.Position - len(s) - len(lp.Annotation)
+				return &located
+			}
+			return type_err
+		}
+		lp.BindingType = binding_type
+		lp.Annotation = ""
+		lp.PState = "L3"
+	} else if lp.PState == "LT" {
+		lp.Annotation += s
 	} else if (lp.PState == "L3") && (s == "(") {
-		lp.PState = "P"
+		lp.StartCollection()
 	} else {
 		state := lp.PState + " Lambda Binding"
 		l_err = StateCharError(state, s)
@@ -330,21 +495,21 @@ func (lp *LambdaParser) VParse(s string) error {
 		lp.CollectionStr += s
 		lp.PState = "V2"
 	} else if (lp.PState == "V2") && IsCapLetter(s) {
-		lvar := LVar{lp.CollectionStr}
+		lvar := LVar{Symbol: lp.CollectionStr}
 		lp.LExprArr = append(lp.LExprArr, &lvar)
 		lp.CollectionStr = ""
 		lp.CollectionStr += s
 		lp.PState = "V1"
 	} else if (lp.PState == "V2") && (s == "L") {
-		lvar := LVar{lp.CollectionStr}
+		lvar := LVar{Symbol: lp.CollectionStr}
 		lp.LExprArr = append(lp.LExprArr, &lvar)
 		lp.CollectionStr = ""
 		lp.PState = "L1"
 	} else if (lp.PState == "V2") && (s == "(") {
-		lvar := LVar{lp.CollectionStr}
+		lvar := LVar{Symbol: lp.CollectionStr}
 		lp.LExprArr = append(lp.LExprArr, &lvar)
 		lp.CollectionStr = ""
-		lp.PState = "P"
+		lp.StartCollection()
 	} else {
 		state := lp.PState + " Variable Reading"
 		v_err = StateCharError(state, s)
@@ -357,44 +522,163 @@ func StateCharError(state, next_str string) error {
 	return errors.New(fmt.Sprintf(error_template, state, next_str))
 }
 
+// Reports err as a TE_Error event from the current state and hands it back unchanged.
+func (lp *LambdaParser) TraceFailure(err error, s string, offset int) error {
+	if lp.Tracer != nil {
+		lp.Tracer.Trace(TraceEvent{
+			Kind:    TE_Error,
+			From:    lp.PState,
+			Char:    s,
+			Offset:  offset,
+			Depth:   lp.ParenthesesTracker.Counter,
+			Nesting: lp.Nesting,
+			Err:     err,
+		})
+	}
+	return err
+}
+
+// DriveParseTraced parses as DriveParse does, reporting each state change to tracer.
+func (lp *LambdaParser) DriveParseTraced(tracer ParseTracer) ([]LExpr, error) {
+	lp.Tracer = tracer
+	return lp.DriveParse()
+}
+
+// Called once input runs out: keeps a trailing variable and reports anything left open. In
+// recovery mode an unclosed parenthetical is closed as if the missing ")" were present, so its
+// contents still show up (and get checked) in the tree.
+func (lp *LambdaParser) Finish() error {
+	if unclosed := lp.ParenthesesTracker.UnclosedErrors(); len(unclosed) > 0 {
+		if !lp.Recover {
+			return unclosed[0]
+		}
+		// Those opened within the parenthetical are reported by its own parse
+		lp.Errors = append(lp.Errors, unclosed[0])
+		lp.ParenthesesTracker.Counter = 0
+		lp.ParenthesesTracker.Opened = nil
+		if close_err := lp.PParse(")"); close_err != nil {
+			lp.LExprArr = append(lp.LExprArr, &LError{Err: unclosed[0]})
+		}
+		lp.CollectionStr = ""
+		lp.LambdaBinding = ""
+		lp.Annotation = ""
+		lp.BindingType = nil
+		lp.PState = "N"
+	}
+	if lp.PState == "V2" {
+		lvar := LVar{Symbol: lp.CollectionStr}
+		lp.LExprArr = append(lp.LExprArr, &lvar)
+		lp.CollectionStr = ""
+		lp.PState = "N"
+	}
+	if lp.PState != "N" {
+		end_err := &ParseError{
+			Offset: len(lp.SrcStr),
+			Err:    fmt.Errorf("Input ended in state %v before the expression was complete", lp.PState),
+		}
+		if !lp.Recover {
+			return end_err
+		}
+		lp.Errors = append(lp.Errors, end_err)
+		lp.LExprArr = append(lp.LExprArr, &LError{Err: end_err})
+		lp.LambdaBinding = ""
+		lp.Annotation = ""
+		lp.BindingType = nil
+		lp.CollectionStr = ""
+		lp.PState = "N"
+	}
+	return nil
+}
+
+// Hand s to the sub-parser for the current state, or to the one s starts from state N.
+func (lp *LambdaParser) Step(s string) error {
+	major_state := string(lp.PState[0])
+	if (major_state == "L") ||
+		((major_state == "N") && (s == "L")) {
+		return lp.LParse(s)
+	} else if (major_state == "P") ||
+		((major_state == "N") && (s == "(")) {
+		return lp.PParse(s)
+	} else if (major_state == "V") ||
+		((major_state == "N") && IsCapLetter(s)) {
+		return lp.VParse(s)
+	}
+	state := ""
+	if major_state == "L" {
+		state = "L Lambda Binding"
+	} else if major_state == "P" {
+		state = "P Parentheses-Enclosed Expr"
+	} else if major_state == "V" {
+		state = "V Reading Variable"
+	} else {
+		state = "N Neutral State"
+	}
+	return StateCharError(state, s)
+}
+
 func (lp *LambdaParser) DriveParse() ([]LExpr, error) {
 	for i, next_str_o := range lp.SrcStr {
-		fmt.Println("State", lp.PState, ": Parse covering ", lp.SrcStr[:i+1])
 		next_str := string(next_str_o)
-		major_state := string(lp.PState[0])
+		prev_state := lp.PState
 		lp.ParenthesesTracker.Update(next_str)
-		var step_err error
-		step_err = nil
-		if (major_state == "L") ||
-			((major_state == "N") && (next_str == "L")) {
-			step_err = lp.LParse(next_str)
-		} else if (major_state == "P") ||
-			((major_state == "N") && (next_str == "(")) {
-			step_err = lp.PParse(next_str)
-		} else if (major_state == "V") ||
-			((major_state == "N") && IsCapLetter(next_str)) {
-			step_err = lp.VParse(next_str)
-		} else {
-			state := ""
-			if major_state == "L" {
-				state = "L Lambda Binding"
-			} else if major_state == "P" {
-				state = "P Parentheses-Enclosed Expr"
-			} else if major_state == "V" {
-				state = "V Reading Variable"
-			} else {
-				state = "N Neutral State"
+		if lp.ParenthesesTracker.IsStray() {
+			stray_err := lp.TraceFailure(lp.ParenthesesTracker.StrayError(), next_str, i)
+			if !lp.Recover {
+				return nil, stray_err
 			}
-			step_err = StateCharError(state, next_str)
+			lp.RecoverFrom(stray_err, next_str, i)
+			continue
 		}
-		if step_err != nil {
-			return nil, step_err
+		if lp.Skipping != nil {
+			if !lp.Skipping.CanResync(next_str) {
+				lp.Skip(next_str)
+				continue
+			}
+			lp.Skipping = nil
+		}
+		if step_err := lp.Step(next_str); step_err != nil {
+			step_err = lp.TraceFailure(step_err, next_str, i)
+			if !lp.Recover {
+				return nil, step_err
+			}
+			lp.RecoverFrom(step_err, next_str, i)
+			continue
+		}
+		if lp.Tracer != nil {
+			lp.Tracer.Trace(TraceEvent{
+				Kind:    TE_Step,
+				From:    prev_state,
+				To:      lp.PState,
+				Char:    next_str,
+				Offset:  i,
+				Depth:   lp.ParenthesesTracker.Counter,
+				Nesting: lp.Nesting,
+			})
 		}
 	}
+	if end_err := lp.Finish(); end_err != nil {
+		return nil, lp.TraceFailure(end_err, "", len(lp.SrcStr))
+	}
+	if lp.Tracer != nil {
+		lp.Tracer.Trace(TraceEvent{
+			Kind:    TE_End,
+			From:    lp.PState,
+			To:      lp.PState,
+			Depth:   lp.ParenthesesTracker.Counter,
+			Nesting: lp.Nesting,
+		})
+	}
 	return lp.LExprArr, nil
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if cmd_err := RunCommand(os.Args[1:], os.Stdout); cmd_err != nil {
+			fmt.Fprintln(os.Stderr, cmd_err)
+			os.Exit(1)
+		}
+		return
+	}
 	fmt.Println("yo")
 
 	x := LVar{Symbol: "x"}
@@ -0,0 +1,226 @@
+package main
+
+import "fmt"
+
+/*
+	Normalization by evaluation
+
+Eval interprets a Core term into a semantic domain where lambdas are Go closures, so a beta
+step is a Go function call and no substitution ever happens. Terms that cannot reduce
+(free variables, or bound variables once we are under a binder) become SemNeutral values
+collecting their arguments. Quote reads a value back: a SemLam is applied to a fresh neutral
+for its bound variable and the result quoted under one more binder. The output is the full
+beta-normal form, including under binders and for open terms.
+
+Arguments are passed as SemThunks evaluated at most once, so this terminates on every term
+with a normal form (same as normal order) while sharing work like call-by-need.
+
+A primitive operator is a neutral until it has all of its arguments, then follows the delta
+rules of the reference reducer: its strict arguments are forced, and if they are literals it
+is defined on the application is replaced by its result, @IF by the chosen branch. @FIX G is
+G applied to a thunk of @FIX G. Terms using the control operators are rejected with
+ErrControl.
+*/
+type SemValue interface {
+	Quote(n *NbE, level int) Core
+}
+
+type SemLam struct {
+	Name string
+	Body func(arg *SemThunk) SemValue
+}
+
+// A free variable (Free set) or the bound variable introduced at Level, applied to Args.
+type SemNeutral struct {
+	Free  string
+	Level int
+	Name  string
+	Args  []*SemThunk
+}
+
+type SemThunk struct {
+	Term  Core
+	Env   *SemEnv
+	Value SemValue
+}
+
+type SemEnv struct {
+	Value *SemThunk
+	Next  *SemEnv
+}
+
+func (e *SemEnv) Extend(value *SemThunk) *SemEnv {
+	return &SemEnv{Value: value, Next: e}
+}
+
+func (e *SemEnv) Lookup(index int) *SemThunk {
+	for ; e != nil; e = e.Next {
+		if index == 0 {
+			return e.Value
+		}
+		index -= 1
+	}
+	return nil
+}
+
+type NbEStats struct {
+	// Applications of a SemLam, i.e. beta steps
+	Betas int
+	// Thunks forced for the first time
+	Forced int
+}
+
+type NbE struct {
+	Stats    NbEStats
+	MaxBetas int
+}
+
+// Raised (as a panic) to unwind out of nested Go closures once MaxBetas is exceeded.
+type nbeAbort struct {
+	err error
+}
+
+func (n *NbE) Force(thunk *SemThunk) SemValue {
+	if thunk.Value == nil {
+		n.Stats.Forced += 1
+		thunk.Value = n.Eval(thunk.Term, thunk.Env)
+		thunk.Env = nil
+	}
+	return thunk.Value
+}
+
+func (n *NbE) Eval(c Core, env *SemEnv) SemValue {
+	switch node := c.(type) {
+	case *CVar:
+		thunk := env.Lookup(node.Index)
+		if thunk == nil {
+			panic(nbeAbort{err: fmt.Errorf("Unbound de Bruijn index %v", node.Index)})
+		}
+		return n.Force(thunk)
+	case *CFree:
+		return &SemNeutral{Free: node.Name, Args: []*SemThunk{}}
+	case *CLam:
+		return &SemLam{Name: node.Name, Body: func(arg *SemThunk) SemValue {
+			return n.Eval(node.Body, env.Extend(arg))
+		}}
+	case *CApp:
+		return n.Apply(n.Eval(node.Fun, env), &SemThunk{Term: node.Arg, Env: env})
+	}
+	panic(nbeAbort{err: fmt.Errorf("Cannot evaluate Core %v of unknown type %T", c.CPrint(), c)})
+}
+
+func (n *NbE) Apply(fun SemValue, arg *SemThunk) SemValue {
+	switch f := fun.(type) {
+	case *SemLam:
+		if n.MaxBetas > 0 && n.Stats.Betas >= n.MaxBetas {
+			panic(nbeAbort{err: fmt.Errorf("%w: %v beta steps of NbE", ErrStepLimit, n.Stats.Betas)})
+		}
+		n.Stats.Betas += 1
+		return f.Body(arg)
+	case *SemNeutral:
+		args := append(f.Args[:len(f.Args):len(f.Args)], arg)
+		if len(f.Free) > 0 {
+			if result, reduced := n.delta(f.Free, args); reduced {
+				return result
+			}
+		}
+		return &SemNeutral{Free: f.Free, Level: f.Level, Name: f.Name, Args: args}
+	}
+	panic(nbeAbort{err: fmt.Errorf("Cannot apply semantic value of type %T", fun)})
+}
+
+// Value of the primitive named free applied to exactly as many args as it takes, false if
+// it is no such primitive or is stuck on them.
+func (n *NbE) delta(free string, args []*SemThunk) (SemValue, bool) {
+	prim, is_prim := DecodePrim(&CFree{Name: free})
+	op, is_op := prim.(*LPrim)
+	if !is_prim || !is_op || len(args) != op.Op.Arity() || op.Op.IsControl() {
+		return nil, false
+	}
+	if op.Op == PRIM_Fix {
+		// @FIX G ⇒ G (@FIX G), the inner one unfolding only once forced
+		again := &SemThunk{Term: &CApp{Fun: &CFree{Name: free}, Arg: &CVar{Index: 0}}, Env: (*SemEnv)(nil).Extend(args[0])}
+		return n.Apply(n.Force(args[0]), again), true
+	}
+	strict := len(args)
+	if op.Op == PRIM_If {
+		strict = 1
+	}
+	literals := []LExpr{}
+	for _, arg := range args[:strict] {
+		value, is_neutral := n.Force(arg).(*SemNeutral)
+		if !is_neutral || len(value.Free) == 0 || len(value.Args) > 0 {
+			return nil, false
+		}
+		literal := DecodeLiteral(&CFree{Name: value.Free})
+		if literal == nil {
+			return nil, false
+		}
+		literals = append(literals, literal)
+	}
+	if op.Op == PRIM_If {
+		cond, is_bool := literals[0].(*LBool)
+		if !is_bool {
+			return nil, false
+		} else if cond.Value {
+			return n.Force(args[1]), true
+		}
+		return n.Force(args[2]), true
+	}
+	result, delta_err := Delta(op.Op, literals[0], literals[1])
+	if delta_err != nil {
+		return nil, false
+	}
+	return &SemNeutral{Free: PrimName(result), Args: []*SemThunk{}}, true
+}
+
+func (v *SemLam) Quote(n *NbE, level int) Core {
+	bound := &SemThunk{Value: &SemNeutral{Level: level, Name: v.Name, Args: []*SemThunk{}}}
+	return &CLam{Name: v.Name, Body: v.Body(bound).Quote(n, level+1)}
+}
+
+func (v *SemNeutral) Quote(n *NbE, level int) Core {
+	var out Core
+	if len(v.Free) > 0 {
+		out = &CFree{Name: v.Free}
+	} else {
+		out = &CVar{Index: level - 1 - v.Level, Name: v.Name}
+	}
+	for _, arg := range v.Args {
+		out = &CApp{Fun: out, Arg: n.Force(arg).Quote(n, level)}
+	}
+	return out
+}
+
+// Beta-normal form of c by evaluation and read back. max_betas <= 0 means no limit, which
+// never returns for terms without a normal form.
+func NormalizeNbE(c Core, max_betas int) (nf Core, stats NbEStats, err error) {
+	if control_err := rejectControl(c, "NbE"); control_err != nil {
+		return nil, NbEStats{}, control_err
+	}
+	n := NbE{MaxBetas: max_betas}
+	defer func() {
+		if r := recover(); r != nil {
+			abort, is_abort := r.(nbeAbort)
+			if !is_abort {
+				panic(r)
+			}
+			nf, stats, err = nil, n.Stats, abort.err
+		}
+	}()
+	nf = n.Eval(c, nil).Quote(&n, 0)
+	return nf, n.Stats, nil
+}
+
+// Normalize a parsed term by evaluation and read the result back.
+func EvalNbE(l LExpr, max_betas int) (LExpr, NbEStats, error) {
+	c, lower_err := Lower(l)
+	if lower_err != nil {
+		return nil, NbEStats{}, lower_err
+	}
+	nf, stats, norm_err := NormalizeNbE(c, max_betas)
+	if norm_err != nil {
+		return nil, stats, norm_err
+	}
+	return Raise(nf), stats, nil
+}
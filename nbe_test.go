@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNbEAgreesWithNormalOrder(t *testing.T) {
+	for _, c := range preludeCases() {
+		t.Run(c.Name, func(t *testing.T) {
+			nf, _, norm_err := NormalizeNbE(c.Term, Test_Max_Steps)
+			if norm_err != nil {
+				t.Fatal(norm_err)
+			}
+			checkNormalResult(t, c.Term, nf)
+		})
+	}
+}
+
+func TestNbEOpenTerms(t *testing.T) {
+	prelude := LoadPrelude()
+	// λX1.(PLUS 2 X1) has a normal form under the binder, with X1 stuck
+	term := &CLam{Name: "X1", Body: CoreApply(prelude.Cores["PLUS"], ChurchNumeral(2), &CVar{Index: 0, Name: "X1"})}
+	nf, _, norm_err := NormalizeNbE(term, Test_Max_Steps)
+	if norm_err != nil {
+		t.Fatal(norm_err)
+	}
+	checkNormalResult(t, term, nf)
+	free := CoreApply(&CFree{Name: "F1"}, CoreApply(prelude.Cores["I"], &CFree{Name: "X1"}))
+	if nf, _, _ := NormalizeNbE(free, Test_Max_Steps); nf.CPrint() != "(F1 X1)" {
+		t.Errorf("got %v, want (F1 X1)", nf.CPrint())
+	}
+}
+
+func TestNbEStepLimit(t *testing.T) {
+	omega := LoadPrelude().Cores["OMEGA"]
+	if _, _, norm_err := NormalizeNbE(omega, 1000); !errors.Is(norm_err, ErrStepLimit) {
+		t.Errorf("got %v, want the step limit", norm_err)
+	}
+}
+
+func TestNbEDeltaRules(t *testing.T) {
+	for _, c := range deltaCases() {
+		nf, _, norm_err := NormalizeNbE(parseCore(t, c.Source), Test_Max_Steps)
+		if norm_err != nil {
+			t.Errorf("%v: %v", c.Source, norm_err)
+		} else if got := Raise(nf).LPrint(); got != c.Want {
+			t.Errorf("%v: got %v, want %v", c.Source, got, c.Want)
+		}
+	}
+	term := parseCore(t, "@SHIFT(LK1.(K1(1)))")
+	if _, _, norm_err := NormalizeNbE(term, Test_Max_Steps); !errors.Is(norm_err, ErrControl) {
+		t.Errorf("got %v, want ErrControl", norm_err)
+	}
+}
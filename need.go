@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+	Call-by-need (lazy Krivine machine)
+
+Like the Krivine machine, arguments are delayed, but as Thunks that are overwritten with
+their weak head normal form the first time they are forced. An update marker is pushed on
+the stack when a thunk starts evaluating and popped once a value reaches it. Applying a
+function to a variable reuses that variable's thunk instead of wrapping it in a new one, so
+(λx.x x)(expensive) evaluates expensive exactly once.
+
+Primitive operators follow the delta rules of the reference reducer, their strict arguments
+forced as thunks so a literal argument is computed once however often it is used, and @FIX G
+unfolds to G (@FIX G) as on the Krivine machine. Terms using the control operators are
+rejected with ErrControl.
+
+NeedNormalize keeps going under lambdas and into the arguments of stuck applications to
+reach the full normal form, still sharing every thunk, which is what Church numeral results
+need. Under a binder the bound variable is represented by an evaluated neutral thunk whose
+head is an internal CFree named "#<level>"; those names cannot come out of the parser.
+*/
+type Thunk struct {
+	Term Core
+	Env  *NeedEnv
+	// Term/Env hold a weak head normal form: a lambda, or a free head applied to Args
+	Evaluated bool
+	Args      []*Thunk
+}
+
+// Immutable linked list of thunks, index 0 being the most recently bound.
+type NeedEnv struct {
+	Value *Thunk
+	Next  *NeedEnv
+}
+
+func (e *NeedEnv) Extend(value *Thunk) *NeedEnv {
+	return &NeedEnv{Value: value, Next: e}
+}
+
+func (e *NeedEnv) Lookup(index int) *Thunk {
+	for ; e != nil; e = e.Next {
+		if index == 0 {
+			return e.Value
+		}
+		index -= 1
+	}
+	return nil
+}
+
+type NeedStats struct {
+	Steps int
+	Betas int
+	// Thunks allocated for arguments that were not already variables
+	ThunksCreated int
+	// Thunks evaluated (each at most once)
+	ThunksForced int
+	// Lookups answered by an already evaluated thunk, i.e. work saved by sharing
+	ThunksShared int
+}
+
+// Stack entries are either an argument, or a thunk waiting to be updated with the value
+// currently being computed.
+type needItem struct {
+	Arg    *Thunk
+	Update *Thunk
+}
+
+type NeedMachine struct {
+	Stats    NeedStats
+	MaxSteps int
+}
+
+func boundLevelName(level int) string {
+	return "#" + strconv.Itoa(level)
+}
+
+// Evaluate term in env to weak head normal form: either a lambda closure (no args) or a
+// free head with its argument thunks.
+func (m *NeedMachine) whnf(term Core, env *NeedEnv) (Core, *NeedEnv, []*Thunk, error) {
+	stack := []needItem{}
+	for {
+		if m.MaxSteps > 0 && m.Stats.Steps >= m.MaxSteps {
+			return nil, nil, nil, fmt.Errorf("%w: %v transitions of the call-by-need machine",
+				ErrStepLimit, m.Stats.Steps)
+		}
+		m.Stats.Steps += 1
+		switch node := term.(type) {
+		case *CApp:
+			var arg *Thunk
+			if arg_var, is_var := node.Arg.(*CVar); is_var && env.Lookup(arg_var.Index) != nil {
+				arg = env.Lookup(arg_var.Index)
+			} else {
+				arg = &Thunk{Term: node.Arg, Env: env}
+				m.Stats.ThunksCreated += 1
+			}
+			stack = append(stack, needItem{Arg: arg})
+			term = node.Fun
+			continue
+		case *CVar:
+			thunk := env.Lookup(node.Index)
+			if thunk == nil {
+				break
+			}
+			if thunk.Evaluated {
+				m.Stats.ThunksShared += 1
+				for i := len(thunk.Args) - 1; i >= 0; i-- {
+					stack = append(stack, needItem{Arg: thunk.Args[i]})
+				}
+			} else {
+				m.Stats.ThunksForced += 1
+				stack = append(stack, needItem{Update: thunk})
+			}
+			term = thunk.Term
+			env = thunk.Env
+			continue
+		case *CLam:
+			if len(stack) == 0 {
+				return term, env, nil, nil
+			}
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if top.Update != nil {
+				top.Update.Term = term
+				top.Update.Env = env
+				top.Update.Evaluated = true
+				continue
+			}
+			env = env.Extend(top.Arg)
+			term = node.Body
+			m.Stats.Betas += 1
+			continue
+		}
+		// Free head: its args run from the top of the stack down to the nearest update marker
+		args := []*Thunk{}
+		i := len(stack) - 1
+		for ; i >= 0 && stack[i].Update == nil; i-- {
+			args = append(args, stack[i].Arg)
+		}
+		if free, is_free := term.(*CFree); is_free && free.Name == PrimName(&LPrim{Op: PRIM_Fix}) && len(args) > 0 {
+			// @FIX G ⇒ G (@FIX G)
+			g := args[0]
+			stack[len(stack)-1] = needItem{Arg: &Thunk{Term: free, Evaluated: true, Args: []*Thunk{g}}}
+			term, env = &CVar{Index: 0}, (*NeedEnv)(nil).Extend(g)
+			continue
+		}
+		if op, is_op := machineOperator(term); is_op && len(args) >= op.Arity() {
+			result, delta_err := m.delta(op, args)
+			if delta_err != nil {
+				return nil, nil, nil, delta_err
+			} else if result != nil {
+				stack = stack[:len(stack)-op.Arity()]
+				term, env = &CVar{Index: 0}, (*NeedEnv)(nil).Extend(result)
+				continue
+			}
+		}
+		if i < 0 {
+			return term, env, args, nil
+		}
+		stack[i].Update.Term = term
+		stack[i].Update.Env = nil
+		stack[i].Update.Args = args
+		stack[i].Update.Evaluated = true
+		stack = append(stack[:i], stack[i+1:]...)
+	}
+}
+
+// What op applied to args reduces to, its strict arguments forced in place; nil when they
+// are not literals it is defined on.
+func (m *NeedMachine) delta(op PrimOp, args []*Thunk) (*Thunk, error) {
+	strict := op.Arity()
+	if op == PRIM_If {
+		strict = 1
+	}
+	literals := []LExpr{}
+	for _, arg := range args[:strict] {
+		// Through a variable, so that the thunk is updated with its value
+		head, _, head_args, arg_err := m.whnf(&CVar{Index: 0}, (*NeedEnv)(nil).Extend(arg))
+		if arg_err != nil {
+			return nil, arg_err
+		}
+		literal := DecodeLiteral(head)
+		if literal == nil || len(head_args) > 0 {
+			return nil, nil
+		}
+		literals = append(literals, literal)
+	}
+	if op == PRIM_If {
+		cond, is_bool := literals[0].(*LBool)
+		if !is_bool {
+			return nil, nil
+		} else if cond.Value {
+			return args[1], nil
+		}
+		return args[2], nil
+	}
+	result, delta_err := Delta(op, literals[0], literals[1])
+	if delta_err != nil {
+		return nil, nil
+	}
+	return &Thunk{Term: PrimCore(result), Evaluated: true}, nil
+}
+
+// Full normal form of term in env, depth being the number of binders already entered.
+func (m *NeedMachine) normalize(term Core, env *NeedEnv, depth int) (Core, error) {
+	head, head_env, args, whnf_err := m.whnf(term, env)
+	if whnf_err != nil {
+		return nil, whnf_err
+	}
+	if lam, is_lam := head.(*CLam); is_lam {
+		bound := &Thunk{Term: &CFree{Name: boundLevelName(depth)}, Evaluated: true}
+		body, body_err := m.normalize(lam.Body, head_env.Extend(bound), depth+1)
+		if body_err != nil {
+			return nil, body_err
+		}
+		return &CLam{Name: lam.Name, Body: body}, nil
+	}
+	out := head
+	if free, is_free := head.(*CFree); is_free && strings.HasPrefix(free.Name, "#") {
+		level, _ := strconv.Atoi(free.Name[1:])
+		out = &CVar{Index: depth - 1 - level}
+	}
+	for _, arg := range args {
+		// Go through a variable so that forcing the argument updates its thunk
+		arg_nf, arg_err := m.normalize(&CVar{Index: 0}, (*NeedEnv)(nil).Extend(arg), depth)
+		if arg_err != nil {
+			return nil, arg_err
+		}
+		out = &CApp{Fun: out, Arg: arg_nf}
+	}
+	return out, nil
+}
+
+// Substitute thunks back into a term without forcing anything.
+func (m *NeedMachine) quote(term Core, env *NeedEnv, depth int) Core {
+	switch node := term.(type) {
+	case *CVar:
+		if node.Index < depth {
+			return node
+		}
+		thunk := env.Lookup(node.Index - depth)
+		if thunk == nil {
+			return node
+		}
+		return Shift(m.quoteThunk(thunk), depth, 0)
+	case *CLam:
+		return &CLam{Name: node.Name, Body: m.quote(node.Body, env, depth+1)}
+	case *CApp:
+		return &CApp{Fun: m.quote(node.Fun, env, depth), Arg: m.quote(node.Arg, env, depth)}
+	}
+	return term
+}
+
+func (m *NeedMachine) quoteThunk(thunk *Thunk) Core {
+	out := m.quote(thunk.Term, thunk.Env, 0)
+	for _, arg := range thunk.Args {
+		out = &CApp{Fun: out, Arg: m.quoteThunk(arg)}
+	}
+	return out
+}
+
+// Call-by-need evaluation of c to weak head normal form, read back without forcing the
+// remaining thunks.
+func NeedEval(c Core, max_steps int) (Core, NeedStats, error) {
+	if control_err := rejectControl(c, "call-by-need"); control_err != nil {
+		return nil, NeedStats{}, control_err
+	}
+	m := NeedMachine{MaxSteps: max_steps}
+	head, env, args, whnf_err := m.whnf(c, nil)
+	if whnf_err != nil {
+		return nil, m.Stats, whnf_err
+	}
+	return m.quoteThunk(&Thunk{Term: head, Env: env, Args: args}), m.Stats, nil
+}
+
+// Call-by-need evaluation of c all the way to its normal form.
+func NeedNormalize(c Core, max_steps int) (Core, NeedStats, error) {
+	if control_err := rejectControl(c, "call-by-need"); control_err != nil {
+		return nil, NeedStats{}, control_err
+	}
+	m := NeedMachine{MaxSteps: max_steps}
+	nf, norm_err := m.normalize(c, nil, 0)
+	return nf, m.Stats, norm_err
+}
+
+// Normalize a parsed term by need and read the result back.
+func EvalNeed(l LExpr, max_steps int) (LExpr, NeedStats, error) {
+	c, lower_err := Lower(l)
+	if lower_err != nil {
+		return nil, NeedStats{}, lower_err
+	}
+	nf, stats, norm_err := NeedNormalize(c, max_steps)
+	if norm_err != nil {
+		return nil, stats, norm_err
+	}
+	return Raise(nf), stats, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNeedAgreesWithNormalOrder(t *testing.T) {
+	for _, c := range preludeCases() {
+		t.Run(c.Name, func(t *testing.T) {
+			whnf, _, eval_err := NeedEval(c.Term, Test_Max_Steps)
+			if eval_err != nil {
+				t.Fatal(eval_err)
+			}
+			checkWeakHeadResult(t, c.Term, whnf)
+			nf, _, norm_err := NeedNormalize(c.Term, Test_Max_Steps)
+			if norm_err != nil {
+				t.Fatal(norm_err)
+			}
+			checkNormalResult(t, c.Term, nf)
+		})
+	}
+}
+
+func TestNeedSharesArguments(t *testing.T) {
+	for _, workload := range evaluatorWorkloads() {
+		if workload.Name != "shared mult" {
+			continue
+		}
+		_, need_stats, need_err := NeedEval(workload.Term, Test_Max_Steps)
+		krivine, krivine_err := KrivineEval(workload.Term, Test_Max_Steps)
+		if need_err != nil || krivine_err != nil {
+			t.Fatalf("%v, %v", need_err, krivine_err)
+		}
+		if need_stats.ThunksShared == 0 {
+			t.Errorf("no thunk was shared")
+		}
+		if need_stats.Betas >= krivine.Stats.Betas {
+			t.Errorf("call-by-need took %v beta steps, call-by-name %v", need_stats.Betas, krivine.Stats.Betas)
+		}
+	}
+}
+
+func TestNeedDeltaRules(t *testing.T) {
+	for _, c := range deltaCases() {
+		nf, _, norm_err := NeedNormalize(parseCore(t, c.Source), Test_Max_Steps)
+		if norm_err != nil {
+			t.Errorf("%v: %v", c.Source, norm_err)
+		} else if got := Raise(nf).LPrint(); got != c.Want {
+			t.Errorf("%v: got %v, want %v", c.Source, got, c.Want)
+		}
+	}
+}
+
+func TestNeedRejectsControl(t *testing.T) {
+	term := parseCore(t, "+(1)(@RESET(LK1.(2)))")
+	if _, _, eval_err := NeedEval(term, Test_Max_Steps); !errors.Is(eval_err, ErrControl) {
+		t.Errorf("NeedEval gave %v, want ErrControl", eval_err)
+	}
+	if _, _, norm_err := NeedNormalize(term, Test_Max_Steps); !errors.Is(norm_err, ErrControl) {
+		t.Errorf("NeedNormalize gave %v, want ErrControl", norm_err)
+	}
+}
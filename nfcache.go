@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+)
+
+/*
+	Persistent normal form cache
+
+Normal forms are stored by the structural Hash of the hash-consed term (see CoreHash), so
+alpha-equivalent terms share an entry and keys are the same from one run to the next. The
+backing file is an append-only log with one JSON record per line, holding the canonical
+text (CPrint) of the term and of its normal form plus the number of normal order steps.
+Opening the cache replays the log; a record whose term text does not match on lookup is a
+hash collision and is ignored. A torn last line (a crash while appending) is skipped, and
+later records for the same term win.
+*/
+type CacheEntry struct {
+	Hash       string `json:"hash"`
+	Term       string `json:"term"`
+	NormalForm string `json:"normal_form"`
+	Steps      int    `json:"steps"`
+}
+
+type CacheStats struct {
+	// Records read from the log when it was opened
+	Loaded int
+	// Lines of the log that could not be decoded
+	Corrupt int
+	Hits    int
+	Misses  int
+	// Records appended since the cache was opened
+	Stored int
+}
+
+type NormalFormCache struct {
+	Path    string
+	Stats   CacheStats
+	mutex   sync.Mutex
+	file    *os.File
+	entries map[string][]CacheEntry
+}
+
+// Alpha-invariant key of c, the hex form of its hash-consed Hash.
+func CacheKey(c Core) string {
+	return strconv.FormatUint(CoreHash(c), 16)
+}
+
+// Open (creating if needed) the log at path and load every record in it.
+func OpenNormalFormCache(path string) (*NormalFormCache, error) {
+	file, open_err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if open_err != nil {
+		return nil, open_err
+	}
+	cache := &NormalFormCache{Path: path, file: file, entries: map[string][]CacheEntry{}}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		entry := CacheEntry{}
+		if json_err := json.Unmarshal(scanner.Bytes(), &entry); json_err != nil || len(entry.Hash) == 0 {
+			cache.Stats.Corrupt += 1
+			continue
+		}
+		cache.entries[entry.Hash] = append(cache.entries[entry.Hash], entry)
+		cache.Stats.Loaded += 1
+	}
+	if scan_err := scanner.Err(); scan_err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Reading normal form cache %v: %w", path, scan_err)
+	}
+	// Terminate a torn last line so the next record starts on a line of its own
+	info, stat_err := file.Stat()
+	if stat_err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, read_err := file.ReadAt(last, info.Size()-1); read_err == nil && last[0] != '\n' {
+			file.Write([]byte{'\n'})
+		}
+	}
+	return cache, nil
+}
+
+func (cache *NormalFormCache) Close() error {
+	return cache.file.Close()
+}
+
+// Cached normal form and step count of c, if any.
+func (cache *NormalFormCache) Lookup(c Core) (Core, int, bool) {
+	key, text := CacheKey(c), c.CPrint()
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	entries := cache.entries[key]
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Term != text {
+			continue
+		}
+		nf, parse_err := ParseCPrint(entries[i].NormalForm)
+		if parse_err != nil {
+			break
+		}
+		cache.Stats.Hits += 1
+		return nf, entries[i].Steps, true
+	}
+	cache.Stats.Misses += 1
+	return nil, 0, false
+}
+
+// Append the normal form of c to the log.
+func (cache *NormalFormCache) Store(c Core, nf Core, steps int) error {
+	entry := CacheEntry{Hash: CacheKey(c), Term: c.CPrint(), NormalForm: nf.CPrint(), Steps: steps}
+	line, json_err := json.Marshal(entry)
+	if json_err != nil {
+		return json_err
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if _, write_err := cache.file.Write(append(line, '\n')); write_err != nil {
+		return fmt.Errorf("Writing normal form cache %v: %w", cache.Path, write_err)
+	}
+	cache.entries[entry.Hash] = append(cache.entries[entry.Hash], entry)
+	cache.Stats.Stored += 1
+	return nil
+}
+
+// Normal form of c by normal order reduction, from the cache when possible. Terms that hit
+// the step limit are not cached.
+func (cache *NormalFormCache) Normalize(c Core, max_steps int) (Core, int, error) {
+	if nf, steps, cached := cache.Lookup(c); cached {
+		return nf, steps, nil
+	}
+	nf, steps, reduce_err := Reduce(c, R_NormalOrder, max_steps)
+	if reduce_err != nil {
+		return nf, steps, reduce_err
+	}
+	return nf, steps, cache.Store(c, nf, steps)
+}
+
+// Normalize a parsed term through the cache, reading the result back as an LExpr.
+func (cache *NormalFormCache) NormalizeLExpr(l LExpr, max_steps int) (LExpr, int, error) {
+	c, lower_err := Lower(l)
+	if lower_err != nil {
+		return nil, 0, lower_err
+	}
+	nf, steps, norm_err := cache.Normalize(c, max_steps)
+	if norm_err != nil {
+		return nil, steps, norm_err
+	}
+	return Raise(nf), steps, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNormalFormCacheSurvivesReopening(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "normal-forms.log")
+	prelude := LoadPrelude()
+	term := CoreApply(prelude.Cores["MULT"], ChurchNumeral(3), ChurchNumeral(4))
+	cache, open_err := OpenNormalFormCache(path)
+	if open_err != nil {
+		t.Fatal(open_err)
+	}
+	nf, steps, norm_err := cache.Normalize(term, Test_Max_Steps)
+	if norm_err != nil {
+		t.Fatal(norm_err)
+	}
+	if cache.Stats.Misses != 1 || cache.Stats.Stored != 1 {
+		t.Errorf("got %+v, want one miss and one record stored", cache.Stats)
+	}
+	cache.Close()
+
+	reopened, reopen_err := OpenNormalFormCache(path)
+	if reopen_err != nil {
+		t.Fatal(reopen_err)
+	}
+	defer reopened.Close()
+	if reopened.Stats.Loaded != 1 {
+		t.Errorf("loaded %v records, want 1", reopened.Stats.Loaded)
+	}
+	cached, cached_steps, hit := reopened.Lookup(term)
+	if !hit {
+		t.Fatalf("no hit for %v after reopening", term.CPrint())
+	}
+	if cached.CPrint() != nf.CPrint() || cached_steps != steps {
+		t.Errorf("got %v in %v steps, stored %v in %v", cached.CPrint(), cached_steps, nf.CPrint(), steps)
+	}
+	checkNormalResult(t, term, cached)
+}
+
+func TestNormalFormCacheKeys(t *testing.T) {
+	store := CreateTermStore()
+	for _, name := range []string{"S", "POW", "Y"} {
+		c := LoadPrelude().Cores[name]
+		if CacheKey(c) != CacheKey(store.Intern(c).Core()) || CoreHash(c) != store.Intern(c).Hash {
+			t.Errorf("%v: key differs from the hash of the interned term", name)
+		}
+	}
+	renamed := &CLam{Name: "A1", Body: &CVar{Index: 0, Name: "A1"}}
+	if CacheKey(renamed) != CacheKey(LoadPrelude().Cores["I"]) {
+		t.Errorf("alpha-equivalent terms have different keys")
+	}
+}
+
+func TestNormalFormCacheSkipsTornAndCollidingRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "normal-forms.log")
+	id := LoadPrelude().Cores["I"]
+	k := LoadPrelude().Cores["K"]
+	// A record under the key of I for another term, then a torn line
+	log := `{"hash":"` + CacheKey(id) + `","term":"λλ1","normal_form":"λλ1","steps":0}` + "\n" + `{"hash":"`
+	if write_err := os.WriteFile(path, []byte(log), 0644); write_err != nil {
+		t.Fatal(write_err)
+	}
+	cache, open_err := OpenNormalFormCache(path)
+	if open_err != nil {
+		t.Fatal(open_err)
+	}
+	if cache.Stats.Loaded != 1 || cache.Stats.Corrupt != 1 {
+		t.Errorf("got %+v, want one record loaded and one corrupt", cache.Stats)
+	}
+	if _, _, hit := cache.Lookup(id); hit {
+		t.Errorf("colliding record answered for I")
+	}
+	if store_err := cache.Store(k, k, 0); store_err != nil {
+		t.Fatal(store_err)
+	}
+	cache.Close()
+	reopened, reopen_err := OpenNormalFormCache(path)
+	if reopen_err != nil {
+		t.Fatal(reopen_err)
+	}
+	defer reopened.Close()
+	if _, _, hit := reopened.Lookup(k); !hit {
+		t.Errorf("record appended after a torn line was lost")
+	}
+}
+
+func TestEvalCommandCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "normal-forms.log")
+	args := []string{"--cache", path, "(+(40)(2))"}
+	var first, second strings.Builder
+	if eval_err := RunCommand(append([]string{"eval"}, args...), &first); eval_err != nil {
+		t.Fatal(eval_err)
+	}
+	if eval_err := RunCommand(append([]string{"eval"}, args...), &second); eval_err != nil {
+		t.Fatal(eval_err)
+	}
+	if strings.Contains(first.String(), "cached") || !strings.Contains(second.String(), "cached") {
+		t.Errorf("first run printed\n%vsecond run printed\n%v", first.String(), second.String())
+	}
+	if eval_err := RunCommand([]string{"eval", "--cache", path, "cbv", "(+(40)(2))"}, &first); eval_err == nil {
+		t.Errorf("--cache accepted with call-by-value")
+	}
+}
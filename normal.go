@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	Normal forms and Böhm trees
+
+Three notions of "done", from strongest to weakest:
+  - normal form: no redex anywhere
+  - head normal form: λx1...λxk. h a1 ... an with h a variable; the ai may still contain
+    redexes
+  - weak head normal form: a lambda (whatever its body), or a variable applied to arguments
+
+Each has a predicate and a reducer that stops as soon as it is reached: normal order,
+head reduction and call-by-name respectively. The predicates ask the reducer, so a primitive
+it would take a delta step on, as in +(1)(2), is not done either. A term without a head
+normal form is unsolvable (Ω, Y K, ...), and all unsolvable terms are identified in the Böhm
+tree of a term, the possibly infinite tree obtained by taking the head normal form and
+recursing into its arguments. BohmApproximation cuts that tree at a depth, writing ⊥ both for
+unsolvable subterms and for subtrees below the cut.
+*/
+func IsNormal(c Core) bool {
+	_, stepped := Step(c, R_NormalOrder)
+	return !stepped
+}
+
+func IsHeadNormal(c Core) bool {
+	_, stepped := Step(c, R_HeadReduction)
+	return !stepped
+}
+
+func IsWeakHeadNormal(c Core) bool {
+	_, stepped := Step(c, R_CallByName)
+	return !stepped
+}
+
+// Normal form by normal order, with the number of beta steps.
+func ReduceToNormal(c Core, max_steps int) (Core, int, error) {
+	return Reduce(c, R_NormalOrder, max_steps)
+}
+
+// Head normal form by head reduction.
+func ReduceToHeadNormal(c Core, max_steps int) (Core, int, error) {
+	return Reduce(c, R_HeadReduction, max_steps)
+}
+
+// Weak head normal form by call-by-name.
+func ReduceToWeakHeadNormal(c Core, max_steps int) (Core, int, error) {
+	return Reduce(c, R_CallByName, max_steps)
+}
+
+// A node of a Böhm tree approximation: ⊥, or λ Binders. Head Children.
+type BohmTree struct {
+	Bottom bool
+	// Ran out of steps looking for a head normal form, as opposed to cut at the depth bound
+	Unsolvable bool
+	Binders    []string
+	// CVar (indices counting every enclosing binder) or CFree
+	Head     Core
+	Children []*BohmTree
+}
+
+// Böhm tree of c down to depth levels of head normal forms. A subterm whose head normal
+// form takes more than max_steps steps of head reduction is taken to be unsolvable.
+func BohmApproximation(c Core, depth int, max_steps int) *BohmTree {
+	if depth <= 0 {
+		return &BohmTree{Bottom: true}
+	}
+	hnf, _, reduce_err := ReduceToHeadNormal(c, max_steps)
+	if reduce_err != nil {
+		return &BohmTree{Bottom: true, Unsolvable: true}
+	}
+	tree := &BohmTree{Binders: []string{}, Children: []*BohmTree{}}
+	for {
+		lam, is_lam := hnf.(*CLam)
+		if !is_lam {
+			break
+		}
+		tree.Binders = append(tree.Binders, lam.Name)
+		hnf = lam.Body
+	}
+	head, args := CoreSpine(hnf)
+	tree.Head = head
+	for _, arg := range args {
+		tree.Children = append(tree.Children, BohmApproximation(arg, depth-1, max_steps))
+	}
+	return tree
+}
+
+// Core form of the approximation, ⊥ being the free variable "⊥".
+func (t *BohmTree) Core() Core {
+	if t.Bottom {
+		return &CFree{Name: "⊥"}
+	}
+	children := []Core{}
+	for _, child := range t.Children {
+		children = append(children, child.Core())
+	}
+	out := CoreApply(t.Head, children...)
+	for i := len(t.Binders) - 1; i >= 0; i-- {
+		out = &CLam{Name: t.Binders[i], Body: out}
+	}
+	return out
+}
+
+func (t *BohmTree) ToString() string {
+	return Raise(t.Core()).LPrint()
+}
+
+// Indented outline of the tree, one node per line.
+func (t *BohmTree) Outline() string {
+	var b strings.Builder
+	free := map[string]bool{}
+	CoreFreeNames(t.Core(), free)
+	var write func(t *BohmTree, scope []string, indent int)
+	write = func(t *BohmTree, scope []string, indent int) {
+		b.WriteString(strings.Repeat("  ", indent))
+		if t.Bottom {
+			b.WriteString("⊥\n")
+			return
+		}
+		used := map[string]bool{}
+		for name := range free {
+			used[name] = true
+		}
+		for _, name := range scope {
+			used[name] = true
+		}
+		scope = scope[:len(scope):len(scope)]
+		for _, binder := range t.Binders {
+			name := FreshName(binder, used)
+			used[name] = true
+			scope = append(scope, name)
+			fmt.Fprintf(&b, "λ%v.", name)
+		}
+		b.WriteString(raiseAtom(t.Head, scope).LPrint())
+		b.WriteString("\n")
+		for _, child := range t.Children {
+			write(child, scope, indent+1)
+		}
+	}
+	write(t, []string{}, 0)
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+)
+
+const Test_Bohm_Steps = 1000
+
+func TestNormalFormPredicates(t *testing.T) {
+	omega := "(LY1.(Y1Y1))(LY1.(Y1Y1))"
+	cases := []struct {
+		source                  string
+		normal, head, weak_head bool
+	}{
+		{"LX1.(" + omega + ")", false, false, true},
+		{"X1(" + omega + ")", false, true, true},
+		{omega, false, false, false},
+		{"LX1.(X1(LY1.(Y1)))", true, true, true},
+		{"LX1.((LY1.(Y1))(X1))", false, false, true},
+		// Delta redexes count as much as beta ones
+		{"+(1)(2)", false, false, false},
+		{"LX1.(+(1)(2))", false, false, true},
+		{"X1(+(1)(2))", false, true, true},
+		// Stuck primitives are done
+		{"+(1)(@TRUE)", true, true, true},
+		{"+(X1)(1)", true, true, true},
+	}
+	for _, c := range cases {
+		term := parseCore(t, c.source)
+		if got := IsNormal(term); got != c.normal {
+			t.Errorf("IsNormal(%v) = %v, want %v", c.source, got, c.normal)
+		}
+		if got := IsHeadNormal(term); got != c.head {
+			t.Errorf("IsHeadNormal(%v) = %v, want %v", c.source, got, c.head)
+		}
+		if got := IsWeakHeadNormal(term); got != c.weak_head {
+			t.Errorf("IsWeakHeadNormal(%v) = %v, want %v", c.source, got, c.weak_head)
+		}
+	}
+	if nf := normalOrder(t, parseCore(t, "+(1)(2)")); !IsNormal(nf) {
+		t.Errorf("IsNormal(%v) = false on the normal form Reduce stops at", nf.CPrint())
+	}
+}
+
+func TestBohmApproximationOfUnsolvables(t *testing.T) {
+	prelude := LoadPrelude()
+	cases := []NamedTerm{
+		{Name: "Ω", Term: prelude.Cores["OMEGA"]},
+		{Name: "Y K", Term: CoreApply(prelude.Cores["Y"], prelude.Cores["K"])},
+	}
+	for _, c := range cases {
+		tree := BohmApproximation(c.Term, 5, Test_Bohm_Steps)
+		if !tree.Bottom || !tree.Unsolvable || tree.ToString() != "⊥" {
+			t.Errorf("%v: got %v, want an unsolvable ⊥", c.Name, tree.ToString())
+		}
+	}
+}
+
+func TestBohmApproximationDepth(t *testing.T) {
+	// λX1.X1 (λZ1.Z1) Ω, both arguments cut at depth 1
+	term := parseCore(t, "LX1.(X1(LZ1.(Z1))((LY1.(Y1Y1))(LY1.(Y1Y1))))")
+	cases := []struct {
+		depth int
+		want  string
+	}{
+		{0, "⊥"},
+		{1, "λ((0 ⊥) ⊥)"},
+		{2, "λ((0 λ0) ⊥)"},
+	}
+	for _, c := range cases {
+		if got := BohmApproximation(term, c.depth, Test_Bohm_Steps).Core().CPrint(); got != c.want {
+			t.Errorf("depth %v: got %v, want %v", c.depth, got, c.want)
+		}
+	}
+	// Cut at depth 1 is not unsolvable, only Ω is at depth 2
+	for depth, want := range map[int]bool{1: false, 2: true} {
+		if got := BohmApproximation(term, depth, Test_Bohm_Steps).Children[1].Unsolvable; got != want {
+			t.Errorf("depth %v: Ω unsolvable is %v, want %v", depth, got, want)
+		}
+	}
+}
+
+func TestBohmTreeOutline(t *testing.T) {
+	term := parseCore(t, "LX1.(X1(LX1.(X1(Z1)))((LY1.(Y1Y1))(LY1.(Y1Y1))))")
+	want := "λX1.X1\n  λX2.X2\n    Z1\n  ⊥\n"
+	if got := BohmApproximation(term, 3, Test_Bohm_Steps).Outline(); got != want {
+		t.Errorf("got\n%v\nwant\n%v", got, want)
+	}
+}
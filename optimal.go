@@ -0,0 +1,456 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+/*
+	Optimal reduction with interaction nets (experimental)
+
+A term is translated into a graph of nodes with one principal port and up to two auxiliary
+ports, linked by wires. Two nodes connected through their principal ports form an active
+pair, rewritten by a local rule depending only on the two node kinds:
+
+  - N_Lam (0 the lambda, 1 its variable, 2 its body) meets N_App (0 the function, 1 the
+    argument, 2 the result): a beta step that just rewires body to result and variable to
+    argument
+  - N_Fan (0 the shared value, 1 and 2 the copies) duplicates whatever it meets, or
+    annihilates with a fan of the same label
+  - N_Era erases whatever it meets
+
+A variable used more than once is shared through a tree of fans labelled by its binder, so
+work inside a shared argument is done once for all copies (Lévy optimal sharing). This is
+the abstract algorithm, without Lamping's bracket and croissant nodes: it is correct for the
+terms we care about here (Church numerals and their arithmetic), but fans of the same label
+can meet wrongly on terms that are not elementary affine typable. The net then gets stuck
+with a fan read through its principal port, loops without end, or reads back as a cyclic
+or unnormalized term. NormalizeOptimal detects all of these and falls back to NormalizeNbE,
+recording why in NetStats.Fallback; NormalizeNet is the bare algorithm, and CompareOptimal
+checks every normal form it reads back against NormalizeNbE.
+
+Active pairs are reduced wherever they are, including inside garbage that is still being
+erased, so terms whose normal form needs a diverging argument to be discarded may hit the
+interaction limit.
+*/
+type NetNodeKind int
+
+const (
+	_ NetNodeKind = iota
+	N_Root
+	N_Lam
+	N_App
+	// Free variable, a principal port only
+	N_Free
+	N_Fan
+	N_Era
+)
+
+func (k NetNodeKind) ToString() string {
+	switch k {
+	case N_Root:
+		return "root"
+	case N_Lam:
+		return "lam"
+	case N_App:
+		return "app"
+	case N_Fan:
+		return "fan"
+	case N_Era:
+		return "era"
+	case N_Free:
+		return "free"
+	}
+	return "unknown"
+}
+
+type NetPort struct {
+	Node int
+	Slot int
+}
+
+type NetNode struct {
+	Kind  NetNodeKind
+	Ports [3]NetPort
+	// Fan label, or the binder name of a lambda
+	Label int
+	Name  string
+	Dead  bool
+}
+
+type NetStats struct {
+	// Rewrites of active pairs of any kind
+	Interactions int
+	// Lam/App annihilations, the net's beta steps
+	Betas int
+	// Fan/fan annihilations of the same label
+	Annihilations int
+	// Duplications of a node by a fan
+	Commutations int
+	// Interactions with an eraser
+	Erasures  int
+	MaxNodes  int
+	LiveNodes int
+	// Why the net failed, when NormalizeOptimal fell back to NormalizeNbE
+	Fallback error
+}
+
+type Net struct {
+	Nodes   []NetNode
+	Root    int
+	Stats   NetStats
+	redexes [][2]int
+	labels  int
+}
+
+func (n *Net) newNode(kind NetNodeKind) int {
+	n.Nodes = append(n.Nodes, NetNode{Kind: kind})
+	n.Stats.LiveNodes += 1
+	n.Stats.MaxNodes = max(n.Stats.MaxNodes, n.Stats.LiveNodes)
+	return len(n.Nodes) - 1
+}
+
+func (n *Net) free(node int) {
+	n.Nodes[node].Dead = true
+	n.Stats.LiveNodes -= 1
+}
+
+// Peer of port p, i.e. the port at the other end of its wire.
+func (n *Net) Peer(p NetPort) NetPort {
+	return n.Nodes[p.Node].Ports[p.Slot]
+}
+
+func (n *Net) Link(a NetPort, b NetPort) {
+	n.Nodes[a.Node].Ports[a.Slot] = b
+	n.Nodes[b.Node].Ports[b.Slot] = a
+	if a.Slot == 0 && b.Slot == 0 && a.Node != n.Root && b.Node != n.Root {
+		n.redexes = append(n.redexes, [2]int{a.Node, b.Node})
+	}
+}
+
+// Occurrences of a bound variable still waiting to be wired to their binder.
+type netBinder struct {
+	Lam  int
+	Uses []NetPort
+}
+
+// Translate a Core term into a net whose root is wired to the term.
+func BuildNet(c Core) *Net {
+	n := &Net{Nodes: []NetNode{}, redexes: [][2]int{}}
+	n.Root = n.newNode(N_Root)
+	n.translate(c, NetPort{Node: n.Root, Slot: 0}, []*netBinder{})
+	return n
+}
+
+// Wire the term c to dest, scope holding the innermost binder last.
+func (n *Net) translate(c Core, dest NetPort, scope []*netBinder) {
+	switch node := c.(type) {
+	case *CVar:
+		binder := scope[len(scope)-1-node.Index]
+		binder.Uses = append(binder.Uses, dest)
+	case *CFree:
+		free := n.newNode(N_Free)
+		n.Nodes[free].Name = node.Name
+		n.Link(NetPort{Node: free, Slot: 0}, dest)
+	case *CLam:
+		lam := n.newNode(N_Lam)
+		n.Nodes[lam].Name = node.Name
+		binder := &netBinder{Lam: lam, Uses: []NetPort{}}
+		n.translate(node.Body, NetPort{Node: lam, Slot: 2}, append(scope, binder))
+		n.share(NetPort{Node: lam, Slot: 1}, binder.Uses)
+		n.Link(NetPort{Node: lam, Slot: 0}, dest)
+	case *CApp:
+		app := n.newNode(N_App)
+		n.translate(node.Fun, NetPort{Node: app, Slot: 0}, scope)
+		n.translate(node.Arg, NetPort{Node: app, Slot: 1}, scope)
+		n.Link(NetPort{Node: app, Slot: 2}, dest)
+	}
+}
+
+// Wire a variable port to all its uses, erasing it if unused and sharing it through a chain
+// of fans with one fresh label if used more than once.
+func (n *Net) share(variable NetPort, uses []NetPort) {
+	switch len(uses) {
+	case 0:
+		era := n.newNode(N_Era)
+		n.Link(NetPort{Node: era, Slot: 0}, variable)
+		return
+	case 1:
+		n.Link(variable, uses[0])
+		return
+	}
+	n.labels += 1
+	label := n.labels
+	for i := 0; i < len(uses)-1; i++ {
+		fan := n.newNode(N_Fan)
+		n.Nodes[fan].Label = label
+		n.Link(NetPort{Node: fan, Slot: 0}, variable)
+		n.Link(NetPort{Node: fan, Slot: 1}, uses[i])
+		variable = NetPort{Node: fan, Slot: 2}
+	}
+	n.Link(variable, uses[len(uses)-1])
+}
+
+// Rewrite active pairs until none is left. max_interactions <= 0 means no limit.
+func (n *Net) Reduce(max_interactions int) error {
+	for len(n.redexes) > 0 {
+		if max_interactions > 0 && n.Stats.Interactions >= max_interactions {
+			return fmt.Errorf("%w: %v interactions", ErrStepLimit, n.Stats.Interactions)
+		}
+		pair := n.redexes[len(n.redexes)-1]
+		n.redexes = n.redexes[:len(n.redexes)-1]
+		a, b := pair[0], pair[1]
+		if n.Nodes[a].Dead || n.Nodes[b].Dead || n.Peer(NetPort{Node: a, Slot: 0}) != (NetPort{Node: b, Slot: 0}) {
+			continue
+		}
+		n.interact(a, b)
+	}
+	return nil
+}
+
+func (n *Net) interact(a int, b int) {
+	ka, kb := n.Nodes[a].Kind, n.Nodes[b].Kind
+	if ka > kb {
+		a, b, ka, kb = b, a, kb, ka
+	}
+	switch {
+	case ka == N_Lam && kb == N_App:
+		n.Stats.Betas += 1
+		n.Link(n.Peer(NetPort{Node: a, Slot: 2}), n.Peer(NetPort{Node: b, Slot: 2}))
+		n.Link(n.Peer(NetPort{Node: a, Slot: 1}), n.Peer(NetPort{Node: b, Slot: 1}))
+	case ka == N_Fan && kb == N_Fan && n.Nodes[a].Label == n.Nodes[b].Label:
+		n.Stats.Annihilations += 1
+		n.Link(n.Peer(NetPort{Node: a, Slot: 1}), n.Peer(NetPort{Node: b, Slot: 1}))
+		n.Link(n.Peer(NetPort{Node: a, Slot: 2}), n.Peer(NetPort{Node: b, Slot: 2}))
+	case kb == N_Fan && (ka == N_Lam || ka == N_App || ka == N_Fan):
+		n.Stats.Commutations += 1
+		n.commute(a, b)
+		return
+	case ka == N_Free && kb == N_Fan:
+		n.Stats.Commutations += 1
+		for slot := 1; slot <= 2; slot++ {
+			copied := n.newNode(N_Free)
+			n.Nodes[copied].Name = n.Nodes[a].Name
+			n.Link(NetPort{Node: copied, Slot: 0}, n.Peer(NetPort{Node: b, Slot: slot}))
+		}
+	case kb == N_Era:
+		n.Stats.Erasures += 1
+		for slot := 1; slot <= n.arity(a); slot++ {
+			era := n.newNode(N_Era)
+			n.Link(NetPort{Node: era, Slot: 0}, n.Peer(NetPort{Node: a, Slot: slot}))
+		}
+	default:
+		// No rule, e.g. a free variable in function position: the pair is stuck
+		return
+	}
+	n.Stats.Interactions += 1
+	n.free(a)
+	n.free(b)
+}
+
+func (n *Net) arity(node int) int {
+	switch n.Nodes[node].Kind {
+	case N_Lam, N_App, N_Fan:
+		return 2
+	}
+	return 0
+}
+
+// x (a lam, app or fan of another label) meets fan f: each is copied once per auxiliary
+// port of the other and the copies are cross-wired.
+func (n *Net) commute(x int, f int) {
+	n.Stats.Interactions += 1
+	xs := [3]int{}
+	fs := [3]int{}
+	for i := 1; i <= 2; i++ {
+		xs[i] = n.newNode(n.Nodes[x].Kind)
+		n.Nodes[xs[i]].Label = n.Nodes[x].Label
+		n.Nodes[xs[i]].Name = n.Nodes[x].Name
+		fs[i] = n.newNode(N_Fan)
+		n.Nodes[fs[i]].Label = n.Nodes[f].Label
+	}
+	for i := 1; i <= 2; i++ {
+		n.Link(NetPort{Node: xs[i], Slot: 0}, n.Peer(NetPort{Node: f, Slot: i}))
+		n.Link(NetPort{Node: fs[i], Slot: 0}, n.Peer(NetPort{Node: x, Slot: i}))
+		for j := 1; j <= 2; j++ {
+			n.Link(NetPort{Node: xs[i], Slot: j}, NetPort{Node: fs[j], Slot: i})
+		}
+	}
+	n.free(x)
+	n.free(f)
+}
+
+// Read the reduced net back as a term, following wires from the root. Fans are crossed by
+// remembering, per label, which copy we entered through. A net mangled by fans meeting
+// wrongly can loop, so at most max_visits ports are visited (<= 0 means no limit).
+func (n *Net) ReadBack(max_visits int) (Core, error) {
+	levels := map[int]int{}
+	stacks := map[int][]int{}
+	visits := 0
+	var read func(p NetPort, depth int) (Core, error)
+	read = func(p NetPort, depth int) (Core, error) {
+		if max_visits > 0 && visits >= max_visits {
+			return nil, fmt.Errorf("%w: %v ports visited reading the net back", ErrStepLimit, visits)
+		}
+		visits += 1
+		node := &n.Nodes[p.Node]
+		switch {
+		case node.Kind == N_Lam && p.Slot == 0:
+			levels[p.Node] = depth
+			body, body_err := read(n.Peer(NetPort{Node: p.Node, Slot: 2}), depth+1)
+			if body_err != nil {
+				return nil, body_err
+			}
+			return &CLam{Name: node.Name, Body: body}, nil
+		case node.Kind == N_Lam && p.Slot == 1:
+			level, bound := levels[p.Node]
+			if !bound {
+				return nil, fmt.Errorf("Variable of a lambda reached outside of its body")
+			}
+			return &CVar{Index: depth - 1 - level, Name: node.Name}, nil
+		case node.Kind == N_App && p.Slot == 2:
+			fun, fun_err := read(n.Peer(NetPort{Node: p.Node, Slot: 0}), depth)
+			if fun_err != nil {
+				return nil, fun_err
+			}
+			arg, arg_err := read(n.Peer(NetPort{Node: p.Node, Slot: 1}), depth)
+			if arg_err != nil {
+				return nil, arg_err
+			}
+			return &CApp{Fun: fun, Arg: arg}, nil
+		case node.Kind == N_Fan && p.Slot != 0:
+			stacks[node.Label] = append(stacks[node.Label], p.Slot)
+			out, out_err := read(n.Peer(NetPort{Node: p.Node, Slot: 0}), depth)
+			stacks[node.Label] = stacks[node.Label][:len(stacks[node.Label])-1]
+			return out, out_err
+		case node.Kind == N_Fan && p.Slot == 0:
+			stack := stacks[node.Label]
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("Fan %v reached through its principal port without a matching entry", node.Label)
+			}
+			slot := stack[len(stack)-1]
+			stacks[node.Label] = stack[:len(stack)-1]
+			out, out_err := read(n.Peer(NetPort{Node: p.Node, Slot: slot}), depth)
+			stacks[node.Label] = append(stacks[node.Label], slot)
+			return out, out_err
+		case node.Kind == N_Free:
+			return &CFree{Name: node.Name}, nil
+		}
+		return nil, fmt.Errorf("Net is not in normal form: reached %v port %v", node.Kind.ToString(), p.Slot)
+	}
+	return read(n.Peer(NetPort{Node: n.Root, Slot: 0}), 0)
+}
+
+// Normal form of c by interaction net reduction alone, which may fail or be wrong on terms
+// that are not elementary affine typable.
+func NormalizeNet(c Core, max_interactions int) (Core, NetStats, error) {
+	net := BuildNet(c)
+	if reduce_err := net.Reduce(max_interactions); reduce_err != nil {
+		return nil, net.Stats, reduce_err
+	}
+	nf, read_err := net.ReadBack(max_interactions)
+	if read_err == nil && !IsNormal(nf) {
+		read_err = fmt.Errorf("Net read back as %v, which is not in normal form", nf.CPrint())
+	}
+	return nf, net.Stats, read_err
+}
+
+// Normal form of c by interaction net reduction, or by NormalizeNbE with the same budget
+// when the net fails on it.
+func NormalizeOptimal(c Core, max_interactions int) (Core, NetStats, error) {
+	nf, stats, net_err := NormalizeNet(c, max_interactions)
+	if net_err == nil {
+		return nf, stats, nil
+	}
+	stats.Fallback = net_err
+	nf, _, nbe_err := NormalizeNbE(c, max_interactions)
+	if nbe_err != nil {
+		return nil, stats, fmt.Errorf("%w, after the interaction net failed: %v", nbe_err, net_err)
+	}
+	return nf, stats, nil
+}
+
+// Normalize a parsed term with the interaction net evaluator and read the result back.
+func EvalOptimal(l LExpr, max_interactions int) (LExpr, NetStats, error) {
+	c, lower_err := Lower(l)
+	if lower_err != nil {
+		return nil, NetStats{}, lower_err
+	}
+	nf, stats, norm_err := NormalizeOptimal(c, max_interactions)
+	if norm_err != nil {
+		return nil, stats, norm_err
+	}
+	return Raise(nf), stats, nil
+}
+
+type OptimalComparison struct {
+	Name string
+	Net  NetStats
+	// Beta steps of normal order reduction by substitution
+	NormalOrderBetas int
+	// Read back normal form agrees with NormalizeNbE
+	Agrees bool
+}
+
+// Reduce c both ways and report the counts.
+func CompareOptimal(name string, c Core, max_steps int) (OptimalComparison, error) {
+	out := OptimalComparison{Name: name}
+	net_nf, net_stats, net_err := NormalizeNet(c, max_steps)
+	out.Net = net_stats
+	if net_err != nil {
+		return out, fmt.Errorf("%v: %w", name, net_err)
+	}
+	_, betas, reduce_err := Reduce(c, R_NormalOrder, max_steps)
+	if reduce_err != nil {
+		return out, fmt.Errorf("%v: %w", name, reduce_err)
+	}
+	out.NormalOrderBetas = betas
+	nf, _, nbe_err := NormalizeNbE(c, max_steps)
+	if nbe_err != nil {
+		return out, fmt.Errorf("%v: %w", name, nbe_err)
+	}
+	out.Agrees = nf.CPrint() == net_nf.CPrint()
+	return out, nil
+}
+
+// A closed term and the name a comparison reports it under.
+type NamedTerm struct {
+	Name string
+	Term Core
+}
+
+// Church exponentiation and friends, where sharing makes the difference.
+func Optimal_Comparison_Cases() []NamedTerm {
+	prelude := LoadPrelude()
+	pow := prelude.Cores["POW"]
+	id := prelude.Cores["I"]
+	cases := []NamedTerm{}
+	for e := 1; e <= 4; e++ {
+		cases = append(cases, NamedTerm{
+			Name: fmt.Sprintf("pow 2 %v", e),
+			Term: CoreApply(pow, ChurchNumeral(2), ChurchNumeral(e)),
+		})
+	}
+	for n := 2; n <= 5; n++ {
+		// 2^2^...^2 applied to I, normal form I
+		cases = append(cases, NamedTerm{
+			Name: fmt.Sprintf("%v 2 I", n),
+			Term: CoreApply(ChurchNumeral(n), ChurchNumeral(2), id),
+		})
+	}
+	return cases
+}
+
+// Write one line per case comparing interactions with normal order beta steps.
+func RunOptimalComparison(w io.Writer, cases []NamedTerm, max_steps int) error {
+	fmt.Fprintf(w, "%-10v %12v %8v %12v %12v %8v\n", "term", "interactions", "net β", "normal β", "max nodes", "agrees")
+	for _, named := range cases {
+		cmp, cmp_err := CompareOptimal(named.Name, named.Term, max_steps)
+		if cmp_err != nil {
+			return cmp_err
+		}
+		fmt.Fprintf(w, "%-10v %12v %8v %12v %12v %8v\n", cmp.Name, cmp.Net.Interactions, cmp.Net.Betas,
+			cmp.NormalOrderBetas, cmp.Net.MaxNodes, cmp.Agrees)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOptimalAgreesWithNormalOrder(t *testing.T) {
+	for _, c := range preludeCases() {
+		if c.Lazy || c.Prims {
+			// The net reduces garbage too, and has no delta rules
+			continue
+		}
+		t.Run(c.Name, func(t *testing.T) {
+			nf, stats, norm_err := NormalizeOptimal(c.Term, Test_Max_Steps)
+			if norm_err != nil {
+				t.Fatal(norm_err)
+			}
+			if stats.Fallback != nil {
+				t.Errorf("fell back to NbE: %v", stats.Fallback)
+			}
+			checkNormalResult(t, c.Term, nf)
+		})
+	}
+}
+
+func TestOptimalComparisonCasesAgree(t *testing.T) {
+	for _, named := range Optimal_Comparison_Cases() {
+		cmp, cmp_err := CompareOptimal(named.Name, named.Term, Test_Max_Steps)
+		if cmp_err != nil {
+			t.Fatal(cmp_err)
+		}
+		if !cmp.Agrees {
+			t.Errorf("%v: net normal form disagrees with NbE", named.Name)
+		}
+	}
+}
+
+// Terms that are not elementary affine typable, on which the bare net goes wrong each in
+// its own way.
+func TestOptimalFallsBackOnNonEALTerms(t *testing.T) {
+	v := func(index int) Core {
+		return &CVar{Index: index, Name: "X1"}
+	}
+	lam := func(body Core) Core {
+		return &CLam{Name: "X1", Body: body}
+	}
+	app := CoreApply
+	self := lam(app(v(0), v(0)))
+	cases := []struct {
+		name string
+		term Core
+		// Part of the error of NormalizeNet
+		net_err string
+	}{
+		{
+			"(λ(0 0) λλ((0 1) (((1 0) 1) λ1)))",
+			app(self, lam(lam(app(app(v(0), v(1)), app(app(app(v(1), v(0)), v(1)), lam(v(1))))))),
+			"without a matching entry",
+		},
+		{
+			"(λ(0 0) λ(λ((0 0) 0) λ1))",
+			app(self, lam(app(lam(app(v(0), v(0), v(0))), lam(v(1))))),
+			ErrStepLimit.Error(),
+		},
+		{
+			"(λ0 λ(λ((0 0) 0) λ((0 (0 1)) (1 1))))",
+			app(lam(v(0)), lam(app(lam(app(v(0), v(0), v(0))), lam(app(v(0), app(v(0), v(1)), app(v(1), v(1))))))),
+			"visit",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if printed := c.term.CPrint(); printed != c.name {
+				t.Fatalf("built %v", printed)
+			}
+			if _, _, net_err := NormalizeNet(c.term, 100000); net_err == nil || !strings.Contains(net_err.Error(), c.net_err) {
+				t.Errorf("bare net gave %v, want an error mentioning %q", net_err, c.net_err)
+			}
+			nf, stats, norm_err := NormalizeOptimal(c.term, 100000)
+			if norm_err != nil {
+				t.Fatal(norm_err)
+			}
+			if stats.Fallback == nil {
+				t.Errorf("normal form %v did not come from the fallback", nf.CPrint())
+			}
+			checkNormalResult(t, c.term, nf)
+		})
+	}
+}
+
+func TestOptimalDivergence(t *testing.T) {
+	omega := LoadPrelude().Cores["OMEGA"]
+	_, stats, norm_err := NormalizeOptimal(omega, 10000)
+	if !errors.Is(norm_err, ErrStepLimit) {
+		t.Errorf("got %v, want the step limit", norm_err)
+	}
+	if stats.Fallback == nil {
+		t.Errorf("diverging term did not fall back")
+	}
+}
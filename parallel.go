@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+	Parallel normal order reduction
+
+Normal order first reduces a term to head normal form λx1...λxk. h a1 ... an, where h is a
+variable, and from then on never contracts a redex that spans two arguments: each ai is
+normalized on its own, left to right. Those normalizations are independent, so here they
+run concurrently, each argument on a worker goroutine if one is free and in the current
+goroutine otherwise. By confluence the result is exactly the normal form normal order
+reaches, and the number of beta steps is the same too, only spread over several cores.
+
+The pool is bounded by a semaphore of Workers slots, and a shared step counter enforces
+MaxSteps across all workers; once it is exceeded every worker stops at its next step.
+
+A primitive operator at the head takes its delta steps with StepPrim, as in Reduce, and one
+stuck on its arguments is a head like a variable, its arguments normalized in parallel.
+*/
+type ParallelReducer struct {
+	Workers  int
+	MaxSteps int
+	slots    chan struct{}
+	steps    atomic.Int64
+	stopped  atomic.Bool
+	// Workers spawned, as opposed to arguments normalized inline
+	spawned atomic.Int64
+}
+
+type ParallelStats struct {
+	Steps   int
+	Spawned int
+}
+
+// Create a reducer with the given pool size, <= 0 meaning one worker per CPU.
+func CreateParallelReducer(workers int, max_steps int) *ParallelReducer {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return &ParallelReducer{Workers: workers, MaxSteps: max_steps, slots: make(chan struct{}, workers)}
+}
+
+// Count one beta step, reporting false once the limit has been passed.
+func (r *ParallelReducer) step() bool {
+	if r.stopped.Load() {
+		return false
+	}
+	if steps := r.steps.Add(1); r.MaxSteps > 0 && steps > int64(r.MaxSteps) {
+		r.stopped.Store(true)
+		return false
+	}
+	return true
+}
+
+// Leftmost outermost reduction until the head of c, under its lambdas, is not a redex.
+func (r *ParallelReducer) headNormalize(c Core) (binders []*CLam, head Core, args []Core, ok bool) {
+	binders = []*CLam{}
+	for {
+		for {
+			lam, is_lam := c.(*CLam)
+			if !is_lam {
+				break
+			}
+			binders = append(binders, lam)
+			c = lam.Body
+		}
+		head, args = CoreSpine(c)
+		if prim, _ := DecodePrim(head); prim != nil {
+			op, is_op := prim.(*LPrim)
+			if !is_op || len(args) < op.Op.Arity() {
+				return binders, head, args, true
+			}
+			// Delta steps, on the application to as many arguments as the operator takes
+			next, stepped := StepPrim(CoreApply(head, args[:op.Op.Arity()]...), R_NormalOrder)
+			if !stepped {
+				return binders, head, args, true
+			}
+			if !r.step() {
+				return nil, nil, nil, false
+			}
+			c = CoreApply(next, args[op.Op.Arity():]...)
+			continue
+		}
+		lam, is_lam := head.(*CLam)
+		if !is_lam || len(args) == 0 {
+			return binders, head, args, true
+		}
+		if !r.step() {
+			return nil, nil, nil, false
+		}
+		c = CoreApply(Beta(lam.Body, args[0]), args[1:]...)
+	}
+}
+
+func (r *ParallelReducer) normalize(c Core) (Core, bool) {
+	binders, head, args, ok := r.headNormalize(c)
+	if !ok {
+		return nil, false
+	}
+	results := make([]Core, len(args))
+	failed := atomic.Bool{}
+	var wg sync.WaitGroup
+	for i, arg := range args {
+		// The last argument always runs inline, the current goroutine would idle otherwise
+		if i < len(args)-1 {
+			select {
+			case r.slots <- struct{}{}:
+				r.spawned.Add(1)
+				wg.Add(1)
+				go func(i int, arg Core) {
+					defer wg.Done()
+					defer func() { <-r.slots }()
+					nf, arg_ok := r.normalize(arg)
+					results[i] = nf
+					if !arg_ok {
+						failed.Store(true)
+					}
+				}(i, arg)
+				continue
+			default:
+			}
+		}
+		nf, arg_ok := r.normalize(arg)
+		results[i] = nf
+		if !arg_ok {
+			failed.Store(true)
+		}
+	}
+	wg.Wait()
+	if failed.Load() {
+		return nil, false
+	}
+	out := CoreApply(head, results...)
+	for i := len(binders) - 1; i >= 0; i-- {
+		out = &CLam{Name: binders[i].Name, Body: out}
+	}
+	return out, true
+}
+
+// Normal form of c, the same one Reduce(c, R_NormalOrder, ...) finds.
+func (r *ParallelReducer) Reduce(c Core) (Core, ParallelStats, error) {
+	r.steps.Store(0)
+	r.spawned.Store(0)
+	r.stopped.Store(false)
+	nf, ok := r.normalize(c)
+	stats := ParallelStats{Steps: int(r.steps.Load()), Spawned: int(r.spawned.Load())}
+	if !ok {
+		stats.Steps = min(stats.Steps, r.MaxSteps)
+		return nil, stats, fmt.Errorf("%w: %v steps of parallel %v with %v workers", ErrStepLimit,
+			stats.Steps, R_NormalOrder.ToString(), r.Workers)
+	}
+	return nf, stats, nil
+}
+
+// Normalize c with a fresh pool of workers. max_steps <= 0 means no limit.
+func ReduceParallel(c Core, workers int, max_steps int) (Core, ParallelStats, error) {
+	return CreateParallelReducer(workers, max_steps).Reduce(c)
+}
+
+// Reduce a parsed term in parallel, reading the result back as an LExpr.
+func ReduceParallelLExpr(l LExpr, workers int, max_steps int) (LExpr, ParallelStats, error) {
+	c, lower_err := Lower(l)
+	if lower_err != nil {
+		return nil, ParallelStats{}, lower_err
+	}
+	nf, stats, reduce_err := ReduceParallel(c, workers, max_steps)
+	if reduce_err != nil {
+		return nil, stats, reduce_err
+	}
+	return Raise(nf), stats, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParallelAgreesWithNormalOrder(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		for _, c := range preludeCases() {
+			t.Run(fmt.Sprintf("%v workers/%v", workers, c.Name), func(t *testing.T) {
+				nf, stats, reduce_err := ReduceParallel(c.Term, workers, Test_Max_Steps)
+				if reduce_err != nil {
+					t.Fatal(reduce_err)
+				}
+				checkNormalResult(t, c.Term, nf)
+				if _, betas, _ := Reduce(c.Term, R_NormalOrder, Test_Max_Steps); stats.Steps != betas {
+					t.Errorf("took %v steps, normal order takes %v", stats.Steps, betas)
+				}
+			})
+		}
+	}
+}
+
+// Every argument of a wide head normal form is a job of its own.
+func TestParallelSpawnsWorkers(t *testing.T) {
+	term := parallelWorkloads()[0].Term
+	nf, stats, reduce_err := ReduceParallel(term, 4, Test_Max_Steps)
+	if reduce_err != nil {
+		t.Fatal(reduce_err)
+	}
+	checkNormalResult(t, term, nf)
+	if stats.Spawned == 0 {
+		t.Errorf("normalized every argument inline")
+	}
+}
+
+func TestParallelStepLimit(t *testing.T) {
+	prelude := LoadPrelude()
+	// Both arguments diverge, the limit must stop both workers
+	term := CoreApply(&CFree{Name: "F1"}, prelude.Cores["OMEGA"], prelude.Cores["OMEGA"])
+	_, stats, reduce_err := ReduceParallel(term, 4, 1000)
+	if !errors.Is(reduce_err, ErrStepLimit) {
+		t.Errorf("got %v, want the step limit", reduce_err)
+	}
+	if stats.Steps > 1000 {
+		t.Errorf("reported %v steps, over the limit", stats.Steps)
+	}
+}
+
+func TestParallelDeltaRules(t *testing.T) {
+	for _, c := range deltaCases() {
+		term := parseCore(t, c.Source)
+		nf, stats, reduce_err := ReduceParallel(term, 4, Test_Max_Steps)
+		if reduce_err != nil {
+			t.Errorf("%v: %v", c.Source, reduce_err)
+			continue
+		}
+		if got := Raise(nf).LPrint(); got != c.Want {
+			t.Errorf("%v: got %v, want %v", c.Source, got, c.Want)
+		}
+		if _, steps, _ := Reduce(term, R_NormalOrder, Test_Max_Steps); stats.Steps != steps {
+			t.Errorf("%v: took %v steps, normal order takes %v", c.Source, stats.Steps, steps)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParenErrorColumnsCountRunes(t *testing.T) {
+	executor := TransitionExecutor_Init()
+	cases := []struct {
+		source string
+		want   string
+	}{
+		{"λX1.(λY1.(X1)", "unclosed '(' opened at column 5"},
+		{"λX1.(λY1.(X1)))", "unexpected ')' at column 15"},
+		{"LX1.(LY1.(X1)))", "unexpected ')' at column 15"},
+		{`++("λλ")(X1))`, "unexpected ')' at column 13"},
+	}
+	for _, c := range cases {
+		_, err := executor.Parse(c.source)
+		var paren_err *ParenError
+		if !errors.As(err, &paren_err) {
+			t.Errorf("%v: got %v, want a ParenError", c.source, err)
+			continue
+		}
+		if !strings.HasPrefix(paren_err.Error(), c.want) {
+			t.Errorf("%v: got %q, want %q", c.source, paren_err.Error(), c.want)
+		}
+	}
+}
+
+func TestLambdaParserParenColumns(t *testing.T) {
+	parser := CreateParser("LX1.(X1")
+	_, err := parser.DriveParse()
+	if err == nil || !strings.HasPrefix(err.Error(), "unclosed '(' opened at column 5") {
+		t.Errorf("got %v", err)
+	}
+}
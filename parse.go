@@ -1,8 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type TransitionCallback func(p Parser, s string) (Parser, error)
@@ -17,11 +21,20 @@ const (
 	L_i             // Read "L" lambda expression start
 	LV1             // Captured letter for a var for a variable binding
 	LV2             // Captured number for a var for a variable binding\
+	LT              // Read ":" after a variable binding, captures its type annotation up to "."
+	LE1             // Read "=" after a variable binding, expects "(" opening the bound term of a let
+	LE2             // Captures the bound term of a let up to its closing ")"
+	LE3             // Read the ")" closing the bound term, expects "."
 	LV3             // Read "." ending a variable binding.
 	LP1             // Read "(" at beginning of function body, captures any further input read
 	L_f             // Read corresponding closing ")" and process captured strings.
 	// TERMINAL for creating complete FUNCTION expression
 
+	TA // Read "Λ" starting a type abstraction, captures the type variable it binds up to "."
+
+	U // Read "*", a universe, captures the digits of its level
+	// TERMINAL for creating complete UNIVERSE expressions.
+
 	V_i // Captured letter for a var (not part of a variable binding of a func)
 	V_f // Captured number for a var (not part of a variable binding of a func)
 	// TERMINAL for creating complete VAR expression.
@@ -32,6 +45,40 @@ const (
 	P_f // Read corresponding closing ")" and process captured strings.
 	// TERMINAL for creating complete CONCAT expressions.
 
+	TY_i // Read "[" starting a type argument, captures the type up to "]"
+	TY_f // Read the closing "]".
+	// TERMINAL for creating complete TYPE ARGUMENT expressions.
+
+	NUM // Captured a digit of an integer literal
+	// TERMINAL for creating complete INTEGER expressions.
+
+	OP // Captured a character of an operator (+, -, <, ==, ++)
+	// TERMINAL for creating complete OPERATOR expressions.
+
+	PW // Read "@" starting a primitive or constructor name (@TRUE, @IF, @CONS), captures its letters
+	// TERMINAL for creating complete PRIMITIVE expressions.
+
+	CB // Read "." after a constructor starting a case branch, captures the fields it binds,
+	// each followed by ".", up to the "(" of its body, which continues as a lambda's
+
+	STR_i // Read "\"" starting a string literal, captures it up to the closing "\""
+	STR_f // Read the closing "\"".
+	// TERMINAL for creating complete STRING expressions.
+
+	// Records and variants extension, only entered by parsers with Records set
+	RC  // Read "{" or "," of a record literal, captures a field label up to "="
+	RE  // Read "=" after a field label, expects "(" opening the field's term
+	RF  // Captures the term of a field up to its closing ")"
+	RS  // Read the ")" closing a field, expects "," or "}"
+	R_f // Read the "}" closing a record literal.
+	// TERMINAL for creating complete RECORD expressions.
+
+	FS // Read "." after a variable, parenthetical or record, captures the label it selects
+	// TERMINAL for creating complete PROJECTION expressions.
+
+	TG // Read "'" starting a tag ('OK), captures its letters
+	// TERMINAL for creating complete TAG expressions.
+
 	E_0   // End State. Should always succeed some neutral/TERMINAL state.
 	DUMMY // Represents arbitrary state
 )
@@ -46,12 +93,24 @@ func (s ParserState) ToString() string {
 		return "LV1"
 	case LV2:
 		return "LV2"
+	case LT:
+		return "LT"
+	case LE1:
+		return "LE1"
+	case LE2:
+		return "LE2"
+	case LE3:
+		return "LE3"
 	case LV3:
 		return "LV3"
 	case LP1:
 		return "LP1"
 	case L_f:
 		return "L_f"
+	case TA:
+		return "TA"
+	case U:
+		return "U"
 	case V_i:
 		return "V_i"
 	case V_f:
@@ -60,6 +119,36 @@ func (s ParserState) ToString() string {
 		return "P_i"
 	case P_f:
 		return "P_f"
+	case TY_i:
+		return "TY_i"
+	case TY_f:
+		return "TY_f"
+	case NUM:
+		return "NUM"
+	case OP:
+		return "OP"
+	case PW:
+		return "PW"
+	case CB:
+		return "CB"
+	case STR_i:
+		return "STR_i"
+	case STR_f:
+		return "STR_f"
+	case RC:
+		return "RC"
+	case RE:
+		return "RE"
+	case RF:
+		return "RF"
+	case RS:
+		return "RS"
+	case R_f:
+		return "R_f"
+	case FS:
+		return "FS"
+	case TG:
+		return "TG"
 	case E_0:
 		return "E_0"
 	case DUMMY:
@@ -68,6 +157,19 @@ func (s ParserState) ToString() string {
 	return "indeterminate state"
 }
 
+// States in which the input may end, leaving a complete expression behind
+func (s ParserState) IsTerminal() bool {
+	switch s {
+	case I_i, V_f, L_f, P_f, TY_f, U, NUM, OP, PW, STR_f, R_f, FS, TG:
+		return true
+	}
+	return false
+}
+
+// Every state in declaration order, for code that needs to walk the whole FSM
+var All_Parser_States = []ParserState{I_i, L_i, LV1, LV2, LT, LE1, LE2, LE3, LV3, LP1, L_f, TA, U, V_i, V_f, P_i, P_f, TY_i, TY_f,
+	NUM, OP, PW, CB, STR_i, STR_f, RC, RE, RF, RS, R_f, FS, TG, E_0, DUMMY}
+
 // Create 1 map per state to return subsequent state given a certain string
 
 // I_i and all states that complete an expression (other than V_f) share the same mapping.
@@ -77,15 +179,35 @@ func End_of_Expression_Mapper(p Parser, s string) (Transition, error) {
 		next_state = V_i
 	} else if s == "(" {
 		next_state = P_i
-	} else if s == "L" {
+	} else if s == "L" || s == "Π" {
 		next_state = L_i
+	} else if s == "*" {
+		next_state = U
+	} else if s == "Λ" {
+		next_state = TA
+	} else if s == "[" {
+		next_state = TY_i
+	} else if IsInteger(s) || s == Negative_Sign {
+		next_state = NUM
+	} else if IsOperatorChar(s) {
+		next_state = OP
+	} else if s == "@" {
+		next_state = PW
+	} else if s == "\"" {
+		next_state = STR_i
+	} else if s == "{" && p.Records {
+		next_state = RC
+	} else if s == "'" && p.Records {
+		next_state = TG
 	} else {
 		return p.TState, fmt.Errorf(
 			"Parsed character (%v) not valid start character for any LExpr"+
-				"following state %v. Must be either L or an alphabetical"+
-				" character or a ( start parenthesis",
+				" following state %v. Must be either L, Λ, Π, *, an alphabetical"+
+				" character, a ( start parenthesis, a [ starting a type argument,"+
+				" a digit or ~, an operator (+ - < =), @, a \" starting a string"+
+				" or, with records, a { or '",
 			s,
-			p.TState.S_f,
+			p.TState.S_f.ToString(),
 		)
 	}
 	transition := Transition{S_f: next_state, S_i: p.TState.S_f}
@@ -102,9 +224,207 @@ func L_f_Mapper(p Parser, s string) (Transition, error) {
 }
 
 func P_f_Mapper(p Parser, s string) (Transition, error) {
+	if s == "." && p.Records {
+		return Transition{S_f: FS, S_i: p.TState.S_f}, nil
+	}
+	return End_of_Expression_Mapper(p, s)
+}
+
+func TY_f_Mapper(p Parser, s string) (Transition, error) {
+	return End_of_Expression_Mapper(p, s)
+}
+
+// Digits after "*" are the universe level, another "*" cannot follow directly
+func U_Mapper(p Parser, s string) (Transition, error) {
+	var next_state ParserState
+	if IsInteger(s) {
+		next_state = U
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	} else if s == "*" {
+		return p.TState, fmt.Errorf("A universe cannot be applied to another universe, found * after %v", "*"+p.Universe)
+	}
+	return End_of_Expression_Mapper(p, s)
+}
+
+// The digits of a number, the characters of an operator and the letters of a primitive name
+// run until the first character that cannot continue them.
+func NUM_Mapper(p Parser, s string) (Transition, error) {
+	if IsInteger(s) {
+		return Transition{S_f: NUM, S_i: p.TState.S_f}, nil
+	} else if s == Negative_Sign {
+		return p.TState, fmt.Errorf(
+			"A negative literal after a number must be parenthesized, as in +(1)(%v1)", Negative_Sign)
+	}
+	return End_of_Expression_Mapper(p, s)
+}
+
+func OP_Mapper(p Parser, s string) (Transition, error) {
+	if IsOperatorChar(s) {
+		return Transition{S_f: OP, S_i: p.TState.S_f}, nil
+	}
 	return End_of_Expression_Mapper(p, s)
 }
 
+func PW_Mapper(p Parser, s string) (Transition, error) {
+	if IsPrimNameChar(s) {
+		return Transition{S_f: PW, S_i: p.TState.S_f}, nil
+	} else if s == "." {
+		return Transition{S_f: CB, S_i: p.TState.S_f}, nil
+	}
+	return End_of_Expression_Mapper(p, s)
+}
+
+// Case branches, @CONS.H1.T1.(B): fields are variables, the body must be parenthesized
+func CB_Mapper(p Parser, s string) (Transition, error) {
+	var next_state ParserState
+	if IsCapLetter(s) || IsInteger(s) || s == "." {
+		next_state = CB
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	} else if s == "(" {
+		next_state = LP1
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	}
+	CB_err := fmt.Errorf(
+		"Currently processing case branch %v, expecting its fields each followed by ."+
+			" then its body bound by () but found char %v",
+		BranchHead(p.Branch),
+		s,
+	)
+	return p.TState, CB_err
+}
+
+// Record literals, {HOST=("h"),PORT=(80)}: labels are letters, every field term must be
+// parenthesized
+func RC_Mapper(p Parser, s string) (Transition, error) {
+	var next_state ParserState
+	if IsPrimNameChar(s) {
+		next_state = RC
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	} else if s == "=" {
+		next_state = RE
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	} else if s == "}" {
+		next_state = R_f
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	}
+	RC_err := fmt.Errorf(
+		"Currently processing record literal, expecting a field label of letters"+
+			" followed by =(E) but found char %v",
+		s,
+	)
+	return p.TState, RC_err
+}
+
+func RE_Mapper(p Parser, s string) (Transition, error) {
+	var next_state ParserState
+	if s == "(" {
+		next_state = RF
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	}
+	RE_err := fmt.Errorf(
+		"Currently processing a field of a record literal, expecting its term in ()."+
+			" Looking for start ( but found char %v",
+		s,
+	)
+	return p.TState, RE_err
+}
+
+func RF_Mapper(p Parser, s string) (Transition, error) {
+	var next_state ParserState
+	if (s == ")") && (p.NestTracker.Counter == 0) {
+		next_state = RS
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	}
+	next_state = RF
+	return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+}
+
+func RS_Mapper(p Parser, s string) (Transition, error) {
+	var next_state ParserState
+	if s == "," {
+		next_state = RC
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	} else if s == "}" {
+		next_state = R_f
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	}
+	RS_err := fmt.Errorf(
+		"Currently processing record literal, expecting , or } after a field but found char %v",
+		s,
+	)
+	return p.TState, RS_err
+}
+
+func R_f_Mapper(p Parser, s string) (Transition, error) {
+	if s == "." {
+		return Transition{S_f: FS, S_i: p.TState.S_f}, nil
+	}
+	return End_of_Expression_Mapper(p, s)
+}
+
+// Projections, R1.PORT.HOST: a label runs until the first character that is not a letter, as
+// a primitive name does, and another "." selects from the result
+func FS_Mapper(p Parser, s string) (Transition, error) {
+	if IsPrimNameChar(s) || s == "." {
+		return Transition{S_f: FS, S_i: p.TState.S_f}, nil
+	}
+	return End_of_Expression_Mapper(p, s)
+}
+
+// Tags, 'OK, are applied to their payload like constructors; a "." starts a case branch
+func TG_Mapper(p Parser, s string) (Transition, error) {
+	if IsPrimNameChar(s) {
+		return Transition{S_f: TG, S_i: p.TState.S_f}, nil
+	} else if s == "." {
+		return Transition{S_f: CB, S_i: p.TState.S_f}, nil
+	}
+	return End_of_Expression_Mapper(p, s)
+}
+
+// Strings end at the first unescaped "\"", which the ParenTracker has already seen
+func STR_i_Mapper(p Parser, s string) (Transition, error) {
+	var next_state ParserState
+	if s == "\"" && !p.NestTracker.InString {
+		next_state = STR_f
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	}
+	next_state = STR_i
+	return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+}
+
+func STR_f_Mapper(p Parser, s string) (Transition, error) {
+	return End_of_Expression_Mapper(p, s)
+}
+
+// Type arguments end at the first "]", types never contain one
+func TY_i_Mapper(p Parser, s string) (Transition, error) {
+	var next_state ParserState
+	if s == "]" {
+		next_state = TY_f
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	}
+	next_state = TY_i
+	return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+}
+
+// Type abstraction, ΛA.(...): the type variable is a name, the body continues as a lambda's
+func TA_Mapper(p Parser, s string) (Transition, error) {
+	var next_state ParserState
+	if IsTypeNameChar(s) {
+		next_state = TA
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	} else if s == "." && len(p.TypeBinder) > 0 {
+		next_state = LV3
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	}
+	TA_err := fmt.Errorf(
+		"Currently processing type abstraction, expecting a type variable name"+
+			" followed by . but found char %v",
+		s,
+	)
+	return p.TState, TA_err
+}
+
 // Mappers for V states (Binding a Variable term)
 func V_i_Mapper(p Parser, s string) (Transition, error) {
 	var next_state ParserState
@@ -134,6 +454,9 @@ func V_f_Mapper(p Parser, s string) (Transition, error) {
 		next_state = V_f
 		transition := Transition{S_f: next_state, S_i: p.TState.S_f}
 		return transition, nil
+	} else if s == "." && p.Records {
+		next_state = FS
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
 	}
 	return End_of_Expression_Mapper(p, s)
 }
@@ -155,10 +478,14 @@ func L_i_Mapper(p Parser, s string) (Transition, error) {
 	if IsCapLetter(s) {
 		next_state = LV1
 		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	} else if s == "@" && !p.Recursive {
+		// λ@F1=(E).(B), a recursive let
+		next_state = L_i
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
 	}
 	L_i_err := fmt.Errorf(
 		"Currently processing lambda-function, expecting first alphabetical char in"+
-			" binding variable but found char %v",
+			" binding variable (or @ marking a recursive let) but found char %v",
 		s,
 	)
 	return p.TState, L_i_err
@@ -189,15 +516,74 @@ func LV2_Mapper(p Parser, s string) (Transition, error) {
 	} else if s == "." {
 		next_state = LV3
 		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	} else if s == ":" {
+		next_state = LT
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	} else if s == "=" {
+		next_state = LE1
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
 	}
 	L_i_err := fmt.Errorf(
-		"Currently processing lambda-function, expecting numeric suffix for"+
+		"Currently processing lambda-function, expecting numeric suffix, :, = or . for"+
 			" binding variable but found char %v",
 		s,
 	)
 	return p.TState, L_i_err
 }
 
+// Type annotations may contain parentheses, only a "." outside of them ends the annotation,
+// or a "=" starting the bound term of an annotated let
+func LT_Mapper(p Parser, s string) (Transition, error) {
+	var next_state ParserState
+	if (s == ".") && (p.NestTracker.Counter == 0) {
+		next_state = LV3
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	} else if (s == "=") && (p.NestTracker.Counter == 0) {
+		next_state = LE1
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	}
+	next_state = LT
+	return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+}
+
+// Let bindings, λX1=(E).(B): the bound term must be parenthesized
+func LE1_Mapper(p Parser, s string) (Transition, error) {
+	var next_state ParserState
+	if s == "(" {
+		next_state = LE2
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	}
+	L_i_err := fmt.Errorf(
+		"Currently processing let binding, expecting the bound term in ()."+
+			" Looking for start ( but found char %v",
+		s,
+	)
+	return p.TState, L_i_err
+}
+
+func LE2_Mapper(p Parser, s string) (Transition, error) {
+	var next_state ParserState
+	if (s == ")") && (p.NestTracker.Counter == 0) {
+		next_state = LE3
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	}
+	next_state = LE2
+	return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+}
+
+func LE3_Mapper(p Parser, s string) (Transition, error) {
+	var next_state ParserState
+	if s == "." {
+		next_state = LV3
+		return Transition{S_f: next_state, S_i: p.TState.S_f}, nil
+	}
+	L_i_err := fmt.Errorf(
+		"Currently processing let binding, expecting . after the bound term but found char %v",
+		s,
+	)
+	return p.TState, L_i_err
+}
+
 func LV3_Mapper(p Parser, s string) (Transition, error) {
 	var next_state ParserState
 	if s == "(" {
@@ -233,6 +619,52 @@ type Parser struct {
 	LVar          string
 	Parenthetical string
 	TState        Transition
+	// Optional sink for parse events, nil keeps the parse silent
+	Tracer ParseTracer
+	// Number of parentheticals enclosing this parse
+	Nesting int
+	// Byte offset of the character currently being read
+	Offset int
+	// Recovery mode: collect errors into Errors and resynchronize instead of aborting
+	Recover bool
+	Errors  ParseErrorList
+	// Error node currently swallowing input while resynchronizing
+	Skipping *LError
+	// Type annotation text of the binding being read, and its parsed form once "." is read
+	Annotation  string
+	BindingType LType
+	// Bound term of the let being read, once its closing ")" is read
+	LetBound LExpr
+	// Type variable bound by the type abstraction being read
+	TypeBinder string
+	// Annotation of the binding being read parsed as a term, see LVar.Domain
+	BindingDomain LExpr
+	// The binder being read was opened by Π rather than L
+	Pi bool
+	// The binder being read is a recursive let, λ@F1=(E).(B)
+	Recursive bool
+	// Level digits of the universe being read
+	Universe string
+	// Text of the number, operator, primitive name or string literal being read
+	Literal string
+	// Constructor (or tag, 'OK) and fields of the case branch being read, @CONS.H1.T1.(B)
+	Branch       string
+	BranchFields []string
+	// Records and variants extension enabled, see records.go
+	Records bool
+	// Labels and terms of the fields of the record literal being read
+	RecordLabels []string
+	RecordFields []LExpr
+	// Offset where the expression currently being read started
+	Start int
+	// Source span of every LExpr built, relative to the string being parsed
+	Spans map[LExpr]Span
+}
+
+// Byte range [Offset, End) of the source text an LExpr was parsed from.
+type Span struct {
+	Offset int
+	End    int
 }
 
 func Parser_Init() Parser {
@@ -242,7 +674,128 @@ func Parser_Init() Parser {
 		LVar:          "",
 		Parenthetical: "",
 		TState:        Transition{S_i: DUMMY, S_f: I_i},
+		Spans:         map[LExpr]Span{},
+	}
+}
+
+// Record where l was parsed from.
+func (p Parser) Span(l LExpr, span Span) Parser {
+	if p.Spans == nil {
+		p.Spans = map[LExpr]Span{}
+	}
+	p.Spans[l] = span
+	return p
+}
+
+// Adopt the spans of the nested parse of p.Parenthetical, shifted onto the enclosing string.
+func (p Parser) NestedSpans(inner Parser) Parser {
+	base := p.Offset - len(p.Parenthetical)
+	for l, span := range inner.Spans {
+		p = p.Span(l, Span{Offset: span.Offset + base, End: span.End + base})
+	}
+	return p
+}
+
+// Fresh parser for the contents of a parenthetical, inheriting the tracer of p.
+func (p Parser) Nested() Parser {
+	nested := Parser_Init()
+	nested.Tracer = p.Tracer
+	nested.Nesting = p.Nesting + 1
+	nested.Recover = p.Recover
+	nested.Records = p.Records
+	return nested
+}
+
+func (p Parser) Trace(e TraceEvent) {
+	if p.Tracer == nil {
+		return
+	}
+	e.Nesting = p.Nesting
+	p.Tracer.Trace(e)
+}
+
+// Reports err as a TE_Error event from the current state and hands it back unchanged.
+func (p Parser) TraceFailure(err error, s string) error {
+	p.Trace(TraceEvent{
+		Kind:   TE_Error,
+		From:   p.TState.S_f.ToString(),
+		Char:   s,
+		Offset: p.Offset,
+		Depth:  p.NestTracker.Counter,
+		Err:    err,
+	})
+	return err
+}
+
+// Position within a source text, Line and Column are 1-based and count runes.
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+func (pos Position) ToString() string {
+	return fmt.Sprintf("line %v, column %v", pos.Line, pos.Column)
+}
+
+// ParseError ties a parser failure to the offset of the character that caused it. Offset is
+// relative to the string handed to the outermost parse; Pos is only filled in by front-ends
+// that know where that string came from (e.g. Decoder).
+type ParseError struct {
+	Offset int
+	Pos    Position
+	State  ParserState
+	Char   string
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	if paren_err, is_paren := e.Err.(*ParenError); is_paren {
+		// Already names its own location
+		return paren_err.Error()
+	}
+	if e.Pos.Line > 0 {
+		return fmt.Sprintf("%v: %v", e.Pos.ToString(), e.Err)
+	}
+	return fmt.Sprintf("offset %v: %v", e.Offset, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Tag err with the parser's current position unless it already carries one.
+func (p Parser) PositionError(err error, char string) error {
+	var parse_err *ParseError
+	if errors.As(err, &parse_err) {
+		return err
+	}
+	return &ParseError{Offset: p.Offset, State: p.TState.S_f, Char: char, Err: err}
+}
+
+// Re-anchor an error from the nested parse of p.Parenthetical onto the enclosing string.
+// p.Offset sits on the closing ")" so the parenthetical starts len(p.Parenthetical) before it.
+func (p Parser) NestedError(err error) *ParseError {
+	var parse_err *ParseError
+	if !errors.As(err, &parse_err) {
+		parse_err = &ParseError{Offset: len(p.Parenthetical), State: p.TState.S_f, Char: ")", Err: err}
+	}
+	shifted := *parse_err
+	shifted.Offset += p.Offset - len(p.Parenthetical)
+	if paren_err, is_paren := parse_err.Err.(*ParenError); is_paren {
+		shifted_paren := *paren_err
+		shifted_paren.Offset = shifted.Offset
+		// NestTracker has already counted the closing ")"
+		shifted_paren.Column += p.NestTracker.Runes - 1 - utf8.RuneCountInString(p.Parenthetical)
+		shifted.Err = &shifted_paren
+		return &shifted
 	}
+	shifted.Err = fmt.Errorf(
+		"Following error emerges while parsing %v within a parenthetical:\n%w",
+		p.Parenthetical,
+		parse_err.Err,
+	)
+	return &shifted
 }
 
 type TransitionExecutor struct {
@@ -257,7 +810,10 @@ func (t *TransitionExecutor) LoadCallback(ts Transition, cb []TransitionCallback
 }
 
 func TransitionExecutor_Init() TransitionExecutor {
-	executor := TransitionExecutor{}
+	executor := TransitionExecutor{
+		TransitionCallbackMap: map[Transition][]TransitionCallback{},
+		TransitionMap:         map[ParserState]TransitionMapper{},
+	}
 	// I_i setup
 	executor.TransitionMap[I_i] = I_i_Mapper
 	// V Variable state maps
@@ -276,8 +832,20 @@ func TransitionExecutor_Init() TransitionExecutor {
 	executor.LoadCallback(Transition{S_f: L_i, S_i: V_f}, capture_lvar)
 	executor.LoadCallback(Transition{S_f: P_i, S_i: V_f}, capture_lvar)
 	executor.LoadCallback(Transition{S_f: E_0, S_i: V_f}, capture_lvar)
+	executor.LoadCallback(Transition{S_f: TA, S_i: V_f}, capture_lvar)
+	executor.LoadCallback(Transition{S_f: TY_i, S_i: V_f}, capture_lvar)
+	executor.LoadCallback(Transition{S_f: U, S_i: V_f}, capture_lvar)
+	executor.LoadCallback(Transition{S_f: OP, S_i: V_f}, capture_lvar)
+	executor.LoadCallback(Transition{S_f: PW, S_i: V_f}, capture_lvar)
+	executor.LoadCallback(Transition{S_f: STR_i, S_i: V_f}, capture_lvar)
+	executor.LoadCallback(Transition{S_f: RC, S_i: V_f}, capture_lvar)
+	executor.LoadCallback(Transition{S_f: TG, S_i: V_f}, capture_lvar)
+	executor.LoadCallback(Transition{S_f: FS, S_i: V_f}, capture_lvar)
+	executor.LoadCallback(Transition{S_f: NUM, S_i: V_f}, capture_lvar)
 	// P Parenthetical state maps
 	// P_i setup
+	executor.TransitionMap[P_i] = P_i_Mapper
+	executor.TransitionMap[P_f] = P_f_Mapper
 	// Non-P state into P state triggers no effective callbacks
 	// Only P_i to P_i captures
 	P_i_to_P_i := Transition{S_f: P_i, S_i: P_i}
@@ -288,6 +856,16 @@ func TransitionExecutor_Init() TransitionExecutor {
 	capture_parenthetical := []TransitionCallback{executor.CaptureParenthetical}
 	executor.LoadCallback(P_i_to_P_f, capture_parenthetical)
 	// L Lambda state maps
+	executor.TransitionMap[L_i] = L_i_Mapper
+	executor.TransitionMap[LV1] = LV1_Mapper
+	executor.TransitionMap[LV2] = LV2_Mapper
+	executor.TransitionMap[LT] = LT_Mapper
+	executor.TransitionMap[LE1] = LE1_Mapper
+	executor.TransitionMap[LE2] = LE2_Mapper
+	executor.TransitionMap[LE3] = LE3_Mapper
+	executor.TransitionMap[LV3] = LV3_Mapper
+	executor.TransitionMap[LP1] = LP1_Mapper
+	executor.TransitionMap[L_f] = L_f_Mapper
 	transition_to_LV1 := Transition{S_f: LV1, S_i: DUMMY}
 	executor.LoadCallback(transition_to_LV1, build_lvar)
 	transition_to_LV2 := Transition{S_f: LV2, S_i: DUMMY}
@@ -297,60 +875,235 @@ func TransitionExecutor_Init() TransitionExecutor {
 	LP1_to_L_f := Transition{S_f: L_f, S_i: LP1}
 	capture_lambda := []TransitionCallback{executor.CaptureLambda}
 	executor.LoadCallback(LP1_to_L_f, capture_lambda)
+	LT_to_LT := Transition{S_f: LT, S_i: LT}
+	executor.LoadCallback(LT_to_LT, []TransitionCallback{executor.BuildAnnotation})
+	LT_to_LV3 := Transition{S_f: LV3, S_i: LT}
+	executor.LoadCallback(LT_to_LV3, []TransitionCallback{executor.CaptureAnnotation})
+	executor.LoadCallback(Transition{S_f: LE1, S_i: LT}, []TransitionCallback{executor.CaptureAnnotation})
+	LE2_to_LE2 := Transition{S_f: LE2, S_i: LE2}
+	executor.LoadCallback(LE2_to_LE2, build_parenthetical)
+	LE2_to_LE3 := Transition{S_f: LE3, S_i: LE2}
+	executor.LoadCallback(LE2_to_LE3, []TransitionCallback{executor.CaptureLetBound})
+	// System F: type abstraction and type argument state maps
+	executor.TransitionMap[TA] = TA_Mapper
+	executor.TransitionMap[TY_i] = TY_i_Mapper
+	executor.TransitionMap[TY_f] = TY_f_Mapper
+	TA_to_TA := Transition{S_f: TA, S_i: TA}
+	executor.LoadCallback(TA_to_TA, []TransitionCallback{executor.BuildTypeBinder})
+	TY_i_to_TY_i := Transition{S_f: TY_i, S_i: TY_i}
+	executor.LoadCallback(TY_i_to_TY_i, []TransitionCallback{executor.BuildAnnotation})
+	TY_i_to_TY_f := Transition{S_f: TY_f, S_i: TY_i}
+	executor.LoadCallback(TY_i_to_TY_f, []TransitionCallback{executor.CaptureTypeArgument})
+	// Dependent types: universes and Π, which shares the lambda states
+	executor.TransitionMap[U] = U_Mapper
+	U_to_U := Transition{S_f: U, S_i: U}
+	executor.LoadCallback(U_to_U, []TransitionCallback{executor.BuildUniverse})
+	capture_universe := []TransitionCallback{executor.CaptureUniverse}
+	for _, next := range []ParserState{V_i, L_i, P_i, TA, TY_i, NUM, OP, PW, STR_i, RC, TG, E_0} {
+		executor.LoadCallback(Transition{S_f: next, S_i: U}, capture_universe)
+	}
+	executor.LoadCallback(Transition{S_f: L_i, S_i: DUMMY}, []TransitionCallback{executor.MarkBinder})
+	// Primitive data: numbers, operators, primitive names and strings
+	executor.TransitionMap[NUM] = NUM_Mapper
+	executor.TransitionMap[OP] = OP_Mapper
+	executor.TransitionMap[PW] = PW_Mapper
+	executor.TransitionMap[STR_i] = STR_i_Mapper
+	executor.TransitionMap[STR_f] = STR_f_Mapper
+	build_literal := []TransitionCallback{executor.BuildLiteral}
+	executor.LoadCallback(Transition{S_f: NUM, S_i: DUMMY}, build_literal)
+	executor.LoadCallback(Transition{S_f: OP, S_i: DUMMY}, build_literal)
+	executor.LoadCallback(Transition{S_f: PW, S_i: PW}, build_literal)
+	executor.LoadCallback(Transition{S_f: STR_i, S_i: STR_i}, build_literal)
+	executor.LoadCallback(Transition{S_f: STR_f, S_i: STR_i}, []TransitionCallback{executor.CaptureString})
+	// Each ends where the next expression (or the input) starts, as a variable does
+	literal_ends := map[ParserState][]TransitionCallback{
+		NUM: {executor.CaptureNumber},
+		OP:  {executor.CaptureOperator},
+		PW:  {executor.CapturePrimName},
+		FS:  {executor.CaptureProjection},
+		TG:  {executor.CaptureTag},
+	}
+	// Case branches: the constructor, then fields, then a body read as a lambda's
+	executor.TransitionMap[CB] = CB_Mapper
+	executor.LoadCallback(Transition{S_f: CB, S_i: PW}, []TransitionCallback{executor.CaptureBranchName})
+	executor.LoadCallback(Transition{S_f: CB, S_i: CB}, []TransitionCallback{executor.BuildBranchField})
+	executor.LoadCallback(Transition{S_f: LP1, S_i: CB}, []TransitionCallback{executor.BuildBranchField})
+	// Records and variants: literals, projections and tags
+	executor.TransitionMap[RC] = RC_Mapper
+	executor.TransitionMap[RE] = RE_Mapper
+	executor.TransitionMap[RF] = RF_Mapper
+	executor.TransitionMap[RS] = RS_Mapper
+	executor.TransitionMap[R_f] = R_f_Mapper
+	executor.TransitionMap[FS] = FS_Mapper
+	executor.TransitionMap[TG] = TG_Mapper
+	executor.LoadCallback(Transition{S_f: RC, S_i: DUMMY}, []TransitionCallback{executor.MarkRecordStart})
+	executor.LoadCallback(Transition{S_f: RC, S_i: RC}, build_literal)
+	executor.LoadCallback(Transition{S_f: RE, S_i: RC}, []TransitionCallback{executor.CaptureRecordLabel})
+	executor.LoadCallback(Transition{S_f: RF, S_i: RF}, build_parenthetical)
+	executor.LoadCallback(Transition{S_f: RS, S_i: RF}, []TransitionCallback{executor.CaptureRecordField})
+	capture_record := []TransitionCallback{executor.CaptureRecord}
+	executor.LoadCallback(Transition{S_f: R_f, S_i: RC}, capture_record)
+	executor.LoadCallback(Transition{S_f: R_f, S_i: RS}, capture_record)
+	executor.LoadCallback(Transition{S_f: FS, S_i: FS}, []TransitionCallback{executor.BuildProjection})
+	executor.LoadCallback(Transition{S_f: TG, S_i: TG}, build_literal)
+	executor.LoadCallback(Transition{S_f: CB, S_i: TG}, []TransitionCallback{executor.CaptureTagBranch})
+	for state, capture := range literal_ends {
+		for _, next := range []ParserState{V_i, L_i, P_i, TA, TY_i, U, NUM, OP, PW, STR_i, RC, TG, E_0} {
+			// A letter continues a primitive name, label or tag rather than starting a variable
+			if next == state || ((state == PW || state == FS || state == TG) && next == V_i) {
+				continue
+			}
+			executor.LoadCallback(Transition{S_f: next, S_i: state}, capture)
+		}
+	}
+	// Remember where each expression starts, for Spans
+	mark_start := []TransitionCallback{executor.MarkStart}
+	executor.LoadCallback(transition_to_V_i, mark_start)
+	executor.LoadCallback(Transition{S_f: L_i, S_i: DUMMY}, mark_start)
+	executor.LoadCallback(Transition{S_f: P_i, S_i: DUMMY}, mark_start)
+	executor.LoadCallback(Transition{S_f: TA, S_i: DUMMY}, mark_start)
+	executor.LoadCallback(Transition{S_f: TY_i, S_i: DUMMY}, mark_start)
+	executor.LoadCallback(Transition{S_f: U, S_i: DUMMY}, mark_start)
+	executor.LoadCallback(Transition{S_f: NUM, S_i: DUMMY}, mark_start)
+	executor.LoadCallback(Transition{S_f: OP, S_i: DUMMY}, mark_start)
+	executor.LoadCallback(Transition{S_f: PW, S_i: DUMMY}, mark_start)
+	executor.LoadCallback(Transition{S_f: STR_i, S_i: DUMMY}, mark_start)
+	executor.LoadCallback(Transition{S_f: TG, S_i: DUMMY}, mark_start)
 	return executor
 }
 
 func (t *TransitionExecutor) Parse(target_str string) (Parser, error) {
-	target_str = strings.ToUpper(target_str)
+	return t.ParseTraced(target_str, nil)
+}
+
+// ParseTraced parses as Parse does, reporting each transition and callback to tracer.
+func (t *TransitionExecutor) ParseTraced(target_str string, tracer ParseTracer) (Parser, error) {
 	p := Parser_Init()
-	for _, char := range target_str {
+	p.Tracer = tracer
+	return t.Run(p, target_str)
+}
+
+// Run drives an already initialised parser over target_str.
+func (t *TransitionExecutor) Run(p Parser, target_str string) (Parser, error) {
+	target_str = UpperOutsideStrings(target_str)
+	for i, char := range target_str {
 		// Keep track of current parentheses nesting level (specifically for LP1, P_i, and P_f states)
 		// NOTE: TransitionMapper has parser and next char as input. p.NestTracker has nesting level
 		// INCLUDING NEXT CHAR. So if next char is ) and parse level is 0, then closing ) has been found
 		// for a previous opening ( at the same depth. Important for designing P_i and LP1 mappers.
-		fmt.Printf("Current state (HEAD %v, TAIL %v) reading char %v",
-			p.TState.S_f.ToString(),
-			p.TState.S_i.ToString(),
-			char)
+		p.Offset = i
 		p.NestTracker.Update(string(char))
-		// For current state, find and apply callback to determine next state using next char
-		current_transition, transition_err := t.TransitionMap[p.TState.S_f](p, string(char))
-		if transition_err != nil {
-			// TODO: Nest error in context including most recent state and last read char
-			return p, transition_err
+		if p.NestTracker.IsStray() {
+			stray_err := p.NestTracker.StrayError()
+			stray_err.State = p.TState.S_f
+			p.TraceFailure(stray_err, string(char))
+			if !p.Recover {
+				return p, stray_err
+			}
+			p = t.RecoverFrom(p, stray_err, string(char))
+			continue
 		}
-		p.TState = current_transition
-		var callback_err error = nil
-		// Apply all callbacks necessary based off most recent and current states in Parser Transition field
-		p, callback_err = t.Apply(p, string(char))
-		if callback_err != nil {
-			// TODO: Nest error in context including most recent state, last read char, callback, etc
-			return p, callback_err
+		if p.Skipping != nil {
+			if !p.CanResync(string(char)) {
+				p = p.Skip(string(char))
+				continue
+			}
+			p.Skipping = nil
+		}
+		var step_err error = nil
+		p, step_err = t.Step(p, string(char))
+		if step_err != nil {
+			if !p.Recover {
+				return p, step_err
+			}
+			p = t.RecoverFrom(p, step_err, string(char))
+		}
+	}
+	p.Offset = len(target_str)
+	if p.Skipping == nil && !p.TState.S_f.IsTerminal() {
+		end_err := t.EndOfInputError(p)
+		p.TraceFailure(end_err, "")
+		if !p.Recover {
+			return p, end_err
 		}
+		p = t.RecoverAtEnd(p, end_err)
 	}
 	// Transition into terminal state E_0 and run final callbacks in response
 	final_transition := Transition{S_i: p.TState.S_f, S_f: E_0}
 	p.TState = final_transition
+	p.Trace(TraceEvent{
+		Kind:  TE_End,
+		From:  p.TState.S_i.ToString(),
+		To:    p.TState.S_f.ToString(),
+		Depth: p.NestTracker.Counter,
+	})
 	var callback_err error = nil
 	p, callback_err = t.Apply(p, "")
 	if callback_err != nil {
-		return p, callback_err
+		return p, p.TraceFailure(p.PositionError(callback_err, ""), "")
 	}
 	return p, nil
 }
 
-func (t *TransitionExecutor) FilterCallbacks(ts Transition) []TransitionCallback {
-	ts_filter := func(ts_input Transition) bool {
-		exact_ts_match := ts == ts_input
-		initial_only := Transition{S_i: ts.S_i, S_f: DUMMY} == ts_input
-		final_only := Transition{S_i: DUMMY, S_f: ts.S_f} == ts_input
-		return exact_ts_match || initial_only || final_only
+// Input ran out in a state that cannot complete an expression. Unclosed parentheses are
+// reported at the outermost "(" still open, anything else at the end of input.
+func (t *TransitionExecutor) EndOfInputError(p Parser) *ParseError {
+	if p.NestTracker.InString {
+		return &ParseError{
+			Offset: p.Offset,
+			State:  p.TState.S_f,
+			Err:    fmt.Errorf("Input ended inside a string literal, it needs a closing \""),
+		}
+	}
+	if unclosed := p.NestTracker.UnclosedErrors(); len(unclosed) > 0 {
+		unclosed[0].State = p.TState.S_f
+		return unclosed[0]
+	}
+	return &ParseError{
+		Offset: p.Offset,
+		State:  p.TState.S_f,
+		Err: fmt.Errorf(
+			"Input ended in state %v before the expression was complete",
+			p.TState.S_f.ToString(),
+		),
 	}
+}
+
+// Step feeds a single character through the mapper of the current state and the callbacks
+// of the resulting transition. The ParenTracker must already include the character.
+func (t *TransitionExecutor) Step(p Parser, s string) (Parser, error) {
+	// For current state, find and apply callback to determine next state using next char
+	current_transition, transition_err := t.TransitionMap[p.TState.S_f](p, s)
+	if transition_err != nil {
+		return p, p.TraceFailure(p.PositionError(transition_err, s), s)
+	}
+	p.TState = current_transition
+	p.Trace(TraceEvent{
+		Kind:   TE_Step,
+		From:   p.TState.S_i.ToString(),
+		To:     p.TState.S_f.ToString(),
+		Char:   s,
+		Offset: p.Offset,
+		Depth:  p.NestTracker.Counter,
+	})
+	var callback_err error = nil
+	// Apply all callbacks necessary based off most recent and current states in Parser Transition field
+	p, callback_err = t.Apply(p, s)
+	if callback_err != nil {
+		return p, p.TraceFailure(p.PositionError(callback_err, s), s)
+	}
+	return p, nil
+}
+
+// Callbacks run in a fixed order: those keyed on leaving S_i, then those keyed on the exact
+// transition, then those keyed on entering S_f. Map iteration order would otherwise decide
+// whether e.g. CaptureLVar runs before or after BuildLVar on a V_f -> V_i transition.
+func (t *TransitionExecutor) FilterCallbacks(ts Transition) []TransitionCallback {
 	selected_callbacks := []TransitionCallback{}
-	for k, v := range t.TransitionCallbackMap {
-		if ts_filter(k) {
-			selected_callbacks = append(selected_callbacks, v...)
-		}
+	initial_only := Transition{S_i: ts.S_i, S_f: DUMMY}
+	final_only := Transition{S_i: DUMMY, S_f: ts.S_f}
+	for _, key := range []Transition{initial_only, ts, final_only} {
+		selected_callbacks = append(selected_callbacks, t.TransitionCallbackMap[key]...)
 	}
 	return selected_callbacks
 }
@@ -358,6 +1111,15 @@ func (t *TransitionExecutor) FilterCallbacks(ts Transition) []TransitionCallback
 func (t *TransitionExecutor) Apply(p Parser, s string) (Parser, error) {
 	callback_list := t.FilterCallbacks((p.TState))
 	for _, callback := range callback_list {
+		if p.Tracer != nil {
+			p.Trace(TraceEvent{
+				Kind:     TE_Callback,
+				From:     p.TState.S_i.ToString(),
+				To:       p.TState.S_f.ToString(),
+				Depth:    p.NestTracker.Counter,
+				Callback: CallbackName(callback),
+			})
+		}
 		var callback_err error = nil
 		p, callback_err = callback(p, s)
 		if callback_err != nil {
@@ -379,39 +1141,343 @@ func (t *TransitionExecutor) BuildParenthetical(p Parser, s string) (Parser, err
 	return p, nil
 }
 
+func (t *TransitionExecutor) BuildAnnotation(p Parser, s string) (Parser, error) {
+	p.Annotation += s
+	return p, nil
+}
+
+func (t *TransitionExecutor) BuildTypeBinder(p Parser, s string) (Parser, error) {
+	p.TypeBinder += s
+	return p, nil
+}
+
+func (t *TransitionExecutor) BuildUniverse(p Parser, s string) (Parser, error) {
+	p.Universe += s
+	return p, nil
+}
+
+func (t *TransitionExecutor) BuildLiteral(p Parser, s string) (Parser, error) {
+	p.Literal += s
+	return p, nil
+}
+
+// Whether the binder just opened is a lambda or a Π, and whether it is recursive.
+func (t *TransitionExecutor) MarkBinder(p Parser, s string) (Parser, error) {
+	if s == "@" {
+		p.Recursive = true
+		return p, nil
+	}
+	p.Pi = s == "Π"
+	return p, nil
+}
+
+func (t *TransitionExecutor) CaptureUniverse(p Parser, s string) (Parser, error) {
+	level := 0
+	if len(p.Universe) > 0 {
+		parsed, atoi_err := strconv.Atoi(p.Universe)
+		if atoi_err != nil {
+			return p, fmt.Errorf("Universe level %v is out of range", p.Universe)
+		}
+		level = parsed
+	}
+	new_universe := &LUniverse{Level: level}
+	p.Universe = ""
+	p.Exprs = append(p.Exprs, new_universe)
+	p = p.Span(new_universe, Span{Offset: p.Start, End: p.Offset})
+	return p, nil
+}
+
+func (t *TransitionExecutor) CaptureNumber(p Parser, s string) (Parser, error) {
+	if p.Literal == Negative_Sign {
+		return p, fmt.Errorf("Expected digits after the %v of a negative literal", Negative_Sign)
+	}
+	value, atoi_err := strconv.ParseInt(strings.Replace(p.Literal, Negative_Sign, "-", 1), 10, 64)
+	if atoi_err != nil {
+		return p, fmt.Errorf("Integer literal %v is out of range", p.Literal)
+	}
+	return t.capturePrim(p, &LInt{Value: value}, p.Offset)
+}
+
+func (t *TransitionExecutor) CaptureOperator(p Parser, s string) (Parser, error) {
+	prim, found := LookupPrim(p.Literal)
+	if _, is_op := prim.(*LPrim); !found || !is_op || p.Literal == PRIM_If.ToString() {
+		return p, fmt.Errorf("Unknown operator %v, expected one of + - < == ++", p.Literal)
+	}
+	return t.capturePrim(p, prim, p.Offset)
+}
+
+// Names that are no primitive are constructors, checked against data declarations later.
+func (t *TransitionExecutor) CapturePrimName(p Parser, s string) (Parser, error) {
+	prim, found := LookupPrim("@" + p.Literal)
+	if p.Literal == Case_Keyword {
+		prim = &LCase{}
+	} else if !found {
+		prim = &LCon{Name: p.Literal}
+	}
+	return t.capturePrim(p, prim, p.Offset)
+}
+
+// The constructor a branch matches, which must not be a primitive.
+func (t *TransitionExecutor) CaptureBranchName(p Parser, s string) (Parser, error) {
+	if _, is_prim := LookupPrim("@" + p.Literal); is_prim || p.Literal == Case_Keyword {
+		return p, fmt.Errorf("Case branches match constructors, @%v is a primitive (use @IF on booleans)", p.Literal)
+	}
+	p.Branch = p.Literal
+	p.BranchFields = []string{}
+	p.Literal = ""
+	return p, nil
+}
+
+// Letters and digits of a field, which "." ends; the "(" of the body must follow a ".".
+func (t *TransitionExecutor) BuildBranchField(p Parser, s string) (Parser, error) {
+	if s != "." && s != "(" {
+		p.LVar += s
+		return p, nil
+	}
+	if s == "(" && len(p.LVar) > 0 {
+		return p, fmt.Errorf("Field %v of branch %v must be followed by . before the body", p.LVar, BranchHead(p.Branch))
+	} else if s == "." && !IsVariableName(p.LVar) {
+		return p, fmt.Errorf("Branch %v binds %q, fields must be variables such as X1", BranchHead(p.Branch), p.LVar)
+	} else if s == "." {
+		p.BranchFields = append(p.BranchFields, p.LVar)
+		p.LVar = ""
+	}
+	return p, nil
+}
+
+// The tag a branch matches, 'OK.X1.(B).
+func (t *TransitionExecutor) CaptureTagBranch(p Parser, s string) (Parser, error) {
+	if len(p.Literal) == 0 {
+		return p, fmt.Errorf("Tag ' has no letters, as in 'OK.X1.(B)")
+	}
+	p.Branch = Tag_Prefix + p.Literal
+	p.BranchFields = []string{}
+	p.Literal = ""
+	return p, nil
+}
+
+func (t *TransitionExecutor) CaptureTag(p Parser, s string) (Parser, error) {
+	if len(p.Literal) == 0 {
+		return p, fmt.Errorf("Tag ' has no letters, as in 'OK(E)")
+	}
+	return t.capturePrim(p, &LTag{Label: p.Literal}, p.Offset)
+}
+
+// A record literal starts at its "{", not at the "," before a later field.
+func (t *TransitionExecutor) MarkRecordStart(p Parser, s string) (Parser, error) {
+	if p.TState.S_i != RC && p.TState.S_i != RS {
+		p.Start = p.Offset
+		p.RecordLabels = []string{}
+		p.RecordFields = []LExpr{}
+	}
+	return p, nil
+}
+
+func (t *TransitionExecutor) CaptureRecordLabel(p Parser, s string) (Parser, error) {
+	if len(p.Literal) == 0 {
+		return p, fmt.Errorf("Field of a record literal has no label, as in {A=(E)}")
+	} else if slices.Contains(p.RecordLabels, p.Literal) {
+		return p, fmt.Errorf("Field %v appears twice in a record literal", p.Literal)
+	}
+	p.RecordLabels = append(p.RecordLabels, p.Literal)
+	p.Literal = ""
+	return p, nil
+}
+
+func (t *TransitionExecutor) CaptureRecordField(p Parser, s string) (Parser, error) {
+	p, field, parse_err := t.ParseNested(p)
+	if parse_err != nil {
+		return p, parse_err
+	}
+	p = p.Span(&field, Span{Offset: p.Offset - len(p.Parenthetical) - 1, End: p.Offset + 1})
+	p.Parenthetical = ""
+	p.RecordFields = append(p.RecordFields, &field)
+	return p, nil
+}
+
+func (t *TransitionExecutor) CaptureRecord(p Parser, s string) (Parser, error) {
+	if p.TState.S_i == RC && (len(p.Literal) > 0 || len(p.RecordLabels) > 0) {
+		// {A} or {A=(1),}, only {} closes without a field
+		return p, fmt.Errorf("Record literal expects a field label followed by =(E) before }")
+	}
+	new_record := &LRecord{Labels: p.RecordLabels, Fields: p.RecordFields}
+	p.RecordLabels = nil
+	p.RecordFields = nil
+	p.Exprs = append(p.Exprs, new_record)
+	p = p.Span(new_record, Span{Offset: p.Start, End: p.Offset + 1})
+	return p, nil
+}
+
+// Letters of the label being selected; a "." selects it and starts another.
+func (t *TransitionExecutor) BuildProjection(p Parser, s string) (Parser, error) {
+	if s != "." {
+		p.Literal += s
+		return p, nil
+	}
+	return t.CaptureProjection(p, s)
+}
+
+// The expression just read, the last of p.Exprs, is the record selected from.
+func (t *TransitionExecutor) CaptureProjection(p Parser, s string) (Parser, error) {
+	if len(p.Exprs) == 0 {
+		return p, fmt.Errorf("Projection .%v has no record to select from", p.Literal)
+	} else if len(p.Literal) == 0 {
+		return p, fmt.Errorf("Projection . has no label, as in R1.PORT")
+	}
+	record := p.Exprs[len(p.Exprs)-1]
+	new_proj := &LProj{Record: record, Label: p.Literal}
+	p.Literal = ""
+	p.Exprs[len(p.Exprs)-1] = new_proj
+	p = p.Span(new_proj, Span{Offset: p.Spans[record].Offset, End: p.Offset})
+	return p, nil
+}
+
+// The literal read between the quotes, with Go escapes.
+func (t *TransitionExecutor) CaptureString(p Parser, s string) (Parser, error) {
+	value, unquote_err := strconv.Unquote("\"" + p.Literal + "\"")
+	if unquote_err != nil {
+		return p, fmt.Errorf("Invalid string literal \"%v\": %v", p.Literal, unquote_err)
+	}
+	return t.capturePrim(p, &LString{Value: value}, p.Offset+1)
+}
+
+func (t *TransitionExecutor) capturePrim(p Parser, prim LExpr, end int) (Parser, error) {
+	p.Literal = ""
+	p.Exprs = append(p.Exprs, prim)
+	p = p.Span(prim, Span{Offset: p.Start, End: end})
+	return p, nil
+}
+
+func (t *TransitionExecutor) MarkStart(p Parser, s string) (Parser, error) {
+	// Self loops (V_i -> V_i, P_i -> P_i) are still inside the same expression
+	if p.TState.S_i != p.TState.S_f {
+		p.Start = p.Offset
+	}
+	return p, nil
+}
+
+// Parse the annotation read since ":" now that "." (or the "=" of a let) ends it, both as a
+// type and as a term. Only an annotation that is neither is an error, reported as a type error.
+func (t *TransitionExecutor) CaptureAnnotation(p Parser, s string) (Parser, error) {
+	binding_type, type_err := ParseType(p.Annotation)
+	nested := p.Nested()
+	nested.Recover = false
+	if domain_parse, domain_err := t.Run(nested, p.Annotation); domain_err == nil {
+		base := p.Offset - len(p.Annotation)
+		for l, span := range domain_parse.Spans {
+			p = p.Span(l, Span{Offset: span.Offset + base, End: span.End + base})
+		}
+		p.BindingDomain = SingleLExpr(domain_parse.Exprs)
+		if type_err != nil {
+			p.Annotation = ""
+			return p, nil
+		}
+	}
+	if type_err != nil {
+		var parse_err *ParseError
+		if errors.As(type_err, &parse_err) {
+			located := *parse_err
+			located.Offset += p.Offset - len(p.Annotation)
+			located.State = LT
+			return p, &located
+		}
+		return p, type_err
+	}
+	p.BindingType = binding_type
+	p.Annotation = ""
+	return p, nil
+}
+
+// Parse the type read since "[" now that "]" ends it.
+func (t *TransitionExecutor) CaptureTypeArgument(p Parser, s string) (Parser, error) {
+	arg_type, type_err := ParseType(p.Annotation)
+	if type_err != nil {
+		var parse_err *ParseError
+		if errors.As(type_err, &parse_err) {
+			located := *parse_err
+			located.Offset += p.Offset - len(p.Annotation)
+			located.State = TY_i
+			return p, &located
+		}
+		return p, type_err
+	}
+	new_arg := &LTypeArg{Type: arg_type}
+	p.Annotation = ""
+	p.Exprs = append(p.Exprs, new_arg)
+	p = p.Span(new_arg, Span{Offset: p.Start, End: p.Offset + 1})
+	return p, nil
+}
+
 func (t *TransitionExecutor) CaptureLVar(p Parser, s string) (Parser, error) {
 	new_lvar := LVar{Symbol: p.LVar}
 	p.LVar = ""
 	p.Exprs = append(p.Exprs, &new_lvar)
+	p = p.Span(&new_lvar, Span{Offset: p.Start, End: p.Offset})
 	return p, nil
 }
 
+// Parse p.Parenthetical on its own, adopting its errors and spans. p.Offset sits on the
+// closing ")".
+func (t *TransitionExecutor) ParseNested(p Parser) (Parser, LExpression, error) {
+	inner_parse, parse_err := t.Run(p.Nested(), p.Parenthetical)
+	if parse_err != nil {
+		return p, LExpression{}, p.NestedError(parse_err)
+	}
+	for _, inner_err := range inner_parse.Errors {
+		// Shift in place so LError nodes of the inner parse keep pointing at the same error
+		*inner_err = *p.NestedError(inner_err)
+		p.Errors = append(p.Errors, inner_err)
+	}
+	return p.NestedSpans(inner_parse), ConcatenateLExprs(inner_parse.Exprs), nil
+}
+
 func (t *TransitionExecutor) CaptureParenthetical(p Parser, s string) (Parser, error) {
-	inner_parse, parse_err := t.Parse(p.Parenthetical)
+	p, new_lexpr, parse_err := t.ParseNested(p)
 	if parse_err != nil {
-		parse_err = fmt.Errorf(
-			"Following error emerges while parsing %v within a parenthetical:\n%w",
-			p.Parenthetical,
-			parse_err,
-		)
 		return p, parse_err
 	}
-	new_lexpr := ConcatenateLExprs(inner_parse.Exprs)
+	p = p.Span(&new_lexpr, Span{Offset: p.Start, End: p.Offset + 1})
 	p.Parenthetical = ""
 	p.Exprs = append(p.Exprs, &new_lexpr)
 	return p, nil
 }
 
+// The bound term of a let is held until the body is read.
+func (t *TransitionExecutor) CaptureLetBound(p Parser, s string) (Parser, error) {
+	p, bound, parse_err := t.ParseNested(p)
+	if parse_err != nil {
+		return p, parse_err
+	}
+	p = p.Span(&bound, Span{Offset: p.Offset - len(p.Parenthetical) - 1, End: p.Offset + 1})
+	p.Parenthetical = ""
+	p.LetBound = &bound
+	return p, nil
+}
+
 func (t *TransitionExecutor) CaptureLambda(p Parser, s string) (Parser, error) {
-	inner_parse, parse_err := t.Parse(p.Parenthetical)
+	p, new_lexpr, parse_err := t.ParseNested(p)
 	if parse_err != nil {
-		parse_err = fmt.Errorf(
-			"Following error emerges while parsing %v within a parenthetical:\n%w",
-			p.Parenthetical,
-			parse_err,
-		)
 		return p, parse_err
 	}
+	if len(p.Branch) > 0 {
+		new_branch := &LBranch{Con: p.Branch, Fields: p.BranchFields, Body: &new_lexpr}
+		p = p.Span(&new_lexpr, Span{Offset: p.Offset - len(p.Parenthetical) - 1, End: p.Offset + 1})
+		p = p.Span(new_branch, Span{Offset: p.Start, End: p.Offset + 1})
+		p.Parenthetical = ""
+		p.Branch = ""
+		p.BranchFields = nil
+		p.Exprs = append(p.Exprs, new_branch)
+		return p, nil
+	}
+	if len(p.TypeBinder) > 0 {
+		new_abs := &LTyAbs{Var: p.TypeBinder, Body: &new_lexpr}
+		p = p.Span(&new_lexpr, Span{Offset: p.Offset - len(p.Parenthetical) - 1, End: p.Offset + 1})
+		p = p.Span(new_abs, Span{Offset: p.Start, End: p.Offset + 1})
+		p.Parenthetical = ""
+		p.TypeBinder = ""
+		p.Exprs = append(p.Exprs, new_abs)
+		return p, nil
+	}
 	//TODO: Add sturdier check that p.LVar actually fits var criteria
 	// consider adding a Parser method to simultaneously blank out LVar field
 	// and return a LVar term
@@ -421,10 +1487,29 @@ func (t *TransitionExecutor) CaptureLambda(p Parser, s string) (Parser, error) {
 			p.Parenthetical,
 		)
 	}
-	new_lexpr := ConcatenateLExprs(inner_parse.Exprs)
-	new_lambda := new_lexpr.LAbstract(LVar{Symbol: p.LVar})
+	if p.Recursive && (p.LetBound == nil || p.Pi) {
+		return p, fmt.Errorf(
+			"Recursive binding of %v needs a bound term, as in λ@%v=(E).(B)",
+			p.LVar,
+			p.LVar,
+		)
+	}
+	binding := LVar{Symbol: p.LVar, Type: p.BindingType, Domain: p.BindingDomain}
+	new_lambda := new_lexpr.LAbstract(binding)
+	if p.LetBound != nil {
+		new_lambda = &LLet{Binding: binding, Bound: p.LetBound, Body: &new_lexpr, Recursive: p.Recursive}
+	} else if p.Pi {
+		new_lambda = &LPi{Binding: binding, Body: &new_lexpr}
+	}
+	p = p.Span(&new_lexpr, Span{Offset: p.Offset - len(p.Parenthetical) - 1, End: p.Offset + 1})
+	p = p.Span(new_lambda, Span{Offset: p.Start, End: p.Offset + 1})
 	p.Parenthetical = ""
 	p.LVar = ""
+	p.BindingType = nil
+	p.BindingDomain = nil
+	p.Pi = false
+	p.Recursive = false
+	p.LetBound = nil
 	p.Exprs = append(p.Exprs, new_lambda)
 	return p, nil
 }
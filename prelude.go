@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Standard definitions, written in the same syntax the Decoder reads from files. Variables
+// need a numeric suffix and can never contain L, hence names like B1/E1 in POW.
+const Prelude_Source = `
+# Combinators
+I = LX1.(X1)
+K = LX1.(LY1.(X1))
+S = LX1.(LY1.(LZ1.(X1Z1(Y1Z1))))
+B = LF1.(LG1.(LX1.(F1(G1X1))))
+FLIP = LF1.(LX1.(LY1.(F1Y1X1)))
+OMEGA = (LX1.(X1X1))(LX1.(X1X1))
+Y = LF1.((LX1.(F1(X1X1)))(LX1.(F1(X1X1))))
+
+# Church booleans
+TRUE = LX1.(LY1.(X1))
+FALSE = LX1.(LY1.(Y1))
+NOT = LP1.(P1(LX1.(LY1.(Y1)))(LX1.(LY1.(X1))))
+AND = LP1.(LQ1.(P1Q1P1))
+OR = LP1.(LQ1.(P1P1Q1))
+
+# Church pairs
+PAIR = LX1.(LY1.(LF1.(F1X1Y1)))
+FST = LP1.(P1(LX1.(LY1.(X1))))
+SND = LP1.(P1(LX1.(LY1.(Y1))))
+
+# Church numerals
+ZERO = LF1.(LX1.(X1))
+SUCC = LN1.(LF1.(LX1.(F1(N1F1X1))))
+PLUS = LM1.(LN1.(LF1.(LX1.(M1F1(N1F1X1)))))
+MULT = LM1.(LN1.(LF1.(M1(N1F1))))
+POW = LB1.(LE1.(E1B1))
+PRED = LN1.(LF1.(LX1.(N1(LG1.(LH1.(H1(G1F1))))(LU1.(X1))(LU1.(U1)))))
+ISZERO = LN1.(N1(LX1.(LX2.(LY2.(Y2))))(LX1.(LY1.(X1))))
+`
+
+type Definitions struct {
+	// Names in the order they were defined
+	Names []string
+	Exprs map[string]LExpr
+	Cores map[string]Core
+}
+
+// Decode every statement of r as a definition. Bare terms are rejected.
+func LoadDefinitions(r io.Reader) (Definitions, error) {
+	defs := Definitions{Names: []string{}, Exprs: map[string]LExpr{}, Cores: map[string]Core{}}
+	decoder := CreateDecoder(r)
+	for {
+		stmt, decode_err := decoder.Decode()
+		if decode_err == io.EOF {
+			return defs, nil
+		}
+		if decode_err != nil {
+			return defs, decode_err
+		}
+		if len(stmt.Name) == 0 {
+			return defs, fmt.Errorf("%v: expected a definition NAME = term", stmt.Pos.ToString())
+		}
+		c, lower_err := Lower(stmt.Expr)
+		if lower_err != nil {
+			return defs, fmt.Errorf("%v: %w", stmt.Pos.ToString(), lower_err)
+		}
+		if _, redefined := defs.Exprs[stmt.Name]; !redefined {
+			defs.Names = append(defs.Names, stmt.Name)
+		}
+		defs.Exprs[stmt.Name] = stmt.Expr
+		defs.Cores[stmt.Name] = c
+	}
+}
+
+func LoadPrelude() Definitions {
+	defs, load_err := LoadDefinitions(strings.NewReader(Prelude_Source))
+	if load_err != nil {
+		panic(fmt.Sprintf("Prelude_Source does not parse: %v", load_err))
+	}
+	return defs
+}
+
+// Church numeral λF1.λX1.F1(F1(...X1)) with n applications of F1.
+func ChurchNumeral(n int) Core {
+	var body Core = &CVar{Index: 0, Name: "X1"}
+	for i := 0; i < n; i++ {
+		body = &CApp{Fun: &CVar{Index: 1, Name: "F1"}, Arg: body}
+	}
+	return &CLam{Name: "F1", Body: &CLam{Name: "X1", Body: body}}
+}
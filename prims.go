@@ -0,0 +1,468 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+	Primitive data - integers, booleans, strings and their operators
+
+Literals are written as they read: 42, ~42 for minus 42 (- being the operator), "text" (Go
+escapes, the only part of the input whose case and whitespace are kept), @TRUE and @FALSE.
+The operators + - < on integers, ++ on strings, == on any two literals of the same kind,
+@IF C T E, the fixpoint @FIX G (see fix.go) and the control operators @RESET E and @SHIFT F
+(see control.go) are curried prefix functions applied like any other term, +(1)(2) or
++(X1)(Y1); parentheses separate a number from the variable or number before it, as X12
+would read as one variable.
+
+Lowering turns each of them into a free Core constant named "@" followed by its text ("@42",
+"@TRUE", "@\"text\"", "@+", "@IF") which every evaluator treats as an opaque atom. The
+reference reducer (Step) adds delta rules: an operator applied to exactly as many arguments
+as it takes first reduces its strict arguments to literals, left to right and with the same
+strategy, then is replaced by its result. @IF is only strict in its condition, so both of
+its branches are left alone until one is chosen, under every strategy. An operator applied
+to literals of the wrong kind, or whose result would overflow 64 bits, is stuck, like a free
+variable.
+*/
+type PrimOp int
+
+const (
+	_           PrimOp = iota
+	PRIM_Add           // + on integers
+	PRIM_Sub           // - on integers
+	PRIM_Less          // < on integers
+	PRIM_Equal         // == on two integers, booleans or strings
+	PRIM_Concat        // ++ on strings
+	PRIM_If            // @IF C T E, strict in C only
+	PRIM_Fix           // @FIX G, the fixpoint of G (see fix.go)
+	PRIM_Reset         // @RESET E, delimiting the continuations @SHIFT captures in E
+	PRIM_Shift         // @SHIFT F, F applied to the continuation up to the nearest @RESET
+)
+
+// Every operator, in the order error messages list them
+var All_Prim_Ops = []PrimOp{PRIM_Add, PRIM_Sub, PRIM_Less, PRIM_Equal, PRIM_Concat, PRIM_If, PRIM_Fix,
+	PRIM_Reset, PRIM_Shift}
+
+// Text of op as written.
+func (op PrimOp) ToString() string {
+	switch op {
+	case PRIM_Add:
+		return "+"
+	case PRIM_Sub:
+		return "-"
+	case PRIM_Less:
+		return "<"
+	case PRIM_Equal:
+		return "=="
+	case PRIM_Concat:
+		return "++"
+	case PRIM_If:
+		return "@IF"
+	case PRIM_Fix:
+		return "@FIX"
+	case PRIM_Reset:
+		return "@RESET"
+	case PRIM_Shift:
+		return "@SHIFT"
+	}
+	return "indeterminate operator"
+}
+
+func (op PrimOp) Arity() int {
+	switch op {
+	case PRIM_If:
+		return 3
+	case PRIM_Fix, PRIM_Reset, PRIM_Shift:
+		return 1
+	}
+	return 2
+}
+
+// Characters operators are spelled with
+func IsOperatorChar(s string) bool {
+	return len(s) > 0 && strings.Trim(s, "+-<=") == ""
+}
+
+// Letters of primitive names, L included
+func IsPrimNameChar(s string) bool {
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return len(s) > 0
+}
+
+// Operator or primitive spelled text, as written (+, @IF).
+func LookupPrim(text string) (LExpr, bool) {
+	switch text {
+	case "@TRUE":
+		return &LBool{Value: true}, true
+	case "@FALSE":
+		return &LBool{Value: false}, true
+	}
+	for _, op := range All_Prim_Ops {
+		if op.ToString() == text {
+			return &LPrim{Op: op}, true
+		}
+	}
+	return nil, false
+}
+
+type LInt struct {
+	Value int64
+}
+
+type LBool struct {
+	Value bool
+}
+
+type LString struct {
+	Value string
+}
+
+type LPrim struct {
+	Op PrimOp
+}
+
+// Sign of negative integer literals, as - already spells subtraction
+const Negative_Sign = "~"
+
+func (l *LInt) LPrint() string {
+	return strings.Replace(strconv.FormatInt(l.Value, 10), "-", Negative_Sign, 1)
+}
+
+func (l *LBool) LPrint() string {
+	if l.Value {
+		return "@TRUE"
+	}
+	return "@FALSE"
+}
+
+func (l *LString) LPrint() string {
+	return strconv.Quote(l.Value)
+}
+
+func (l *LPrim) LPrint() string {
+	return l.Op.ToString()
+}
+
+func primAbstract(l LExpr, b LVar) LExpr {
+	out_str := "λ" + b.BindingPrint() + ".(" + l.LPrint() + ")"
+	return &LExpression{
+		Binding: b,
+		Exprs:   []LExpr{l},
+		Repr:    out_str,
+	}
+}
+
+func (l *LInt) LAbstract(b LVar) LExpr {
+	return primAbstract(l, b)
+}
+
+func (l *LBool) LAbstract(b LVar) LExpr {
+	return primAbstract(l, b)
+}
+
+func (l *LString) LAbstract(b LVar) LExpr {
+	return primAbstract(l, b)
+}
+
+func (l *LPrim) LAbstract(b LVar) LExpr {
+	return primAbstract(l, b)
+}
+
+func (l *LInt) Copy() LExpr {
+	return &LInt{Value: l.Value}
+}
+
+func (l *LBool) Copy() LExpr {
+	return &LBool{Value: l.Value}
+}
+
+func (l *LString) Copy() LExpr {
+	return &LString{Value: l.Value}
+}
+
+func (l *LPrim) Copy() LExpr {
+	return &LPrim{Op: l.Op}
+}
+
+func (l *LInt) LApply(b LVar, replace LExpr) LExpr {
+	return l.Copy()
+}
+
+func (l *LBool) LApply(b LVar, replace LExpr) LExpr {
+	return l.Copy()
+}
+
+func (l *LString) LApply(b LVar, replace LExpr) LExpr {
+	return l.Copy()
+}
+
+func (l *LPrim) LApply(b LVar, replace LExpr) LExpr {
+	return l.Copy()
+}
+
+func (l *LInt) LEquals(l2 LExpr) bool {
+	return l.LPrint() == l2.LPrint()
+}
+
+func (l *LBool) LEquals(l2 LExpr) bool {
+	return l.LPrint() == l2.LPrint()
+}
+
+func (l *LString) LEquals(l2 LExpr) bool {
+	return l.LPrint() == l2.LPrint()
+}
+
+func (l *LPrim) LEquals(l2 LExpr) bool {
+	return l.LPrint() == l2.LPrint()
+}
+
+// Texts of lexprs run together, parenthesizing any that would otherwise be read as part of
+// the one before it: a number after X1 or 2, a letter after @TRUE, an operator after another.
+// Negative numbers are parenthesized after a number too, which they cannot follow directly.
+func SeqPrint(lexprs []LExpr) string {
+	out := ""
+	for _, expr := range lexprs {
+		text := expr.LPrint()
+		if len(out) > 0 && len(text) > 0 && tokensMerge(out[len(out)-1], text[0]) {
+			text = "(" + text + ")"
+		}
+		out += text
+	}
+	return out
+}
+
+func tokensMerge(last byte, first byte) bool {
+	is_digit := func(b byte) bool { return '0' <= b && b <= '9' }
+	is_letter := func(b byte) bool { return 'A' <= b && b <= 'Z' }
+	is_operator := func(b byte) bool { return IsOperatorChar(string(b)) }
+	return ((is_digit(first) || first == Negative_Sign[0]) && (is_digit(last) || last == '*')) ||
+		(is_letter(first) && is_letter(last)) ||
+		(is_operator(first) && is_operator(last)) ||
+		(first == '*' && last == '*')
+}
+
+// Prefix of the Core constants primitives lower to
+const Prim_Prefix = "@"
+
+// Name of the Core constant for a literal or operator, "" if l is neither.
+func PrimName(l LExpr) string {
+	switch node := l.(type) {
+	case *LInt:
+		return Prim_Prefix + strconv.FormatInt(node.Value, 10)
+	case *LBool, *LString, *LPrim:
+		return Prim_Prefix + strings.TrimPrefix(node.LPrint(), Prim_Prefix)
+	}
+	return ""
+}
+
+func PrimCore(l LExpr) Core {
+	return &CFree{Name: PrimName(l)}
+}
+
+// Literal or operator a Core constant stands for.
+func DecodePrim(c Core) (LExpr, bool) {
+	free, is_free := c.(*CFree)
+	if !is_free || !strings.HasPrefix(free.Name, Prim_Prefix) {
+		return nil, false
+	}
+	text := free.Name[len(Prim_Prefix):]
+	if value, atoi_err := strconv.ParseInt(text, 10, 64); atoi_err == nil {
+		return &LInt{Value: value}, true
+	} else if strings.HasPrefix(text, "\"") {
+		value, unquote_err := strconv.Unquote(text)
+		return &LString{Value: value}, unquote_err == nil
+	} else if prim, found := LookupPrim(text); found {
+		return prim, true
+	}
+	return LookupPrim(free.Name)
+}
+
+// Literal value of c, nil unless c is an integer, boolean or string constant.
+func DecodeLiteral(c Core) LExpr {
+	prim, is_prim := DecodePrim(c)
+	if _, is_op := prim.(*LPrim); !is_prim || is_op {
+		return nil
+	}
+	return prim
+}
+
+// StepPrim takes one delta step in c, if it is an operator applied to exactly as many
+// arguments as it takes: either an argument it is strict in is stepped under s, or all of
+// them are literals and the application is replaced by its result. Returns false when c is
+// no such application or is stuck.
+func StepPrim(c Core, s Strategy) (Core, bool) {
+	head, args := CoreSpine(c)
+	prim, is_prim := DecodePrim(head)
+	op, is_op := prim.(*LPrim)
+	if !is_prim || !is_op || len(args) != op.Op.Arity() {
+		return c, false
+	} else if op.Op == PRIM_Fix {
+		return stepFix(args[0], s)
+	} else if op.Op.IsControl() {
+		// Only the CEK machine runs them
+		return c, false
+	}
+	strict := len(args)
+	if op.Op == PRIM_If {
+		strict = 1
+	}
+	literals := []LExpr{}
+	for i, arg := range args[:strict] {
+		if literal := DecodeLiteral(arg); literal != nil {
+			literals = append(literals, literal)
+			continue
+		}
+		next, stepped := Step(arg, s)
+		if !stepped {
+			return c, false
+		}
+		args[i] = next
+		return CoreApply(head, args...), true
+	}
+	if op.Op == PRIM_If {
+		if cond, is_bool := literals[0].(*LBool); is_bool && cond.Value {
+			return args[1], true
+		} else if is_bool {
+			return args[2], true
+		}
+		return c, false
+	}
+	result, delta_err := Delta(op.Op, literals[0], literals[1])
+	if delta_err != nil {
+		return c, false
+	}
+	return PrimCore(result), true
+}
+
+// Result of a strict binary operator on two literals.
+func Delta(op PrimOp, a LExpr, b LExpr) (LExpr, error) {
+	a_int, a_is_int := a.(*LInt)
+	b_int, b_is_int := b.(*LInt)
+	a_str, a_is_str := a.(*LString)
+	b_str, b_is_str := b.(*LString)
+	switch {
+	case op == PRIM_Add && a_is_int && b_is_int:
+		sum := a_int.Value + b_int.Value
+		// Overflowed when both operands have the same sign and the sum has the other one
+		if (a_int.Value >= 0) == (b_int.Value >= 0) && (sum >= 0) != (a_int.Value >= 0) {
+			return nil, fmt.Errorf("%v overflows on %v and %v", op.ToString(), a.LPrint(), b.LPrint())
+		}
+		return &LInt{Value: sum}, nil
+	case op == PRIM_Sub && a_is_int && b_is_int:
+		difference := a_int.Value - b_int.Value
+		if (a_int.Value >= 0) != (b_int.Value >= 0) && (difference >= 0) != (a_int.Value >= 0) {
+			return nil, fmt.Errorf("%v overflows on %v and %v", op.ToString(), a.LPrint(), b.LPrint())
+		}
+		return &LInt{Value: difference}, nil
+	case op == PRIM_Less && a_is_int && b_is_int:
+		return &LBool{Value: a_int.Value < b_int.Value}, nil
+	case op == PRIM_Concat && a_is_str && b_is_str:
+		return &LString{Value: a_str.Value + b_str.Value}, nil
+	case op == PRIM_Equal:
+		if equal, comparable := literalsEqual(a, b); comparable {
+			return &LBool{Value: equal}, nil
+		}
+	}
+	return nil, fmt.Errorf("%v is not defined on %v and %v", op.ToString(), a.LPrint(), b.LPrint())
+}
+
+// Whether two literals are equal, comparable only when they are of the same kind.
+func literalsEqual(a LExpr, b LExpr) (bool, bool) {
+	switch a_lit := a.(type) {
+	case *LInt:
+		b_int, is_int := b.(*LInt)
+		return is_int && a_lit.Value == b_int.Value, is_int
+	case *LBool:
+		b_bool, is_bool := b.(*LBool)
+		return is_bool && a_lit.Value == b_bool.Value, is_bool
+	case *LString:
+		b_str, is_str := b.(*LString)
+		return is_str && a_lit.Value == b_str.Value, is_str
+	}
+	return false, false
+}
+
+// Base types of the literals
+var (
+	Int_Type    = &TBase{Name: "INT"}
+	Bool_Type   = &TBase{Name: "BOOL"}
+	String_Type = &TBase{Name: "STR"}
+)
+
+// Type of a literal or operator; ==, @IF and @FIX are polymorphic, taking a fresh variable from
+// fresh for the type of their arguments.
+func PrimType(l LExpr, fresh func() LType) LType {
+	arrows := func(ts ...LType) LType {
+		t := ts[len(ts)-1]
+		for i := len(ts) - 2; i >= 0; i-- {
+			t = &TArrow{From: ts[i], To: t}
+		}
+		return t
+	}
+	switch node := l.(type) {
+	case *LInt:
+		return Int_Type
+	case *LBool:
+		return Bool_Type
+	case *LString:
+		return String_Type
+	case *LPrim:
+		switch node.Op {
+		case PRIM_Add, PRIM_Sub:
+			return arrows(Int_Type, Int_Type, Int_Type)
+		case PRIM_Less:
+			return arrows(Int_Type, Int_Type, Bool_Type)
+		case PRIM_Concat:
+			return arrows(String_Type, String_Type, String_Type)
+		case PRIM_Equal:
+			a := fresh()
+			return arrows(a, a, Bool_Type)
+		case PRIM_If:
+			a := fresh()
+			return arrows(Bool_Type, a, a, a)
+		case PRIM_Fix:
+			a := fresh()
+			return arrows(arrows(a, a), a)
+		}
+	}
+	return nil
+}
+
+// Upper case everything outside of string literals, as the parser expects.
+func UpperOutsideStrings(s string) string {
+	if !strings.Contains(s, "\"") {
+		return strings.ToUpper(s)
+	}
+	var out strings.Builder
+	tracker := ParenTracker{}
+	for _, r := range s {
+		in_string := tracker.InString
+		tracker.Update(string(r))
+		if in_string || tracker.InString {
+			out.WriteRune(r)
+		} else {
+			out.WriteString(strings.ToUpper(string(r)))
+		}
+	}
+	return out.String()
+}
+
+// Programs shown by "lambda eval" when given no argument. Definitions named like variables
+// are available to later statements.
+const Prim_Examples_Source = `
++(40)(2)
+<(3)(4)
+==("lambda")("LAMBDA")
+++("hello, ")("world")
+@IF(<(1)(2))("yes")("no")
+TWICE1 = LX1.(+(X1)(X1))
+TWICE1(TWICE1(5))
+L@F1=(LN1.(@IF(<(N1)(1))(0)(+(N1)(F1(-(N1)(1)))))).(F1(10))
+@FIX(LF1.(LN1.(@IF(<(N1)(1))("")(++("*")(F1(-(N1)(1)))))))(3)
+`
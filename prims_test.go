@@ -0,0 +1,162 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func parseCore(t *testing.T, source string) Core {
+	t.Helper()
+	executor := TransitionExecutor_Init()
+	p, parse_err := executor.Parse(source)
+	if parse_err != nil {
+		t.Fatalf("%v: %v", source, parse_err)
+	}
+	c, lower_err := Lower(SingleLExpr(p.Exprs))
+	if lower_err != nil {
+		t.Fatalf("%v: %v", source, lower_err)
+	}
+	return c
+}
+
+func TestNegativeLiterals(t *testing.T) {
+	cases := []struct {
+		source string
+		want   string
+	}{
+		{"~5", "~5"},
+		{"+(1)(~5)", "(+1(~5))"},
+		{"-(3)(10)", "(-3(10))"},
+		{"\"a~b\"", "\"a~b\""},
+	}
+	executor := TransitionExecutor_Init()
+	for _, c := range cases {
+		p, parse_err := executor.Parse(c.source)
+		if parse_err != nil {
+			t.Errorf("%v: %v", c.source, parse_err)
+		} else if got := SingleLExpr(p.Exprs).LPrint(); got != c.want {
+			t.Errorf("%v: parsed %v, want %v", c.source, got, c.want)
+		}
+	}
+	// Printed negative results read back as the same literal
+	nf, _, reduce_err := Reduce(parseCore(t, "-(3)(10)"), R_NormalOrder, Test_Max_Steps)
+	if reduce_err != nil {
+		t.Fatal(reduce_err)
+	}
+	if printed := Raise(nf).LPrint(); printed != "~7" || parseCore(t, printed).CPrint() != nf.CPrint() {
+		t.Errorf("got %v, want ~7 reading back as itself", printed)
+	}
+}
+
+func TestNegativeLiteralErrors(t *testing.T) {
+	executor := TransitionExecutor_Init()
+	for source, want := range map[string]string{
+		"~":         "Expected digits after the ~",
+		"+(1)(5~3)": "must be parenthesized",
+	} {
+		if _, parse_err := executor.Parse(source); parse_err == nil || !strings.Contains(parse_err.Error(), want) {
+			t.Errorf("%v: got %v, want an error mentioning %q", source, parse_err, want)
+		}
+	}
+}
+
+func TestDeltaEquality(t *testing.T) {
+	cases := []struct {
+		a, b LExpr
+		want string
+	}{
+		{&LInt{Value: 2}, &LInt{Value: 2}, "@TRUE"},
+		{&LInt{Value: 2}, &LInt{Value: -2}, "@FALSE"},
+		{&LBool{Value: true}, &LBool{Value: true}, "@TRUE"},
+		{&LString{Value: "a"}, &LString{Value: "b"}, "@FALSE"},
+		{&LInt{Value: 1}, &LString{Value: "1"}, ""},
+		{&LBool{Value: false}, &LInt{Value: 0}, ""},
+	}
+	for _, c := range cases {
+		result, delta_err := Delta(PRIM_Equal, c.a, c.b)
+		if c.want == "" {
+			if delta_err == nil {
+				t.Errorf("==(%v)(%v) gave %v, want an error", c.a.LPrint(), c.b.LPrint(), result.LPrint())
+			}
+		} else if delta_err != nil || result.LPrint() != c.want {
+			t.Errorf("==(%v)(%v) gave %v, %v, want %v", c.a.LPrint(), c.b.LPrint(), result, delta_err, c.want)
+		}
+	}
+}
+
+// Terms with primitives, paired with their normal form, for the evaluators with delta rules.
+func deltaCases() []struct{ Source, Want string } {
+	return []struct{ Source, Want string }{
+		{"+(40)(2)", "42"},
+		{"==(~3)(-(0)(3))", "@TRUE"},
+		{"++(\"a\")(\"b\")", "\"ab\""},
+		{"@FIX(LF1.(LN1.(@IF(<(N1)(1))(0)(+(N1)(F1(-(N1)(1)))))))(10)", "55"},
+		// @IF only forces the branch it takes
+		{"@IF(@TRUE)(1)((LX1.(X1(X1)))(LX1.(X1(X1))))", "1"},
+		// Stuck on arguments it is not defined on, or on a bound variable
+		{"+(1)(@TRUE)", "(+1@TRUE)"},
+		{"LX1.(+(X1)((LY1.(Y1))(1)))", "λX1.(+X1(1))"},
+	}
+}
+
+func TestDeltaCasesUnderNormalOrder(t *testing.T) {
+	for _, c := range deltaCases() {
+		if got := Raise(normalOrder(t, parseCore(t, c.Source))).LPrint(); got != c.Want {
+			t.Errorf("%v: got %v, want %v", c.Source, got, c.Want)
+		}
+	}
+}
+
+// A variable or universe right before a negative literal is kept, as it is before a
+// parenthesized one.
+func TestNegativeLiteralAfterAnExpression(t *testing.T) {
+	cases := []struct {
+		source string
+		want   []string
+	}{
+		{"X1~5", []string{"X1", "~5"}},
+		{"*~1", []string{"*", "~1"}},
+		{"*1~1", []string{"*1", "~1"}},
+	}
+	executor := TransitionExecutor_Init()
+	for _, c := range cases {
+		p, parse_err := executor.Parse(c.source)
+		if parse_err != nil {
+			t.Errorf("%v: %v", c.source, parse_err)
+			continue
+		}
+		got := []string{}
+		for _, expr := range p.Exprs {
+			got = append(got, expr.LPrint())
+		}
+		if strings.Join(got, " ") != strings.Join(c.want, " ") {
+			t.Errorf("%v: parsed %v, want %v", c.source, got, c.want)
+		}
+	}
+	nf, _, reduce_err := Reduce(parseCore(t, "+(X1~5)(1)"), R_NormalOrder, Test_Max_Steps)
+	if reduce_err != nil {
+		t.Fatal(reduce_err)
+	}
+	if got := Raise(nf).LPrint(); got != "(+(X1(~5))1)" {
+		t.Errorf("got %v, want the application to X1 left stuck", got)
+	}
+}
+
+func TestDeltaOverflow(t *testing.T) {
+	cases := []struct {
+		source string
+		want   string
+	}{
+		{"+(9223372036854775807)(1)", "(+9223372036854775807(1))"},
+		{"-(~9223372036854775808)(1)", "(-~9223372036854775808(1))"},
+		{"+(~9223372036854775808)(~1)", "(+~9223372036854775808(~1))"},
+		{"-(9223372036854775807)(~1)", "(-9223372036854775807(~1))"},
+		{"+(9223372036854775806)(1)", "9223372036854775807"},
+		{"-(~9223372036854775807)(1)", "~9223372036854775808"},
+	}
+	for _, c := range cases {
+		if got := Raise(normalOrder(t, parseCore(t, c.source))).LPrint(); got != c.want {
+			t.Errorf("%v: got %v, want %v", c.source, got, c.want)
+		}
+	}
+}
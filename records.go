@@ -0,0 +1,757 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+/*
+	Records and variants - an optional extension with row-typed inference
+
+Parsers with Records set (Decoder.AcceptRecords, "+records" on the command line) read three
+more kinds of term, all named by labels of capital letters:
+
+	{HOST=("localhost"),PORT=(8080)}     a record literal, every field term parenthesized
+	R1.PORT                              projection of a field, R1.SERVER.PORT selecting twice
+	'OK(5)                               a tag applied to its payload, a polymorphic variant
+
+Variants are taken apart by @CASE as data types are, one branch per tag binding its payload:
+
+	@CASE(R1)'OK.V1.(V1)'ERR.E1.(0)
+
+Unlike constructors, tags and labels need no declaration. Lowering turns records into the
+constant "{HOST,PORT}" applied to the fields in label order, projections into ".PORT" applied
+to the record and tags into "'OK", while a case on tags is a case constant with arms 'OK/1 and
+'ERR/1. EncodeRecords compiles those into the pure calculus plus string literals, which every
+evaluator already runs:
+
+  - a record is a function from labels to fields, λL1.(@IF(==L1"HOST")(H)(@IF(==L1"PORT")(P)
+    NOFIELD)), and a projection applies the record to its label
+  - 'OK(V) is λH1.(H1"OK"V), handing its label and payload to whoever matches it, and a case
+    passes in a handler comparing that label with each of its tags in turn
+
+DecodeRecords reads such values back for display. Like ScottDecode it goes by shape only, so
+with the extension on a plain λH1.(H1"OK"(X1)) shows as 'OK(X1).
+
+Inference types records as {HOST: STR, PORT: INT} and variants as [OK: INT, ERR: STR], each
+made of a row: fields in front of either the empty row (a closed row) or a type variable
+standing for fields not known yet. Projection of PORT only asks for {PORT: a | r}, so
+λR1.(R1.PORT) takes any record with a PORT field, and 'OK(5) is [OK: INT | r], fitting any
+variant with an OK tag; a case on tags closes the row to exactly the tags it has branches for.
+Rows are unified label by label (Rémy): a label missing from one row is added to it when its
+tail is a variable, and is an error of kind TY_MissingLabel when the row is closed.
+*/
+type LRecord struct {
+	Labels []string
+	Fields []LExpr
+}
+
+// LProj selects the field Label of Record, R1.PORT.
+type LProj struct {
+	Record LExpr
+	Label  string
+}
+
+// LTag is a tag, 'OK, applied to its payload like a constructor.
+type LTag struct {
+	Label string
+}
+
+// Prefixes of the Core constants for tags and projections
+const (
+	Tag_Prefix    = "'"
+	Select_Prefix = "."
+)
+
+// Constants a projection of a missing field and a case without a branch for its tag are stuck
+// on; no variable can be named so
+var (
+	No_Field = &CFree{Name: "NOFIELD"}
+	No_Match = &CFree{Name: "NOMATCH"}
+)
+
+func (l *LRecord) LPrint() string {
+	fields := []string{}
+	for i, label := range l.Labels {
+		fields = append(fields, label+"="+parenthesized(l.Fields[i]))
+	}
+	return "{" + strings.Join(fields, ",") + "}"
+}
+
+func (l *LProj) LPrint() string {
+	switch node := l.Record.(type) {
+	case *LVar, *LRecord, *LProj:
+		return node.LPrint() + Select_Prefix + l.Label
+	}
+	return parenthesized(l.Record) + Select_Prefix + l.Label
+}
+
+func (l *LTag) LPrint() string {
+	return Tag_Prefix + l.Label
+}
+
+func (l *LRecord) LAbstract(b LVar) LExpr {
+	return primAbstract(l, b)
+}
+
+func (l *LProj) LAbstract(b LVar) LExpr {
+	return primAbstract(l, b)
+}
+
+func (l *LTag) LAbstract(b LVar) LExpr {
+	return primAbstract(l, b)
+}
+
+func (l *LRecord) Copy() LExpr {
+	fields := make([]LExpr, len(l.Fields))
+	for i, field := range l.Fields {
+		fields[i] = field.Copy()
+	}
+	return &LRecord{Labels: append([]string{}, l.Labels...), Fields: fields}
+}
+
+func (l *LProj) Copy() LExpr {
+	return &LProj{Record: l.Record.Copy(), Label: l.Label}
+}
+
+func (l *LTag) Copy() LExpr {
+	return &LTag{Label: l.Label}
+}
+
+func (l *LRecord) LApply(b LVar, replace LExpr) LExpr {
+	fields := make([]LExpr, len(l.Fields))
+	for i, field := range l.Fields {
+		fields[i] = field.LApply(b, replace)
+	}
+	return &LRecord{Labels: append([]string{}, l.Labels...), Fields: fields}
+}
+
+func (l *LProj) LApply(b LVar, replace LExpr) LExpr {
+	return &LProj{Record: l.Record.LApply(b, replace), Label: l.Label}
+}
+
+func (l *LTag) LApply(b LVar, replace LExpr) LExpr {
+	return l.Copy()
+}
+
+func (l *LRecord) LEquals(l2 LExpr) bool {
+	return l.LPrint() == l2.LPrint()
+}
+
+func (l *LProj) LEquals(l2 LExpr) bool {
+	return l.LPrint() == l2.LPrint()
+}
+
+func (l *LTag) LEquals(l2 LExpr) bool {
+	return l.LPrint() == l2.LPrint()
+}
+
+// How a branch matching con is written: @CONS for a constructor, 'OK for a tag.
+func BranchHead(con string) string {
+	if strings.HasPrefix(con, Tag_Prefix) {
+		return con
+	}
+	return Prim_Prefix + con
+}
+
+// Name of the Core constant for a record with labels, "{HOST,PORT}".
+func RecordName(labels []string) string {
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+// Labels of a record constant named by RecordName.
+func ParseRecordName(name string) ([]string, bool) {
+	inner, found := strings.CutPrefix(name, "{")
+	inner, closed := strings.CutSuffix(inner, "}")
+	if !found || !closed {
+		return nil, false
+	} else if len(inner) == 0 {
+		return []string{}, true
+	}
+	labels := strings.Split(inner, ",")
+	for _, label := range labels {
+		if !IsPrimNameChar(label) {
+			return nil, false
+		}
+	}
+	return labels, true
+}
+
+// The record constant applied to the fields of l, sorted by label.
+func lowerRecord(l *LRecord, scope []string) (Core, error) {
+	order := make([]int, len(l.Labels))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return l.Labels[order[i]] < l.Labels[order[j]] })
+	labels := []string{}
+	fields := []Core{}
+	for _, i := range order {
+		if len(labels) > 0 && labels[len(labels)-1] == l.Labels[i] {
+			return nil, fmt.Errorf("Field %v appears twice in %v", l.Labels[i], l.LPrint())
+		}
+		field, field_err := lower(l.Fields[i], scope)
+		if field_err != nil {
+			return nil, field_err
+		}
+		labels = append(labels, l.Labels[i])
+		fields = append(fields, field)
+	}
+	return CoreApply(&CFree{Name: RecordName(labels)}, fields...), nil
+}
+
+// Whether arms match tags rather than constructors. They may not mix the two, and every tag
+// binds its payload alone.
+func variantArms(arms []CaseArm) (bool, error) {
+	tags := []string{}
+	for _, arm := range arms {
+		if !strings.HasPrefix(arm.Con, Tag_Prefix) {
+			continue
+		} else if slices.Contains(tags, arm.Con) {
+			return true, fmt.Errorf("@CASE has two branches for %v", arm.Con)
+		} else if arm.Arity != 1 {
+			return true, fmt.Errorf("Branch %v binds %v variables, a tag carries exactly one value", arm.Con, arm.Arity)
+		}
+		tags = append(tags, arm.Con)
+	}
+	if len(tags) > 0 && len(tags) < len(arms) {
+		return true, fmt.Errorf("@CASE mixes tags and constructors in its branches")
+	}
+	return len(tags) > 0, nil
+}
+
+// EncodeRecords replaces the records, projections, tags and cases on tags of c by their
+// encodings in the pure calculus.
+func EncodeRecords(c Core) (Core, error) {
+	switch node := c.(type) {
+	case *CLam:
+		body, body_err := EncodeRecords(node.Body)
+		if body_err != nil {
+			return nil, body_err
+		}
+		return &CLam{Name: node.Name, Body: body}, nil
+	case *CVar:
+		return c, nil
+	}
+	head, args := CoreSpine(c)
+	for i, arg := range args {
+		encoded, arg_err := EncodeRecords(arg)
+		if arg_err != nil {
+			return nil, arg_err
+		}
+		args[i] = encoded
+	}
+	free, is_free := head.(*CFree)
+	if !is_free {
+		fun, fun_err := EncodeRecords(head)
+		if fun_err != nil {
+			return nil, fun_err
+		}
+		return CoreApply(fun, args...), nil
+	}
+	if arms, is_case := ParseCaseName(free.Name); is_case {
+		is_variant, arms_err := variantArms(arms)
+		if arms_err != nil {
+			return nil, arms_err
+		} else if is_variant {
+			return CoreApply(variantCase(arms), args...), nil
+		}
+	} else if labels, is_record := ParseRecordName(free.Name); is_record {
+		return CoreApply(recordConstructor(labels), args...), nil
+	} else if label, is_select := strings.CutPrefix(free.Name, Select_Prefix); is_select {
+		select_field := &CLam{Name: "R1", Body: &CApp{Fun: &CVar{Index: 0, Name: "R1"}, Arg: labelCore(label)}}
+		return CoreApply(select_field, args...), nil
+	} else if label, is_tag := strings.CutPrefix(free.Name, Tag_Prefix); is_tag {
+		// λP1.λH1.(H1 "OK" P1)
+		tag := &CLam{Name: "P1", Body: &CLam{Name: "H1", Body: CoreApply(&CVar{Index: 0, Name: "H1"},
+			labelCore(label), &CVar{Index: 1, Name: "P1"})}}
+		return CoreApply(tag, args...), nil
+	}
+	return CoreApply(head, args...), nil
+}
+
+// String literal standing for a label at run time.
+func labelCore(label string) Core {
+	return PrimCore(&LString{Value: label})
+}
+
+// @IF(==(X)(label))(then)(otherwise), X being the label under test at index.
+func labelTest(index int, name string, label string, then Core, otherwise Core) Core {
+	test := CoreApply(PrimCore(&LPrim{Op: PRIM_Equal}), &CVar{Index: index, Name: name}, labelCore(label))
+	return CoreApply(PrimCore(&LPrim{Op: PRIM_If}), test, then, otherwise)
+}
+
+// λF1...λFn.λL1.(@IF(==L1"A1")(F1)(... NOFIELD)) for a record with labels A1 ... An.
+func recordConstructor(labels []string) Core {
+	n := len(labels)
+	var out Core = No_Field
+	for i := n - 1; i >= 0; i-- {
+		out = labelTest(0, "L1", labels[i], &CVar{Index: n - i, Name: "F1"}, out)
+	}
+	out = &CLam{Name: "L1", Body: out}
+	for range n {
+		out = &CLam{Name: "F1", Body: out}
+	}
+	return out
+}
+
+// λS1.λK1...λKn.(S1 λT1.λX1.(@IF(==T1"A1")(K1 X1)(... NOMATCH))) for a case on tags A1 ... An.
+func variantCase(arms []CaseArm) Core {
+	n := len(arms)
+	var out Core = No_Match
+	for i := n - 1; i >= 0; i-- {
+		handler := &CApp{Fun: &CVar{Index: 1 + n - i, Name: "K1"}, Arg: &CVar{Index: 0, Name: "X1"}}
+		out = labelTest(1, "T1", strings.TrimPrefix(arms[i].Con, Tag_Prefix), handler, out)
+	}
+	out = &CApp{Fun: &CVar{Index: n, Name: "S1"}, Arg: &CLam{Name: "T1", Body: &CLam{Name: "X1", Body: out}}}
+	for range n {
+		out = &CLam{Name: "K1", Body: out}
+	}
+	return &CLam{Name: "S1", Body: out}
+}
+
+// DecodeRecords turns the encodings of records and tagged values within c back into record
+// and tag constants.
+func DecodeRecords(c Core) Core {
+	switch node := c.(type) {
+	case *CLam:
+		if labels, fields, is_record := decodeRecordBody(node.Body); is_record {
+			for i, field := range fields {
+				fields[i] = DecodeRecords(Shift(field, -1, 0))
+			}
+			return CoreApply(&CFree{Name: RecordName(labels)}, fields...)
+		}
+		head, args := CoreSpine(node.Body)
+		if handler, is_var := head.(*CVar); is_var && handler.Index == 0 && len(args) == 2 && !refersBelow(args[1], 1, 0) {
+			if label, is_label := DecodeLiteral(args[0]).(*LString); is_label && IsPrimNameChar(label.Value) {
+				return &CApp{Fun: &CFree{Name: Tag_Prefix + label.Value}, Arg: DecodeRecords(Shift(args[1], -1, 0))}
+			}
+		}
+		return &CLam{Name: node.Name, Body: DecodeRecords(node.Body)}
+	case *CApp:
+		return &CApp{Fun: DecodeRecords(node.Fun), Arg: DecodeRecords(node.Arg)}
+	}
+	return c
+}
+
+// Labels and fields of the body of an encoded record, whose label is bound at index 0.
+func decodeRecordBody(body Core) ([]string, []Core, bool) {
+	labels := []string{}
+	fields := []Core{}
+	for {
+		if free, is_free := body.(*CFree); is_free && free.Name == No_Field.Name {
+			return labels, fields, true
+		}
+		head, args := CoreSpine(body)
+		if op, is_op := machineOperator(head); !is_op || op != PRIM_If || len(args) != 3 {
+			return nil, nil, false
+		}
+		test_head, test_args := CoreSpine(args[0])
+		if op, is_op := machineOperator(test_head); !is_op || op != PRIM_Equal || len(test_args) != 2 {
+			return nil, nil, false
+		}
+		tested, is_var := test_args[0].(*CVar)
+		label, is_label := DecodeLiteral(test_args[1]).(*LString)
+		if !is_var || tested.Index != 0 || !is_label || !IsPrimNameChar(label.Value) || refersBelow(args[1], 1, 0) {
+			return nil, nil, false
+		}
+		labels = append(labels, label.Value)
+		fields = append(fields, args[1])
+		body = args[2]
+	}
+}
+
+// Read a record or projection constant applied to args back, false if head is neither.
+func raiseRecordSpine(head *CFree, args []Core, scope []string, used map[string]bool) ([]LExpr, bool) {
+	var out LExpr = nil
+	rest := args
+	if labels, is_record := ParseRecordName(head.Name); is_record && len(args) >= len(labels) {
+		fields := []LExpr{}
+		for _, arg := range args[:len(labels)] {
+			fields = append(fields, raise(arg, scope, used))
+		}
+		out = &LRecord{Labels: labels, Fields: fields}
+		rest = args[len(labels):]
+	} else if label, is_select := strings.CutPrefix(head.Name, Select_Prefix); is_select && len(args) > 0 {
+		out = &LProj{Record: raise(args[0], scope, used), Label: label}
+		rest = args[1:]
+	} else {
+		return nil, false
+	}
+	lexprs := []LExpr{out}
+	for _, arg := range rest {
+		lexprs = append(lexprs, raise(arg, scope, used))
+	}
+	return lexprs, true
+}
+
+// Read a tag or empty record constant back, nil if c is neither.
+func raiseRecordAtom(c *CFree) LExpr {
+	if label, is_tag := strings.CutPrefix(c.Name, Tag_Prefix); is_tag && IsPrimNameChar(label) {
+		return &LTag{Label: label}
+	} else if c.Name == RecordName(nil) {
+		return &LRecord{Labels: []string{}, Fields: []LExpr{}}
+	}
+	return nil
+}
+
+// {HOST: STR, PORT: INT | r}, a record with the fields of Row.
+type TRecord struct {
+	Row LType
+}
+
+// [OK: INT, ERR: STR | r], a variant with the tags of Row.
+type TVariant struct {
+	Row LType
+}
+
+// Row with the label Label of type Field in front of the row Rest: another TRowExtend, the
+// empty row or a type variable.
+type TRowExtend struct {
+	Label string
+	Field LType
+	Rest  LType
+}
+
+type TRowEmpty struct{}
+
+func (t *TRecord) TPrint() string {
+	return "{" + rowPrint(t.Row, func(t LType) string { return t.TPrint() }, ":", ",", "|") + "}"
+}
+
+func (t *TVariant) TPrint() string {
+	return "[" + rowPrint(t.Row, func(t LType) string { return t.TPrint() }, ":", ",", "|") + "]"
+}
+
+func (t *TRowExtend) TPrint() string {
+	return "(" + rowPrint(t, func(t LType) string { return t.TPrint() }, ":", ",", "|") + ")"
+}
+
+func (t *TRowEmpty) TPrint() string {
+	return "()"
+}
+
+func (t *TRecord) TEquals(t2 LType) bool {
+	record, is_record := t2.(*TRecord)
+	return is_record && rowEquals(t.Row, record.Row)
+}
+
+func (t *TVariant) TEquals(t2 LType) bool {
+	variant, is_variant := t2.(*TVariant)
+	return is_variant && rowEquals(t.Row, variant.Row)
+}
+
+func (t *TRowExtend) TEquals(t2 LType) bool {
+	return rowEquals(t, t2)
+}
+
+func (t *TRowEmpty) TEquals(t2 LType) bool {
+	_, is_empty := t2.(*TRowEmpty)
+	return is_empty
+}
+
+// Fields of row sorted by label, and the type ending it.
+func rowFields(row LType) ([]*TRowExtend, LType) {
+	fields := []*TRowExtend{}
+	for {
+		extend, is_extend := row.(*TRowExtend)
+		if !is_extend {
+			break
+		}
+		fields = append(fields, extend)
+		row = extend.Rest
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Label < fields[j].Label })
+	return fields, row
+}
+
+// Rows are equal whatever the order of their labels.
+func rowEquals(a LType, b LType) bool {
+	a_fields, a_tail := rowFields(a)
+	b_fields, b_tail := rowFields(b)
+	if len(a_fields) != len(b_fields) || !a_tail.TEquals(b_tail) {
+		return false
+	}
+	for i := range a_fields {
+		if a_fields[i].Label != b_fields[i].Label || !a_fields[i].Field.TEquals(b_fields[i].Field) {
+			return false
+		}
+	}
+	return true
+}
+
+// "A: a, B: b | r" for a row, printing types with print.
+func rowPrint(row LType, print func(LType) string, colon string, comma string, bar string) string {
+	fields, tail := rowFields(row)
+	parts := []string{}
+	for _, field := range fields {
+		parts = append(parts, field.Label+colon+print(field.Field))
+	}
+	out := strings.Join(parts, comma)
+	if _, is_empty := tail.(*TRowEmpty); !is_empty {
+		if len(parts) > 0 {
+			out += bar
+		}
+		out += print(tail)
+	}
+	return out
+}
+
+// The types directly inside a record, variant or row type t.
+func rowTypeParts(t LType) []LType {
+	switch node := t.(type) {
+	case *TRecord:
+		return []LType{node.Row}
+	case *TVariant:
+		return []LType{node.Row}
+	case *TRowExtend:
+		return []LType{node.Field, node.Rest}
+	}
+	return []LType{}
+}
+
+// t rebuilt with f applied to its parts, false if t is no record, variant or row type.
+func rowTypeMap(t LType, f func(LType) LType) (LType, bool) {
+	switch node := t.(type) {
+	case *TRecord:
+		return &TRecord{Row: f(node.Row)}, true
+	case *TVariant:
+		return &TVariant{Row: f(node.Row)}, true
+	case *TRowExtend:
+		return &TRowExtend{Label: node.Label, Field: f(node.Field), Rest: f(node.Rest)}, true
+	}
+	return t, false
+}
+
+// Unify records with records, variants with variants and rows with rows, false if a and b
+// are no such pair.
+func (inf *Inferencer) unifyRowTypes(a LType, b LType) (*unifyError, bool) {
+	var row_err *unifyError = nil
+	switch a_node := a.(type) {
+	case *TRecord:
+		b_node, is_record := b.(*TRecord)
+		if !is_record {
+			return nil, false
+		}
+		row_err = inf.unifyRows(a_node.Row, b_node.Row)
+	case *TVariant:
+		b_node, is_variant := b.(*TVariant)
+		if !is_variant {
+			return nil, false
+		}
+		row_err = inf.unifyRows(a_node.Row, b_node.Row)
+	case *TRowExtend, *TRowEmpty:
+		// Pairs of rows, unless both are empty
+		_, a_empty := a.(*TRowEmpty)
+		_, b_extend := b.(*TRowExtend)
+		if _, b_empty := b.(*TRowEmpty); !b_extend && (a_empty || !b_empty) {
+			return nil, false
+		}
+		return inf.unifyRows(a, b), true
+	default:
+		return nil, false
+	}
+	if row_err != nil && row_err.Kind == TY_MissingLabel && row_err.Left == nil {
+		// Name the whole types, the one that has the label first
+		row_err.Left, row_err.Right = inf.Resolve(a), inf.Resolve(b)
+		if fields, _ := rowFields(rowTypeParts(row_err.Left)[0]); !slices.ContainsFunc(fields,
+			func(field *TRowExtend) bool { return field.Label == row_err.Label }) {
+			row_err.Left, row_err.Right = row_err.Right, row_err.Left
+		}
+	}
+	return row_err, true
+}
+
+func (inf *Inferencer) unifyRows(a LType, b LType) *unifyError {
+	a, b = inf.prune(a), inf.prune(b)
+	if _, is_empty := a.(*TRowEmpty); is_empty {
+		if _, is_extend := b.(*TRowExtend); is_extend {
+			return inf.unifyRows(b, a)
+		}
+	}
+	extend, is_extend := a.(*TRowExtend)
+	if !is_extend {
+		return inf.unify(a, b)
+	}
+	tail := inf.rowTail(extend.Rest)
+	field, rest, rewrite_err := inf.rewriteRow(b, extend.Label)
+	if rewrite_err != nil {
+		return rewrite_err
+	}
+	if tail != nil {
+		if _, bound := inf.subst[tail.ID]; bound {
+			// b ends in the same variable as a, which would have to contain the label itself
+			return &unifyError{Kind: TY_Occurs, Left: tail, Right: inf.Resolve(b)}
+		}
+	}
+	if field_err := inf.unify(extend.Field, field); field_err != nil {
+		return field_err
+	}
+	return inf.unify(extend.Rest, rest)
+}
+
+// The variable ending row, nil if it is closed.
+func (inf *Inferencer) rowTail(row LType) *TVar {
+	for {
+		switch node := inf.prune(row).(type) {
+		case *TRowExtend:
+			row = node.Rest
+		case *TVar:
+			return node
+		default:
+			return nil
+		}
+	}
+}
+
+// The field labelled label of row, and row without it. A row ending in a variable gets the
+// label by binding the variable to a row with it.
+func (inf *Inferencer) rewriteRow(row LType, label string) (LType, LType, *unifyError) {
+	switch node := inf.prune(row).(type) {
+	case *TRowExtend:
+		if node.Label == label {
+			return node.Field, node.Rest, nil
+		}
+		field, rest, rewrite_err := inf.rewriteRow(node.Rest, label)
+		if rewrite_err != nil {
+			return nil, nil, rewrite_err
+		}
+		return field, &TRowExtend{Label: node.Label, Field: node.Field, Rest: rest}, nil
+	case *TVar:
+		field, rest := inf.fresh(), inf.fresh()
+		inf.subst[node.ID] = &TRowExtend{Label: label, Field: field, Rest: rest}
+		inf.origin[node.ID] = inf.solving
+		return field, rest, nil
+	}
+	return nil, nil, &unifyError{Kind: TY_MissingLabel, Label: label}
+}
+
+// {A1: T1, ..., An: Tn}, closed.
+func (inf *Inferencer) inferRecord(node *LRecord, scope []inferBinding, span Span) (LType, error) {
+	var row LType = &TRowEmpty{}
+	for i := len(node.Labels) - 1; i >= 0; i-- {
+		field, field_err := inf.infer(node.Fields[i], scope, span)
+		if field_err != nil {
+			return nil, field_err
+		}
+		row = &TRowExtend{Label: node.Labels[i], Field: field, Rest: row}
+	}
+	return &TRecord{Row: row}, nil
+}
+
+// Any record with the label: {A: a | r} → a.
+func (inf *Inferencer) inferProj(node *LProj, scope []inferBinding, span Span) (LType, error) {
+	record, record_err := inf.infer(node.Record, scope, span)
+	if record_err != nil {
+		return nil, record_err
+	}
+	field := inf.fresh()
+	given := inf.Resolve(record)
+	record_text := node.Record.LPrint()
+	index, unify_err := inf.constrain(record, &TRecord{Row: &TRowExtend{Label: node.Label, Field: field, Rest: inf.fresh()}},
+		node.LPrint(), span, fmt.Sprintf("%v selects field %v of %v", node.LPrint(), node.Label, record_text))
+	if unify_err != nil {
+		return nil, inf.failureAt(unify_err, index, func(pretty func(LType) string) (string, string) {
+			return fmt.Sprintf("%v has type %v", record_text, pretty(given)),
+				fmt.Sprintf("either the label %v or the record %v is likely wrong", node.Label, record_text)
+		})
+	}
+	return field, nil
+}
+
+// A tag fits any variant with it: a → [A: a | r].
+func (inf *Inferencer) inferTag(node *LTag) LType {
+	payload := inf.fresh()
+	return &TArrow{From: payload, To: &TVariant{Row: &TRowExtend{Label: node.Label, Field: payload, Rest: inf.fresh()}}}
+}
+
+// Type of @CASE applied to a scrutinee and its branches on tags, exprs[0] being the @CASE,
+// and how many of exprs that takes. The scrutinee must have exactly the tags of the branches.
+func (inf *Inferencer) inferMatch(exprs []LExpr, scope []inferBinding, span Span) (LType, int, error) {
+	case_text := SeqPrint(exprs)
+	if len(exprs) < 3 {
+		return nil, 0, &TypeError{Kind: TY_Malformed, Span: span, Term: case_text,
+			Message: "@CASE needs a scrutinee and branches, as in @CASE(E)'A.X1.(B)"}
+	}
+	scrutinee, scrutinee_err := inf.infer(exprs[1], scope, span)
+	if scrutinee_err != nil {
+		return nil, 0, scrutinee_err
+	}
+	branches := []*LBranch{}
+	for _, expr := range exprs[2:] {
+		branch, is_branch := asBranch(expr)
+		if !is_branch {
+			break
+		}
+		branches = append(branches, branch)
+	}
+	consumed := 2 + len(branches)
+	case_text = SeqPrint(exprs[:consumed])
+	case_span := Span{Offset: inf.span(exprs[0], span).Offset, End: inf.span(exprs[consumed-1], span).End}
+	arms := []CaseArm{}
+	for _, branch := range branches {
+		arms = append(arms, CaseArm{Con: branch.Con, Arity: len(branch.Fields)})
+	}
+	if is_variant, arms_err := variantArms(arms); arms_err != nil || !is_variant || len(arms) == 0 {
+		message := "only a @CASE on tags can be inferred, data types are not supported"
+		if arms_err != nil {
+			message = arms_err.Error()
+		}
+		return nil, 0, &TypeError{Kind: TY_Malformed, Span: case_span, Term: case_text, Message: message}
+	}
+	var row LType = &TRowEmpty{}
+	payloads := make([]LType, len(branches))
+	for i := len(branches) - 1; i >= 0; i-- {
+		payloads[i] = inf.fresh()
+		row = &TRowExtend{Label: strings.TrimPrefix(branches[i].Con, Tag_Prefix), Field: payloads[i], Rest: row}
+	}
+	given := inf.Resolve(scrutinee)
+	scrutinee_text := exprs[1].LPrint()
+	index, unify_err := inf.constrain(scrutinee, &TVariant{Row: row}, case_text, case_span,
+		fmt.Sprintf("%v is matched against the tags of the branches", scrutinee_text))
+	if unify_err != nil {
+		return nil, 0, inf.failureAt(unify_err, index, func(pretty func(LType) string) (string, string) {
+			return fmt.Sprintf("%v has type %v", scrutinee_text, pretty(given)),
+				fmt.Sprintf("a branch is likely missing for a tag %v may carry, or %v is not a variant", scrutinee_text, scrutinee_text)
+		})
+	}
+	var result LType = nil
+	for i, branch := range branches {
+		inner_scope := append(scope[:len(scope):len(scope)], inferBinding{Name: branch.Fields[0], Scheme: MonoScheme(payloads[i])})
+		body, body_err := inf.infer(branch.Body, inner_scope, inf.span(branch, span))
+		if body_err != nil {
+			return nil, 0, body_err
+		}
+		if result == nil {
+			result = body
+			continue
+		}
+		expected := inf.Resolve(result)
+		index, unify_err := inf.constrain(result, body, branch.LPrint(), inf.span(branch, span),
+			fmt.Sprintf("branch %v returns what the branches before it do", BranchHead(branch.Con)))
+		if unify_err != nil {
+			return nil, 0, inf.failureAt(unify_err, index, func(pretty func(LType) string) (string, string) {
+				return fmt.Sprintf("the branches before %v return %v", BranchHead(branch.Con), pretty(expected)),
+					fmt.Sprintf("the body of branch %v is likely wrong", BranchHead(branch.Con))
+			})
+		}
+	}
+	return result, consumed, nil
+}
+
+// Programs shown by "lambda eval +records" after the data examples: configurations as records
+// and results as variants.
+const Records_Examples_Source = `
+CONFIG1 = {HOST=("localhost"),PORT=(8080),DEBUG=(@FALSE)}
+CONFIG1.PORT
+WITHPORT1 = λP1.(λC1.({HOST=(C1.HOST),PORT=(P1),DEBUG=(C1.DEBUG)}))
+WITHPORT1(9090)(CONFIG1)
+ADDRESS1 = λC1.(++(++("http://")(C1.HOST))("/"))
+ADDRESS1(CONFIG1)
+CHECK1 = λC1.(@IF(<(C1.PORT)(1024))('ERR("privileged port"))('OK(C1)))
+SHOW1 = λR1.(@CASE(R1)'OK.C1.(ADDRESS1(C1))'ERR.E1.(E1))
+SHOW1(CHECK1(CONFIG1))
+SHOW1(CHECK1(WITHPORT1(80)(CONFIG1)))
+`
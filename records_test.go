@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func parseRecords(t *testing.T, source string) LExpr {
+	t.Helper()
+	executor := TransitionExecutor_Init()
+	p := Parser_Init()
+	p.Records = true
+	p, parse_err := executor.Run(p, source)
+	if parse_err != nil {
+		t.Fatalf("%v: %v", source, parse_err)
+	}
+	return SingleLExpr(p.Exprs)
+}
+
+func TestInferRecords(t *testing.T) {
+	cases := []struct {
+		source string
+		want   string
+	}{
+		{"{HOST=(\"localhost\"),PORT=(8080)}", "{HOST: STR, PORT: INT}"},
+		{"{HOST=(\"localhost\"),PORT=(8080)}.PORT", "INT"},
+		{"LR1.(+(R1.A)(R1.B))", "{A: INT, B: INT | a} → INT"},
+		{"(LR1.(R1.A))({B=(@TRUE),A=(1)})", "INT"},
+		{"'OK(5)", "[OK: INT | a]"},
+		{"LR1.(@CASE(R1)'OK.V1.(V1)'ERR.E1.(E1))", "[ERR: a, OK: a] → a"},
+		{"@CASE('OK(5))'OK.V1.(V1)'ERR.E1.(0)", "INT"},
+	}
+	for _, c := range cases {
+		scheme, infer_err := InferType(parseRecords(t, c.source), nil, nil)
+		if infer_err != nil {
+			t.Errorf("%v: %v", c.source, infer_err)
+		} else if got := PrettyType(scheme.Type); got != c.want {
+			t.Errorf("%v: got %v, want %v", c.source, got, c.want)
+		}
+	}
+}
+
+// The type with the label comes first in a TY_MissingLabel error, whichever side of the
+// constraint it was on.
+func TestInferMissingLabels(t *testing.T) {
+	cases := []struct {
+		source string
+		want   string
+	}{
+		{"{A=(1),B=(2)}.C", "{A: INT, B: INT} lacks label C of {A: INT, B: INT, C: a | b}"},
+		{"(LR1.(R1.C))({A=(1)})", "{A: INT} lacks label C of {C: a | b}"},
+		// inferMatch closes the row to the tags of the branches
+		{"@CASE('OK(1))'ERR.E1.(E1)", "[ERR: b] lacks label OK of [OK: INT | a]"},
+		{"LR1.(@CASE(R1)'OK.V1.(V1))('ERR(1))", "[OK: a] lacks label ERR of [ERR: INT, OK: a | b]"},
+	}
+	for _, c := range cases {
+		_, infer_err := InferType(parseRecords(t, c.source), nil, nil)
+		var type_err *TypeError
+		if !errors.As(infer_err, &type_err) || type_err.Kind != TY_MissingLabel {
+			t.Errorf("%v: got %v, want a missing label", c.source, infer_err)
+		} else if !strings.Contains(type_err.Message, c.want) {
+			t.Errorf("%v: got %v, want %q", c.source, type_err.Message, c.want)
+		}
+	}
+}
+
+func TestUnifyRows(t *testing.T) {
+	closed := &TRecord{Row: &TRowExtend{Label: "A", Field: Int_Type, Rest: &TRowEmpty{}}}
+	for _, swap := range []bool{false, true} {
+		inf := CreateInferencer(nil, nil)
+		var a, b LType = closed, &TRecord{Row: &TRowExtend{Label: "B", Field: Bool_Type, Rest: inf.fresh()}}
+		if swap {
+			a, b = b, a
+		}
+		unify_err := inf.unify(a, b)
+		if unify_err == nil || unify_err.Kind != TY_MissingLabel || unify_err.Label != "B" {
+			t.Fatalf("%v and %v: got %v, want B missing", a.TPrint(), b.TPrint(), unify_err)
+		}
+		if unify_err.Right.TPrint() != closed.TPrint() {
+			t.Errorf("%v and %v: %v named as lacking B, want %v", a.TPrint(), b.TPrint(),
+				unify_err.Right.TPrint(), closed.TPrint())
+		}
+	}
+
+	inf := CreateInferencer(nil, nil)
+	// Rows in a different order unify label by label
+	first := &TRecord{Row: &TRowExtend{Label: "A", Field: Int_Type, Rest: &TRowExtend{Label: "B", Field: inf.fresh(), Rest: &TRowEmpty{}}}}
+	second := &TRecord{Row: &TRowExtend{Label: "B", Field: Bool_Type, Rest: inf.fresh()}}
+	if unify_err := inf.unify(first, second); unify_err != nil {
+		t.Fatalf("%v", unify_err)
+	}
+	if got := inf.Resolve(second).TPrint(); got != inf.Resolve(first).TPrint() {
+		t.Errorf("got %v and %v after unifying", inf.Resolve(first).TPrint(), got)
+	}
+
+	// {A | r} and {B | r} would need r to contain A and B both
+	shared := inf.fresh()
+	with_a := &TRecord{Row: &TRowExtend{Label: "A", Field: Int_Type, Rest: shared}}
+	with_b := &TRecord{Row: &TRowExtend{Label: "B", Field: Int_Type, Rest: shared}}
+	if unify_err := inf.unify(with_a, with_b); unify_err == nil || unify_err.Kind != TY_Occurs {
+		t.Errorf("got %v, want the occurs check to fail", unify_err)
+	}
+}
+
+func TestLowerRecordRejectsDuplicateFields(t *testing.T) {
+	// The parser already rejects {A=(1),A=(2)}, so build the literal by hand
+	record := &LRecord{Labels: []string{"A", "B", "A"}, Fields: []LExpr{&LInt{Value: 1}, &LInt{Value: 2}, &LInt{Value: 3}}}
+	_, lower_err := Lower(record)
+	if lower_err == nil || !strings.Contains(lower_err.Error(), "Field A appears twice") {
+		t.Errorf("got %v, want field A to appear twice", lower_err)
+	}
+}
+
+func TestRecordEncodingRoundTrips(t *testing.T) {
+	sources := []string{
+		"{HOST=(\"localhost\"),PORT=(8080)}",
+		"{B=(@TRUE),A=(1)}",
+		"'OK(5)",
+		"'ERR({CODE=(404)})",
+		"{INNER=({X=(1)}),TAG=('OK(\"a\"))}",
+	}
+	for _, source := range sources {
+		c, lower_err := Lower(parseRecords(t, source))
+		if lower_err != nil {
+			t.Fatalf("%v: %v", source, lower_err)
+		}
+		encoded, encode_err := EncodeRecords(c)
+		if encode_err != nil {
+			t.Errorf("%v: %v", source, encode_err)
+			continue
+		}
+		if decoded := DecodeRecords(normalOrder(t, encoded)); decoded.CPrint() != c.CPrint() {
+			t.Errorf("%v: decoded %v, want %v", source, decoded.CPrint(), c.CPrint())
+		}
+	}
+}
+
+// Projections and cases on tags reduce through the encoding.
+func TestEncodedRecordsReduce(t *testing.T) {
+	cases := []struct {
+		source string
+		want   string
+	}{
+		{"{HOST=(\"localhost\"),PORT=(8080)}.PORT", "8080"},
+		{"{A=({B=(2)})}.A.B", "2"},
+		{"@CASE('OK(5))'OK.V1.(+(V1)(1))'ERR.E1.(0)", "6"},
+		{"@CASE('ERR(5))'OK.V1.(+(V1)(1))'ERR.E1.(0)", "0"},
+	}
+	for _, c := range cases {
+		lowered, lower_err := Lower(parseRecords(t, c.source))
+		if lower_err != nil {
+			t.Fatalf("%v: %v", c.source, lower_err)
+		}
+		encoded, encode_err := EncodeRecords(lowered)
+		if encode_err != nil {
+			t.Fatalf("%v: %v", c.source, encode_err)
+		}
+		if got := Raise(DecodeRecords(normalOrder(t, encoded))).LPrint(); got != c.want {
+			t.Errorf("%v: got %v, want %v", c.source, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+/*
+	Error recovery for TransitionExecutor and LambdaParser
+
+In recovery mode a syntax error does not abort the parse. The parser keeps any complete
+expression pending before the bad character, records the error, drops an LError node into
+Exprs and swallows input until it reaches a character that can only start a new LExpr: "("
+or "L" (variables never contain L), or a capital letter right after swallowed punctuation
+(so it cannot be the middle of a variable). Parentheticals are parsed on their own, so an error
+inside one is contained by its closing ")"; at the top level the statement boundary (end
+of input, or ";"/newline for a Decoder with Recover set, as lambda check uses) is the last
+resynchronization point.
+
+LambdaParser.DriveParseRecover follows the same rules over its own, smaller grammar.
+*/
+type ParseErrorList []*ParseError
+
+func (l ParseErrorList) Error() string {
+	messages := []string{}
+	for _, parse_err := range l {
+		messages = append(messages, parse_err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// LError stands in for input that failed to parse, so a partial tree can still be returned.
+type LError struct {
+	Err *ParseError
+	// Input swallowed while resynchronizing, starting at the offending character
+	Source string
+}
+
+func (l *LError) LPrint() string {
+	return "<error>"
+}
+
+func (l *LError) LAbstract(b LVar) LExpr {
+	out_str := "λ" + b.BindingPrint() + ".(" + l.LPrint() + ")"
+	return &LExpression{
+		Binding: b,
+		Exprs:   []LExpr{l},
+		Repr:    out_str,
+	}
+}
+
+func (l *LError) Copy() LExpr {
+	return &LError{Err: l.Err, Source: l.Source}
+}
+
+func (l *LError) LApply(b LVar, replace LExpr) LExpr {
+	return l.Copy()
+}
+
+// Error nodes are never equivalent to anything, including each other.
+func (l *LError) LEquals(l2 LExpr) bool {
+	return false
+}
+
+// Characters at which a recovering parser stops swallowing input.
+func IsResyncChar(s string) bool {
+	return s == "(" || s == "L"
+}
+
+// Whether s ends the input swallowed into l, shared by both front-ends.
+func (l *LError) CanResync(s string) bool {
+	if IsResyncChar(s) {
+		return true
+	}
+	swallowed := l.Source
+	if len(swallowed) == 0 || !IsCapLetter(s) {
+		return false
+	}
+	last := swallowed[len(swallowed)-1:]
+	return !IsCapLetter(last) && !IsInteger(last) && last != "L"
+}
+
+func (p Parser) CanResync(s string) bool {
+	return p.Skipping.CanResync(s)
+}
+
+func (t *TransitionExecutor) ParseRecover(target_str string) (Parser, ParseErrorList) {
+	p := Parser_Init()
+	p.Recover = true
+	p, parse_err := t.Run(p, target_str)
+	if parse_err != nil {
+		// Only reachable if a callback fails at end of input
+		p = t.RecoverFrom(p, parse_err, "")
+	}
+	return p, p.Errors
+}
+
+// Record err, keep whatever complete expression was pending and start resynchronizing at s.
+func (t *TransitionExecutor) RecoverFrom(p Parser, err error, s string) Parser {
+	if p.TState.S_f.IsTerminal() {
+		pending := p
+		pending.TState = Transition{S_i: p.TState.S_f, S_f: E_0}
+		if flushed, flush_err := t.Apply(pending, ""); flush_err == nil {
+			p.Exprs = flushed.Exprs
+		}
+	}
+	var parse_err *ParseError
+	if !errors.As(p.PositionError(err, s), &parse_err) {
+		return p
+	}
+	error_node := &LError{Err: parse_err}
+	p.Errors = append(p.Errors, parse_err)
+	p.Exprs = append(p.Exprs, error_node)
+	p.LVar = ""
+	p.Parenthetical = ""
+	p.LetBound = nil
+	p.Recursive = false
+	p.Branch = ""
+	p.BranchFields = nil
+	p.RecordLabels = nil
+	p.RecordFields = nil
+	p.TypeBinder = ""
+	p.Annotation = ""
+	p.Universe = ""
+	p.Literal = ""
+	p.TState = Transition{S_i: p.TState.S_f, S_f: I_i}
+	p.NestTracker.DropStray()
+	if IsResyncChar(s) {
+		// The offending char starts a fresh expression, so reading it from I_i cannot fail
+		p, _ = t.Step(p, s)
+		return p
+	}
+	p.Skipping = error_node
+	return p.Skip(s)
+}
+
+// Swallow s into the error node currently being resynchronized past.
+func (p Parser) Skip(s string) Parser {
+	p.Skipping.Source += s
+	p.NestTracker.DropStray()
+	return p
+}
+
+// Input ran out mid-expression. An unclosed parenthetical or lambda body is closed as if the
+// missing ")" were present, so its contents still show up (and get checked) in the tree.
+func (t *TransitionExecutor) RecoverAtEnd(p Parser, end_err *ParseError) Parser {
+	p.Errors = append(p.Errors, end_err)
+	closing := map[ParserState]ParserState{P_i: P_f, LP1: L_f}
+	if closed, found := closing[p.TState.S_f]; found {
+		p.TState = Transition{S_i: p.TState.S_f, S_f: closed}
+		// Counted as read, NestedError places the parenthetical before its closing ")"
+		p.NestTracker.Runes += 1
+		closed_p, close_err := t.Apply(p, ")")
+		if close_err == nil {
+			return closed_p
+		}
+	}
+	p.Exprs = append(p.Exprs, &LError{Err: end_err})
+	p.LVar = ""
+	p.Parenthetical = ""
+	p.LetBound = nil
+	p.Recursive = false
+	p.Branch = ""
+	p.BranchFields = nil
+	p.RecordLabels = nil
+	p.RecordFields = nil
+	p.TypeBinder = ""
+	p.Annotation = ""
+	p.Universe = ""
+	p.Literal = ""
+	p.TState = Transition{S_i: p.TState.S_f, S_f: I_i}
+	return p
+}
+
+// DriveParseRecover parses as DriveParse does, in recovery mode: every error is collected and
+// an LError node takes the place of the input it spoiled.
+func (lp *LambdaParser) DriveParseRecover() ([]LExpr, ParseErrorList) {
+	lp.Recover = true
+	exprs, _ := lp.DriveParse()
+	return exprs, lp.Errors
+}
+
+// Record err, keep a pending variable and start resynchronizing at s, read at offset.
+func (lp *LambdaParser) RecoverFrom(err error, s string, offset int) {
+	var parse_err *ParseError
+	if !errors.As(err, &parse_err) {
+		parse_err = &ParseError{Offset: offset, Char: s, Err: err}
+	}
+	if lp.PState == "V2" {
+		lvar := LVar{Symbol: lp.CollectionStr}
+		lp.LExprArr = append(lp.LExprArr, &lvar)
+	}
+	error_node := &LError{Err: parse_err}
+	lp.Errors = append(lp.Errors, parse_err)
+	lp.LExprArr = append(lp.LExprArr, error_node)
+	lp.LambdaBinding = ""
+	lp.Annotation = ""
+	lp.BindingType = nil
+	lp.CollectionStr = ""
+	lp.PState = "N"
+	lp.ParenthesesTracker.DropStray()
+	if IsResyncChar(s) {
+		// The offending char starts a fresh expression, so reading it from N cannot fail
+		lp.Step(s)
+		return
+	}
+	lp.Skipping = error_node
+	lp.Skip(s)
+}
+
+// Swallow s into the error node currently being resynchronized past.
+func (lp *LambdaParser) Skip(s string) {
+	lp.Skipping.Source += s
+	lp.ParenthesesTracker.DropStray()
+}
+
+// Adopt the errors of a nested parse of CollectionStr, moving them to offsets in SrcStr.
+func (lp *LambdaParser) AdoptErrors(nested ParseErrorList) {
+	for _, inner_err := range nested {
+		// Shift in place so LError nodes of the nested parse keep pointing at the same error
+		inner_err.Offset += lp.CollectionStart.Offset
+		if paren_err, is_paren := inner_err.Err.(*ParenError); is_paren {
+			shifted_paren := *paren_err
+			shifted_paren.Offset = inner_err.Offset
+			shifted_paren.Column += lp.CollectionStart.Column
+			inner_err.Err = &shifted_paren
+		}
+		lp.Errors = append(lp.Errors, inner_err)
+	}
+}
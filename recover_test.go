@@ -0,0 +1,76 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func printExprs(exprs []LExpr) []string {
+	printed := []string{}
+	for _, expr := range exprs {
+		printed = append(printed, expr.LPrint())
+	}
+	return printed
+}
+
+func errorOffsets(errs ParseErrorList) []int {
+	offsets := []int{}
+	for _, parse_err := range errs {
+		offsets = append(offsets, parse_err.Offset)
+	}
+	return offsets
+}
+
+func TestRecoverCollectsEveryError(t *testing.T) {
+	cases := []struct {
+		source  string
+		exprs   []string
+		offsets []int
+	}{
+		{"X1Y1", []string{"X1", "Y1"}, []int{}},
+		{"X1?Y1", []string{"X1", "<error>", "Y1"}, []int{2}},
+		{"LX1.X1(Y1)", []string{"<error>", "Y1"}, []int{4}},
+		{"(X1?)(Y1", []string{"(X1<error>)", "Y1"}, []int{3, 5}},
+		{"X1))Y1", []string{"X1", "<error>", "<error>", "Y1"}, []int{2, 3}},
+		{"LX1.(X1(Y1", []string{"λX1.(X1Y1)"}, []int{4, 7}},
+		{"(A1)(B1?C1)LZ1.(Z1)", []string{"A1", "(B1<error>C1)", "λZ1.Z1"}, []int{7}},
+		{"X1?Y1!LZ1.(Z1)", []string{"X1", "<error>", "Y1", "<error>", "λZ1.Z1"}, []int{2, 5}},
+	}
+	executor := TransitionExecutor_Init()
+	for _, c := range cases {
+		t.Run(c.source, func(t *testing.T) {
+			p, fsm_errs := executor.ParseRecover(c.source)
+			parser := CreateParser(c.source)
+			exprs, lp_errs := parser.DriveParseRecover()
+			for name, got := range map[string][]string{"executor": printExprs(p.Exprs), "lambda parser": printExprs(exprs)} {
+				if !slices.Equal(got, c.exprs) {
+					t.Errorf("%v parsed %v, want %v", name, got, c.exprs)
+				}
+			}
+			for name, got := range map[string]ParseErrorList{"executor": fsm_errs, "lambda parser": lp_errs} {
+				if !slices.Equal(errorOffsets(got), c.offsets) {
+					t.Errorf("%v reported errors at %v, want %v:\n%v", name, errorOffsets(got), c.offsets, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRecoverParenColumnsAgree(t *testing.T) {
+	executor := TransitionExecutor_Init()
+	for _, source := range []string{"LX1.(X1(Y1", "(A1(B1(C1", "LX1.((LY1.(X1)"} {
+		_, fsm_errs := executor.ParseRecover(source)
+		parser := CreateParser(source)
+		_, lp_errs := parser.DriveParseRecover()
+		if fsm_errs.Error() != lp_errs.Error() {
+			t.Errorf("%v: executor reported\n%v\nlambda parser reported\n%v", source, fsm_errs, lp_errs)
+		}
+	}
+}
+
+func TestDriveParseStillAborts(t *testing.T) {
+	parser := CreateParser("X1?Y1")
+	if _, err := parser.DriveParse(); err == nil {
+		t.Errorf("DriveParse accepted X1?Y1 outside recovery mode")
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	Reduction graphs
+
+ExploreReductions contracts every redex of a term, not only the one a strategy would pick,
+and keeps going breadth first from each reduct. Terms are interned in a TermStore, so two
+paths reaching alpha-equivalent terms meet in the same node; the result is the reduction
+graph of the term (cut off by the bounds), in which confluence shows up as diamonds.
+
+A redex is identified by its position, the path of steps from the root down to it: "f" into
+the function of an application, "a" into its argument, "b" into a lambda body. The root
+redex has the empty path, printed as "ε".
+*/
+type ReductionEdge struct {
+	From  int
+	To    int
+	Redex string
+}
+
+type ReductionGraph struct {
+	// Nodes[0] is the starting term
+	Nodes []*HTerm
+	// Breadth first distance of each node from the start, i.e. its shortest path length
+	Depth []int
+	Edges []ReductionEdge
+	// Nodes left unexpanded because a bound was hit
+	Truncated []int
+	store     *TermStore
+	index     map[*HTerm]int
+}
+
+type ReductionBounds struct {
+	// Steps from the starting term, <= 0 means no limit
+	MaxDepth int
+	// Distinct terms, <= 0 means no limit
+	MaxNodes int
+	// Terms larger than this (counted as trees) are not expanded, <= 0 means no limit
+	MaxSize int
+}
+
+type Reduct struct {
+	Redex string
+	Term  *HTerm
+}
+
+// Every term h reduces to in one beta step, one per redex, leftmost outermost first.
+func (s *TermStore) Reducts(h *HTerm) []Reduct {
+	out := []Reduct{}
+	var walk func(h *HTerm, path string, rebuild func(*HTerm) *HTerm)
+	walk = func(h *HTerm, path string, rebuild func(*HTerm) *HTerm) {
+		switch h.Kind {
+		case H_Lam:
+			walk(h.Left, path+"b", func(body *HTerm) *HTerm {
+				return rebuild(s.Lam(body))
+			})
+		case H_App:
+			if h.Left.Kind == H_Lam {
+				out = append(out, Reduct{Redex: path, Term: rebuild(s.Beta(h.Left.Left, h.Right))})
+			}
+			walk(h.Left, path+"f", func(fun *HTerm) *HTerm {
+				return rebuild(s.App(fun, h.Right))
+			})
+			walk(h.Right, path+"a", func(arg *HTerm) *HTerm {
+				return rebuild(s.App(h.Left, arg))
+			})
+		}
+	}
+	walk(h, "", func(h *HTerm) *HTerm { return h })
+	return out
+}
+
+func RedexLabel(path string) string {
+	if len(path) == 0 {
+		return "ε"
+	}
+	return path
+}
+
+// Breadth first exploration of every reduction of c within bounds.
+func ExploreReductions(c Core, bounds ReductionBounds) *ReductionGraph {
+	store := CreateTermStore()
+	start := store.Intern(c)
+	g := &ReductionGraph{
+		Nodes:     []*HTerm{start},
+		Depth:     []int{0},
+		Edges:     []ReductionEdge{},
+		Truncated: []int{},
+		store:     store,
+		index:     map[*HTerm]int{start: 0},
+	}
+	for current := 0; current < len(g.Nodes); current++ {
+		h := g.Nodes[current]
+		if (bounds.MaxDepth > 0 && g.Depth[current] >= bounds.MaxDepth) ||
+			(bounds.MaxSize > 0 && h.Size > bounds.MaxSize) {
+			if len(store.Reducts(h)) > 0 {
+				g.Truncated = append(g.Truncated, current)
+			}
+			continue
+		}
+		for _, reduct := range store.Reducts(h) {
+			target, seen := g.index[reduct.Term]
+			if !seen {
+				if bounds.MaxNodes > 0 && len(g.Nodes) >= bounds.MaxNodes {
+					g.Truncated = append(g.Truncated, current)
+					break
+				}
+				target = len(g.Nodes)
+				g.Nodes = append(g.Nodes, reduct.Term)
+				g.Depth = append(g.Depth, g.Depth[current]+1)
+				g.index[reduct.Term] = target
+			}
+			g.Edges = append(g.Edges, ReductionEdge{From: current, To: target, Redex: reduct.Redex})
+		}
+	}
+	return g
+}
+
+// Node holding the term alpha-equivalent to c, or -1 if it was not reached.
+func (g *ReductionGraph) Find(c Core) int {
+	if i, found := g.index[g.store.Intern(c)]; found {
+		return i
+	}
+	return -1
+}
+
+// Nodes without outgoing edges that are not truncated, i.e. normal forms.
+func (g *ReductionGraph) NormalForms() []int {
+	has_edge := map[int]bool{}
+	for _, edge := range g.Edges {
+		has_edge[edge.From] = true
+	}
+	for _, i := range g.Truncated {
+		has_edge[i] = true
+	}
+	out := []int{}
+	for i := range g.Nodes {
+		if !has_edge[i] {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// Edges of a shortest reduction from the start to node to, false if it is unreachable.
+func (g *ReductionGraph) ShortestPath(to int) ([]ReductionEdge, bool) {
+	// Nodes were added breadth first, so the first edge into a node comes from a parent at
+	// the previous depth
+	parent := make([]int, len(g.Nodes))
+	for i := range parent {
+		parent[i] = -1
+	}
+	for e, edge := range g.Edges {
+		if parent[edge.To] < 0 && edge.To != 0 && g.Depth[edge.To] == g.Depth[edge.From]+1 {
+			parent[edge.To] = e
+		}
+	}
+	if to < 0 || to >= len(g.Nodes) {
+		return nil, false
+	}
+	path := []ReductionEdge{}
+	for node := to; node != 0; {
+		if parent[node] < 0 {
+			return nil, false
+		}
+		edge := g.Edges[parent[node]]
+		path = append(path, edge)
+		node = edge.From
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, true
+}
+
+// Shortest reduction to a normal form, false if none was reached within the bounds.
+func (g *ReductionGraph) ShortestNormalization() ([]ReductionEdge, bool) {
+	normal_forms := g.NormalForms()
+	if len(normal_forms) == 0 {
+		return nil, false
+	}
+	best := normal_forms[0]
+	for _, i := range normal_forms {
+		if g.Depth[i] < g.Depth[best] {
+			best = i
+		}
+	}
+	return g.ShortestPath(best)
+}
+
+// Graphviz source, nodes labelled by their read back term, normal forms doubly circled and
+// truncated nodes dashed.
+func (g *ReductionGraph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph Reductions {\n\tnode [shape=box];\n")
+	normal := map[int]bool{}
+	for _, i := range g.NormalForms() {
+		normal[i] = true
+	}
+	truncated := map[int]bool{}
+	for _, i := range g.Truncated {
+		truncated[i] = true
+	}
+	for i, node := range g.Nodes {
+		attrs := []string{fmt.Sprintf("label=%q", Raise(node.Core()).LPrint())}
+		if normal[i] {
+			attrs = append(attrs, "peripheries=2")
+		}
+		if truncated[i] {
+			attrs = append(attrs, "style=dashed")
+		}
+		if i == 0 {
+			attrs = append(attrs, "penwidth=2")
+		}
+		fmt.Fprintf(&b, "\tn%v [%v];\n", i, strings.Join(attrs, ", "))
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&b, "\tn%v -> n%v [label=%q];\n", edge.From, edge.To, RedexLabel(edge.Redex))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
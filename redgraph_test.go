@@ -0,0 +1,102 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// (λX1.X1X1)((λY1.Y1)Z1) reduces to Z1Z1 along three paths, all meeting in one node:
+//
+//	0 --ε--> 1 (IZ)(IZ) --f--> 3 Z(IZ) --a--> 5 ZZ
+//	                    --a--> 4 (IZ)Z --f--> 5
+//	0 --a--> 2 (λX1.X1X1)Z --ε--> 5
+func diamondTerm(t *testing.T) Core {
+	t.Helper()
+	return parseCore(t, "(LX1.(X1X1))((LY1.(Y1))(Z1))")
+}
+
+func TestExploreReductionsMergesPaths(t *testing.T) {
+	g := ExploreReductions(diamondTerm(t), ReductionBounds{})
+	if len(g.Nodes) != 6 || len(g.Edges) != 7 || len(g.Truncated) != 0 {
+		t.Fatalf("got %v nodes, %v edges and %v truncated, want 6, 7 and 0", len(g.Nodes), len(g.Edges), len(g.Truncated))
+	}
+	normal := g.Find(parseCore(t, "Z1Z1"))
+	if normal < 0 {
+		t.Fatalf("Z1Z1 was not reached")
+	}
+	into := 0
+	for _, edge := range g.Edges {
+		if edge.To == normal {
+			into += 1
+		}
+	}
+	if into != 3 {
+		t.Errorf("%v edges into Z1Z1, want 3", into)
+	}
+	if forms := g.NormalForms(); len(forms) != 1 || forms[0] != normal {
+		t.Errorf("got normal forms %v, want only %v", forms, normal)
+	}
+	if g.Find(parseCore(t, "(LX1.(X1X1))(Z1)")) != 2 || g.Find(parseCore(t, "Z2Z2")) != -1 {
+		t.Errorf("Find does not go by alpha equivalence of interned terms")
+	}
+}
+
+func TestShortestNormalization(t *testing.T) {
+	g := ExploreReductions(diamondTerm(t), ReductionBounds{})
+	path, found := g.ShortestNormalization()
+	if !found {
+		t.Fatalf("no normalization found")
+	}
+	redexes := []string{}
+	for _, edge := range path {
+		redexes = append(redexes, RedexLabel(edge.Redex))
+	}
+	if got := strings.Join(redexes, " "); got != "a ε" {
+		t.Errorf("got the path %v, want a ε", got)
+	}
+	if g.Depth[path[len(path)-1].To] != 2 {
+		t.Errorf("normal form at depth %v, want 2", g.Depth[path[len(path)-1].To])
+	}
+}
+
+func TestExploreReductionsBounds(t *testing.T) {
+	cases := []struct {
+		name      string
+		bounds    ReductionBounds
+		nodes     int
+		truncated []int
+	}{
+		{"depth", ReductionBounds{MaxDepth: 1}, 3, []int{1, 2}},
+		{"nodes", ReductionBounds{MaxNodes: 4}, 4, []int{1, 2, 3}},
+		{"size", ReductionBounds{MaxSize: 1}, 1, []int{0}},
+	}
+	for _, c := range cases {
+		g := ExploreReductions(diamondTerm(t), c.bounds)
+		if len(g.Nodes) != c.nodes || !slices.Equal(g.Truncated, c.truncated) {
+			t.Errorf("%v: got %v nodes truncated at %v, want %v at %v", c.name, len(g.Nodes), g.Truncated, c.nodes, c.truncated)
+		}
+		if _, found := g.ShortestNormalization(); found {
+			t.Errorf("%v: found a normalization past the bounds", c.name)
+		}
+	}
+	omega := ExploreReductions(parseCore(t, "(LX1.(X1X1))(LX1.(X1X1))"), ReductionBounds{})
+	if len(omega.Nodes) != 1 || len(omega.Edges) != 1 || len(omega.NormalForms()) != 0 {
+		t.Errorf("Ω: got %v nodes and %v edges, want one node looping on itself", len(omega.Nodes), len(omega.Edges))
+	}
+}
+
+func TestReductionGraphDOT(t *testing.T) {
+	dot := ExploreReductions(diamondTerm(t), ReductionBounds{}).DOT()
+	for _, want := range []string{"digraph Reductions {", "n0 -> n1 [label=\"ε\"]", "n0 -> n2 [label=\"a\"]", "peripheries=2", "penwidth=2"} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT lacks %q:\n%v", want, dot)
+		}
+	}
+	if strings.Contains(dot, "dashed") {
+		t.Errorf("nothing is truncated but DOT has a dashed node:\n%v", dot)
+	}
+	if cut := ExploreReductions(diamondTerm(t), ReductionBounds{MaxDepth: 1}).DOT(); strings.Count(cut, "style=dashed") != 2 {
+		t.Errorf("want the two nodes at depth 1 dashed:\n%v", cut)
+	}
+}
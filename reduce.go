@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+	Substitution-based reduction over Core
+
+Every beta step rebuilds the redex's body with the argument substituted in, the same work
+LApplyInit does over LExpr but capture-avoiding thanks to de Bruijn indices. This is the
+reference the machine evaluators are checked and benchmarked against, and its delta rules for
+the primitive operators (see prims.go) are the ones they follow.
+*/
+type Strategy int
+
+const (
+	_                  Strategy = iota
+	R_NormalOrder               // Leftmost outermost redex, reducing under lambdas
+	R_ApplicativeOrder          // Leftmost innermost redex, reducing under lambdas
+	R_CallByName                // Leftmost outermost redex, stopping at a weak head normal form
+	R_CallByValue               // Arguments to values first, stopping at a weak head normal form
+	R_HeadReduction             // Leftmost outermost redex in head position, stopping at a head normal form
+)
+
+func (s Strategy) ToString() string {
+	switch s {
+	case R_NormalOrder:
+		return "normal order"
+	case R_ApplicativeOrder:
+		return "applicative order"
+	case R_CallByName:
+		return "call-by-name"
+	case R_CallByValue:
+		return "call-by-value"
+	case R_HeadReduction:
+		return "head reduction"
+	}
+	return "indeterminate strategy"
+}
+
+var ErrStepLimit = errors.New("reduction step limit reached")
+
+// Shift adds d to every index of c that points past cutoff binders.
+func Shift(c Core, d int, cutoff int) Core {
+	if d == 0 {
+		return c
+	}
+	switch node := c.(type) {
+	case *CVar:
+		if node.Index >= cutoff {
+			return &CVar{Index: node.Index + d, Name: node.Name}
+		}
+		return node
+	case *CLam:
+		return &CLam{Name: node.Name, Body: Shift(node.Body, d, cutoff+1)}
+	case *CApp:
+		return &CApp{Fun: Shift(node.Fun, d, cutoff), Arg: Shift(node.Arg, d, cutoff)}
+	}
+	return c
+}
+
+// Replace index depth in c by replace (which is valid at depth 0) and close the gap left by
+// the removed binder.
+func substitute(c Core, depth int, replace Core) Core {
+	switch node := c.(type) {
+	case *CVar:
+		if node.Index == depth {
+			return Shift(replace, depth, 0)
+		} else if node.Index > depth {
+			return &CVar{Index: node.Index - 1, Name: node.Name}
+		}
+		return node
+	case *CLam:
+		return &CLam{Name: node.Name, Body: substitute(node.Body, depth+1, replace)}
+	case *CApp:
+		return &CApp{Fun: substitute(node.Fun, depth, replace), Arg: substitute(node.Arg, depth, replace)}
+	}
+	return c
+}
+
+// Contract the redex (λ.body) arg.
+func Beta(body Core, arg Core) Core {
+	return substitute(body, 0, arg)
+}
+
+func IsRedex(c Core) bool {
+	app, is_app := c.(*CApp)
+	if !is_app {
+		return false
+	}
+	_, is_lam := app.Fun.(*CLam)
+	return is_lam
+}
+
+// Step contracts one redex chosen by s. Returns false if c has no redex s would pick.
+func Step(c Core, s Strategy) (Core, bool) {
+	switch node := c.(type) {
+	case *CLam:
+		if s == R_CallByName || s == R_CallByValue {
+			return c, false
+		}
+		body, stepped := Step(node.Body, s)
+		if !stepped {
+			return c, false
+		}
+		return &CLam{Name: node.Name, Body: body}, true
+	case *CApp:
+		if next, stepped := StepPrim(c, s); stepped {
+			return next, true
+		}
+		if next, stepped, is_fix := stepFixApplication(c, s); is_fix {
+			return next, stepped
+		}
+		lam, is_redex := node.Fun.(*CLam)
+		if is_redex && (s == R_NormalOrder || s == R_CallByName || s == R_HeadReduction) {
+			return Beta(lam.Body, node.Arg), true
+		}
+		if fun, stepped := Step(node.Fun, s); stepped {
+			return &CApp{Fun: fun, Arg: node.Arg}, true
+		}
+		if s == R_CallByName || s == R_HeadReduction {
+			return c, false
+		}
+		if arg, stepped := Step(node.Arg, s); stepped {
+			return &CApp{Fun: node.Fun, Arg: arg}, true
+		}
+		if is_redex {
+			return Beta(lam.Body, node.Arg), true
+		}
+	}
+	return c, false
+}
+
+// Reduce steps c under s until no redex is left, or max_steps steps have been taken
+// (max_steps <= 0 means no limit). Returns the final term and the number of steps taken, beta
+// and delta steps alike, and ErrIllFounded if a strict s is stuck on a recursive definition
+// that needs its own value.
+func Reduce(c Core, s Strategy, max_steps int) (Core, int, error) {
+	steps := 0
+	for {
+		next, stepped := Step(c, s)
+		if !stepped {
+			return c, steps, illFoundedError(c, s)
+		}
+		if max_steps > 0 && steps >= max_steps {
+			return c, steps, fmt.Errorf("%w: %v steps of %v", ErrStepLimit, steps, s.ToString())
+		}
+		c = next
+		steps += 1
+	}
+}
+
+// Reduce a parsed term, reading the result back as an LExpr.
+func ReduceLExpr(l LExpr, s Strategy, max_steps int) (LExpr, int, error) {
+	c, lower_err := Lower(l)
+	if lower_err != nil {
+		return nil, 0, lower_err
+	}
+	reduced, steps, reduce_err := Reduce(c, s, max_steps)
+	if reduce_err != nil {
+		return nil, steps, reduce_err
+	}
+	return Raise(reduced), steps, nil
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+	System F - the polymorphic lambda calculus
+
+Terms gain type abstraction, ΛA.(...), binding the type variable A in its body, and type
+application, written as a type argument in brackets among the arguments of an application:
+ID[NAT]X1 applies ID to the type NAT, then to X1. Types gain ∀A.T. Since the parser
+uppercases its input, type variables and base types share a syntax; a type name is a
+variable where an enclosing Λ or ∀ binds it and a base type elsewhere.
+
+CheckSystemF types a term (every lambda binding needs an annotation), and EvalSystemF runs
+it with its types erased: Λ and type arguments disappear in Lower, leaving an untyped term
+for the existing evaluators.
+*/
+type TForall struct {
+	Var  string
+	Body LType
+}
+
+func (t *TForall) TPrint() string {
+	return "∀" + t.Var + "." + t.Body.TPrint()
+}
+
+// Alpha-equivalence, ∀A.A→A equals ∀B.B→B.
+func (t *TForall) TEquals(t2 LType) bool {
+	return TypeAlphaEquals(t, t2)
+}
+
+func TypeAlphaEquals(a LType, b LType) bool {
+	return typeAlphaEquals(a, b, []string{}, []string{})
+}
+
+func typeAlphaEquals(a LType, b LType, bound_a []string, bound_b []string) bool {
+	switch node := a.(type) {
+	case *TBase:
+		base, is_base := b.(*TBase)
+		if !is_base {
+			return false
+		}
+		i, j := lastIndex(bound_a, node.Name), lastIndex(bound_b, base.Name)
+		if i < 0 && j < 0 {
+			return node.Name == base.Name
+		}
+		return i == j
+	case *TArrow:
+		arrow, is_arrow := b.(*TArrow)
+		return is_arrow && typeAlphaEquals(node.From, arrow.From, bound_a, bound_b) &&
+			typeAlphaEquals(node.To, arrow.To, bound_a, bound_b)
+	case *TForall:
+		forall, is_forall := b.(*TForall)
+		return is_forall && typeAlphaEquals(node.Body, forall.Body,
+			append(bound_a[:len(bound_a):len(bound_a)], node.Var),
+			append(bound_b[:len(bound_b):len(bound_b)], forall.Var))
+	}
+	return a.TEquals(b)
+}
+
+func lastIndex(names []string, name string) int {
+	for i := len(names) - 1; i >= 0; i-- {
+		if names[i] == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Type names occurring free in t.
+func FreeTypeNames(t LType, out map[string]bool) {
+	switch node := t.(type) {
+	case *TBase:
+		out[node.Name] = true
+	case *TArrow:
+		FreeTypeNames(node.From, out)
+		FreeTypeNames(node.To, out)
+	case *TForall:
+		inner := map[string]bool{}
+		FreeTypeNames(node.Body, inner)
+		delete(inner, node.Var)
+		for name := range inner {
+			out[name] = true
+		}
+	}
+}
+
+// Simultaneous capture-avoiding substitution of the free type names of t.
+func SubstTypes(t LType, replace map[string]LType) LType {
+	switch node := t.(type) {
+	case *TBase:
+		if replacement, found := replace[node.Name]; found {
+			return replacement
+		}
+		return node
+	case *TArrow:
+		return &TArrow{From: SubstTypes(node.From, replace), To: SubstTypes(node.To, replace)}
+	case *TForall:
+		inner := map[string]LType{}
+		avoid := map[string]bool{}
+		for name, replacement := range replace {
+			if name != node.Var {
+				inner[name] = replacement
+				FreeTypeNames(replacement, avoid)
+			}
+		}
+		if len(inner) == 0 {
+			return node
+		}
+		forall_var, body := node.Var, node.Body
+		if avoid[forall_var] {
+			FreeTypeNames(body, avoid)
+			forall_var = FreshTypeName(forall_var, avoid)
+			body = SubstTypes(body, map[string]LType{node.Var: &TBase{Name: forall_var}})
+		}
+		return &TForall{Var: forall_var, Body: SubstTypes(body, inner)}
+	}
+	return t
+}
+
+// name, or name with a numeric suffix, whichever is first not in used.
+func FreshTypeName(name string, used map[string]bool) string {
+	if !used[name] {
+		return name
+	}
+	base := strings.TrimRightFunc(name, func(r rune) bool { return '0' <= r && r <= '9' })
+	for i := 1; ; i++ {
+		candidate := base + strconv.Itoa(i)
+		if !used[candidate] {
+			return candidate
+		}
+	}
+}
+
+// Characters of a type variable bound by Λ.
+func IsTypeNameChar(s string) bool {
+	for _, r := range s {
+		if !(('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') || r == '_') {
+			return false
+		}
+	}
+	return len(s) > 0
+}
+
+// LTyAbs is ΛVar.(Body).
+type LTyAbs struct {
+	Var  string
+	Body LExpr
+}
+
+func (l *LTyAbs) LPrint() string {
+	return "Λ" + l.Var + "." + parenthesized(l.Body)
+}
+
+func (l *LTyAbs) LAbstract(b LVar) LExpr {
+	out_str := "λ" + b.BindingPrint() + ".(" + l.LPrint() + ")"
+	return &LExpression{
+		Binding: b,
+		Exprs:   []LExpr{l},
+		Repr:    out_str,
+	}
+}
+
+func (l *LTyAbs) Copy() LExpr {
+	return &LTyAbs{Var: l.Var, Body: l.Body.Copy()}
+}
+
+func (l *LTyAbs) LApply(b LVar, replace LExpr) LExpr {
+	return &LTyAbs{Var: l.Var, Body: l.Body.LApply(b, replace)}
+}
+
+func (l *LTyAbs) LEquals(l2 LExpr) bool {
+	return l.LPrint() == l2.LPrint()
+}
+
+// LTypeArg is a type argument [Type], only meaningful among the arguments of an application.
+type LTypeArg struct {
+	Type LType
+}
+
+func (l *LTypeArg) LPrint() string {
+	return "[" + l.Type.TPrint() + "]"
+}
+
+func (l *LTypeArg) LAbstract(b LVar) LExpr {
+	out_str := "λ" + b.BindingPrint() + ".(" + l.LPrint() + ")"
+	return &LExpression{
+		Binding: b,
+		Exprs:   []LExpr{l},
+		Repr:    out_str,
+	}
+}
+
+func (l *LTypeArg) Copy() LExpr {
+	return &LTypeArg{Type: l.Type}
+}
+
+func (l *LTypeArg) LApply(b LVar, replace LExpr) LExpr {
+	return l.Copy()
+}
+
+func (l *LTypeArg) LEquals(l2 LExpr) bool {
+	return l.LPrint() == l2.LPrint()
+}
+
+// Type variable bound by an enclosing Λ. Internal is the name it has in the types the
+// checker builds, renamed when Name is already bound so that types of variables in scope
+// cannot be captured.
+type typeBinding struct {
+	Name     string
+	Internal string
+}
+
+type FChecker struct {
+	// Spans recorded by the parser, used to locate errors
+	Spans   map[LExpr]Span
+	Context TypeContext
+}
+
+// Type of l in System F, or a *TypeError.
+func CheckSystemF(l LExpr, spans map[LExpr]Span, context TypeContext) (LType, error) {
+	checker := FChecker{Spans: spans, Context: context}
+	return checker.check(l, []stlcBinding{}, []typeBinding{}, Span{})
+}
+
+// Type the term of a decoded statement, positioning any error in the decoded input.
+func CheckSystemFStatement(stmt Statement, context TypeContext) (LType, error) {
+	t, check_err := CheckSystemF(stmt.Expr, stmt.Spans, context)
+	if type_err, is_type_err := check_err.(*TypeError); is_type_err {
+		located := *type_err
+		located.Pos = stmt.Locate(located.Span.Offset)
+		return nil, &located
+	}
+	return t, check_err
+}
+
+func (fc FChecker) span(l LExpr, outer Span) Span {
+	if span, found := fc.Spans[l]; found {
+		return span
+	}
+	return outer
+}
+
+// An annotation as written, with the type variables in tscope given their internal names.
+func (fc FChecker) resolve(t LType, tscope []typeBinding) LType {
+	replace := map[string]LType{}
+	for _, binding := range tscope {
+		replace[binding.Name] = &TBase{Name: binding.Internal}
+	}
+	return SubstTypes(t, replace)
+}
+
+func (fc FChecker) check(l LExpr, scope []stlcBinding, tscope []typeBinding, outer Span) (LType, error) {
+	span := fc.span(l, outer)
+	switch node := l.(type) {
+	case *LVar:
+		for i := len(scope) - 1; i >= 0; i-- {
+			if scope[i].Name == node.Symbol {
+				return scope[i].Type, nil
+			}
+		}
+		if t, found := fc.Context[node.Symbol]; found {
+			return t, nil
+		}
+		return nil, &TypeError{Kind: TY_Unbound, Span: span, Term: node.Symbol,
+			Message: fmt.Sprintf("%v is not bound by a lambda and has no type in the context", node.Symbol)}
+	case *LExpression:
+		if len(node.Binding.Symbol) == 0 {
+			return fc.checkSeq(node, node.Exprs, scope, tscope, span)
+		}
+		if node.Binding.Type == nil {
+			return nil, &TypeError{Kind: TY_Unannotated, Span: span, Term: node.LPrint(),
+				Message: fmt.Sprintf("binding %v of %v needs a type annotation (λ%v:A.)",
+					node.Binding.Symbol, node.LPrint(), node.Binding.Symbol)}
+		}
+		binding_type := fc.resolve(node.Binding.Type, tscope)
+		inner_scope := append(scope[:len(scope):len(scope)], stlcBinding{Name: node.Binding.Symbol, Type: binding_type})
+		body, body_err := fc.checkSeq(node, node.Exprs, inner_scope, tscope, span)
+		if body_err != nil {
+			return nil, body_err
+		}
+		return &TArrow{From: binding_type, To: body}, nil
+	case *LTyAbs:
+		// Rename the binder if it would capture a type variable in scope or a type name the
+		// body's annotations use freely
+		avoid := map[string]bool{}
+		for _, binding := range tscope {
+			avoid[binding.Internal] = true
+		}
+		for _, binding := range scope {
+			FreeTypeNames(binding.Type, avoid)
+		}
+		for _, t := range fc.Context {
+			FreeTypeNames(t, avoid)
+		}
+		annotated := map[string]bool{}
+		AnnotationTypeNames(node.Body, annotated)
+		delete(annotated, node.Var)
+		for name := range annotated {
+			avoid[name] = true
+		}
+		internal := FreshTypeName(node.Var, avoid)
+		inner_tscope := append(tscope[:len(tscope):len(tscope)], typeBinding{Name: node.Var, Internal: internal})
+		body, body_err := fc.check(node.Body, scope, inner_tscope, span)
+		if body_err != nil {
+			return nil, body_err
+		}
+		return &TForall{Var: internal, Body: body}, nil
+	case *LLet:
+		bound_scope := scope
+		if node.Recursive && node.Binding.Type == nil {
+			return nil, &TypeError{Kind: TY_Unannotated, Span: span, Term: node.LPrint(),
+				Message: fmt.Sprintf("recursive binding %v of %v needs a type annotation (λ@%v:A=)",
+					node.Binding.Symbol, node.LPrint(), node.Binding.Symbol)}
+		} else if node.Recursive {
+			bound_scope = append(scope[:len(scope):len(scope)], stlcBinding{Name: node.Binding.Symbol, Type: fc.resolve(node.Binding.Type, tscope)})
+		}
+		bound, bound_err := fc.check(node.Bound, bound_scope, tscope, span)
+		if bound_err != nil {
+			return nil, bound_err
+		}
+		if node.Binding.Type != nil && !TypeAlphaEquals(fc.resolve(node.Binding.Type, tscope), bound) {
+			annotated := fc.resolve(node.Binding.Type, tscope)
+			return nil, &TypeError{Kind: TY_Mismatch, Span: fc.span(node.Bound, span), Term: node.Bound.LPrint(),
+				Expected: annotated, Found: bound,
+				Message: fmt.Sprintf("%v has type %v but %v is annotated %v",
+					node.Bound.LPrint(), bound.TPrint(), node.Binding.Symbol, annotated.TPrint())}
+		}
+		inner_scope := append(scope[:len(scope):len(scope)], stlcBinding{Name: node.Binding.Symbol, Type: bound})
+		return fc.check(node.Body, inner_scope, tscope, span)
+	}
+	return nil, &TypeError{Kind: TY_Malformed, Span: span, Term: l.LPrint(),
+		Message: fmt.Sprintf("cannot type %v", l.LPrint())}
+}
+
+// Left-nested application of exprs, term and type arguments alike.
+func (fc FChecker) checkSeq(node LExpr, exprs []LExpr, scope []stlcBinding, tscope []typeBinding, span Span) (LType, error) {
+	if len(exprs) == 0 {
+		return nil, &TypeError{Kind: TY_Malformed, Span: span, Term: node.LPrint(), Message: "empty expression"}
+	}
+	if _, is_type_arg := exprs[0].(*LTypeArg); is_type_arg {
+		return nil, &TypeError{Kind: TY_Malformed, Span: fc.span(exprs[0], span), Term: exprs[0].LPrint(),
+			Message: fmt.Sprintf("type argument %v is not applied to anything", exprs[0].LPrint())}
+	}
+	fun_type, fun_err := fc.check(exprs[0], scope, tscope, span)
+	if fun_err != nil {
+		return nil, fun_err
+	}
+	fun_text := exprs[0].LPrint()
+	fun_span := fc.span(exprs[0], span)
+	for _, arg := range exprs[1:] {
+		arg_span := fc.span(arg, span)
+		if type_arg, is_type_arg := arg.(*LTypeArg); is_type_arg {
+			forall, is_forall := fun_type.(*TForall)
+			if !is_forall {
+				return nil, &TypeError{Kind: TY_NotPolymorphic, Span: fun_span, Term: fun_text, Found: fun_type,
+					Message: fmt.Sprintf("%v has type %v and cannot be applied to the type %v",
+						fun_text, fun_type.TPrint(), type_arg.Type.TPrint())}
+			}
+			fun_type = SubstTypes(forall.Body, map[string]LType{forall.Var: fc.resolve(type_arg.Type, tscope)})
+		} else {
+			arg_type, arg_err := fc.check(arg, scope, tscope, span)
+			if arg_err != nil {
+				return nil, arg_err
+			}
+			arrow, is_arrow := fun_type.(*TArrow)
+			if !is_arrow {
+				return nil, &TypeError{Kind: TY_NotFunction, Span: fun_span, Term: fun_text, Found: fun_type,
+					Message: fmt.Sprintf("%v has type %v and cannot be applied to %v", fun_text, fun_type.TPrint(), arg.LPrint())}
+			}
+			if !TypeAlphaEquals(arrow.From, arg_type) {
+				return nil, &TypeError{Kind: TY_Mismatch, Span: arg_span, Term: arg.LPrint(),
+					Expected: arrow.From, Found: arg_type,
+					Message: fmt.Sprintf("%v has type %v but %v expects an argument of type %v",
+						arg.LPrint(), arg_type.TPrint(), fun_text, arrow.From.TPrint())}
+			}
+			fun_type = arrow.To
+		}
+		fun_text += arg.LPrint()
+		fun_span = Span{Offset: fun_span.Offset, End: max(fun_span.End, arg_span.End)}
+	}
+	return fun_type, nil
+}
+
+// Type names used freely by the annotations and type arguments of l, i.e. not bound by a Λ
+// or ∀ within l.
+func AnnotationTypeNames(l LExpr, out map[string]bool) {
+	switch node := l.(type) {
+	case *LExpression:
+		if node.Binding.Type != nil {
+			FreeTypeNames(node.Binding.Type, out)
+		}
+		for _, expr := range node.Exprs {
+			AnnotationTypeNames(expr, out)
+		}
+	case *LLet:
+		if node.Binding.Type != nil {
+			FreeTypeNames(node.Binding.Type, out)
+		}
+		AnnotationTypeNames(node.Bound, out)
+		AnnotationTypeNames(node.Body, out)
+	case *LTyAbs:
+		inner := map[string]bool{}
+		AnnotationTypeNames(node.Body, inner)
+		delete(inner, node.Var)
+		for name := range inner {
+			out[name] = true
+		}
+	case *LTypeArg:
+		FreeTypeNames(node.Type, out)
+	}
+}
+
+// Check l in System F, then normalize it with its types erased.
+func EvalSystemF(l LExpr, spans map[LExpr]Span, max_steps int) (LType, LExpr, int, error) {
+	t, check_err := CheckSystemF(l, spans, TypeContext{})
+	if check_err != nil {
+		return nil, nil, 0, check_err
+	}
+	c, lower_err := Lower(l)
+	if lower_err != nil {
+		return t, nil, 0, lower_err
+	}
+	nf, steps, reduce_err := Reduce(c, R_NormalOrder, max_steps)
+	if reduce_err != nil {
+		return t, nil, steps, reduce_err
+	}
+	return t, Raise(nf), steps, nil
+}
+
+// Typed Church encodings, checked and run by "lambda systemf". BOOL is ∀A.A→A→A, NAT is
+// ∀A.(A→A)→A→A and A×B is ∀C.(A→B→C)→C.
+const SystemF_Examples_Source = `
+ID = ΛA.(LX1:A.(X1))
+SELF_APPLY = LX1:(∀A.A→A).(X1[∀A.A→A]X1)
+ID_ID = (LX1:(∀A.A→A).(X1[∀A.A→A]X1))(ΛA.(LX1:A.(X1)))
+
+TRUE = ΛA.(LX1:A.(LY1:A.(X1)))
+FALSE = ΛA.(LX1:A.(LY1:A.(Y1)))
+NOT = LB1:(∀A.A→A→A).(ΛA.(LX1:A.(LY1:A.(B1[A]Y1X1))))
+NOT_TRUE = (LB1:(∀A.A→A→A).(ΛA.(LX1:A.(LY1:A.(B1[A]Y1X1)))))(ΛA.(LX1:A.(LY1:A.(X1))))
+
+ZERO = ΛA.(LF1:A→A.(LX1:A.(X1)))
+SUCC = LN1:(∀A.(A→A)→A→A).(ΛA.(LF1:A→A.(LX1:A.(F1(N1[A]F1X1)))))
+PLUS = LM1:(∀A.(A→A)→A→A).(LN1:(∀A.(A→A)→A→A).(ΛA.(LF1:A→A.(LX1:A.(M1[A]F1(N1[A]F1X1))))))
+TWO = LS1=(LN1:(∀A.(A→A)→A→A).(ΛA.(LF1:A→A.(LX1:A.(F1(N1[A]F1X1)))))).(S1(S1(ΛA.(LF1:A→A.(LX1:A.(X1))))))
+
+PAIR = ΛA.(ΛB.(LX1:A.(LY1:B.(ΛC.(LF1:A→B→C.(F1X1Y1))))))
+FST = ΛA.(ΛB.(LP1:(∀C.(A→B→C)→C).(P1[A](LX1:A.(LY1:B.(X1))))))
+SWAP = ΛA.(ΛB.(LP1:(∀C.(A→B→C)→C).(ΛC.(LF1:B→A→C.(P1[C](LX1:A.(LY1:B.(F1Y1X1))))))))
+`
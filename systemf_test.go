@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func decodeAll(t *testing.T, source string) []Statement {
+	t.Helper()
+	decoder := CreateDecoder(strings.NewReader(source))
+	stmts := []Statement{}
+	for {
+		stmt, decode_err := decoder.Decode()
+		if decode_err == io.EOF {
+			return stmts
+		} else if decode_err != nil {
+			t.Fatalf("%v", decode_err)
+		}
+		stmts = append(stmts, stmt)
+	}
+}
+
+func TestSystemFChurchEncodings(t *testing.T) {
+	want := map[string]string{
+		"ID":         "∀A.A→A",
+		"SELF_APPLY": "(∀A.A→A)→∀A.A→A",
+		"NOT":        "(∀A.A→A→A)→∀A.A→A→A",
+		"NOT_TRUE":   "∀A.A→A→A",
+		"SUCC":       "(∀A.(A→A)→A→A)→∀A.(A→A)→A→A",
+		"TWO":        "∀A.(A→A)→A→A",
+		"PAIR":       "∀A.∀B.A→B→∀C.(A→B→C)→C",
+		"SWAP":       "∀A.∀B.(∀C.(A→B→C)→C)→∀C.(B→A→C)→C",
+	}
+	for _, stmt := range decodeAll(t, SystemF_Examples_Source) {
+		got, check_err := CheckSystemFStatement(stmt, TypeContext{})
+		if check_err != nil {
+			t.Errorf("%v: %v", stmt.Name, check_err)
+		} else if expected, listed := want[stmt.Name]; listed && got.TPrint() != expected {
+			t.Errorf("%v: got %v, want %v", stmt.Name, got.TPrint(), expected)
+		}
+	}
+}
+
+func TestSubstTypesAvoidsCapture(t *testing.T) {
+	// (∀B.A→B)[A := B] must not capture the B it brings in
+	forall := &TForall{Var: "B", Body: &TArrow{From: &TBase{Name: "A"}, To: &TBase{Name: "B"}}}
+	got := SubstTypes(forall, map[string]LType{"A": &TBase{Name: "B"}})
+	if got.TPrint() != "∀B1.B→B1" {
+		t.Errorf("got %v, want ∀B1.B→B1", got.TPrint())
+	}
+	// A bound name shadows the substitution
+	shadowed := &TForall{Var: "A", Body: &TBase{Name: "A"}}
+	if got := SubstTypes(shadowed, map[string]LType{"A": Int_Type}); got.TPrint() != "∀A.A" {
+		t.Errorf("got %v, want ∀A.A", got.TPrint())
+	}
+	// Through a type application in a term
+	stmts := decodeAll(t, "(ΛA.(LX1:A.(ΛB.(LY1:B.(X1)))))[B]")
+	if got, check_err := CheckSystemFStatement(stmts[0], TypeContext{}); check_err != nil || got.TPrint() != "B→∀B1.B1→B" {
+		t.Errorf("got %v, %v, want B→∀B1.B1→B", got, check_err)
+	}
+}
+
+func TestSystemFErrors(t *testing.T) {
+	cases := []struct {
+		source string
+		want   TypeErrorKind
+	}{
+		{"(LX1:A.(X1))[A]", TY_NotPolymorphic},
+		{"(ΛA.(LX1:A.(X1)))[A][A]", TY_NotPolymorphic},
+		{"LX1.(X1)", TY_Unannotated},
+		{"ΛA.(LX1.(X1))", TY_Unannotated},
+	}
+	for _, c := range cases {
+		stmts := decodeAll(t, c.source)
+		_, check_err := CheckSystemFStatement(stmts[0], TypeContext{})
+		var type_err *TypeError
+		if !errors.As(check_err, &type_err) || type_err.Kind != c.want {
+			t.Errorf("%v: got %v, want a %v error", c.source, check_err, c.want.ToString())
+		}
+	}
+}
+
+// Types and type arguments are erased before reducing.
+func TestEvalSystemFErases(t *testing.T) {
+	cases := []struct {
+		source string
+		want   string
+	}{
+		{"(LB1:(∀A.A→A→A).(ΛA.(LX1:A.(LY1:A.(B1[A]Y1X1)))))(ΛA.(LX1:A.(LY1:A.(X1))))", "λX1.λY1.Y1"},
+		{"(ΛA.(LX1:A.(X1)))[∀B.B→B](ΛB.(LY1:B.(Y1)))", "λY1.Y1"},
+	}
+	for _, c := range cases {
+		stmts := decodeAll(t, c.source)
+		_, nf, _, eval_err := EvalSystemF(stmts[0].Expr, stmts[0].Spans, Test_Max_Steps)
+		if eval_err != nil {
+			t.Errorf("%v: %v", c.source, eval_err)
+		} else if nf.LPrint() != c.want {
+			t.Errorf("%v: got %v, want %v", c.source, nf.LPrint(), c.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+/*
+	ParseTracer - receives structured events while a parser runs
+
+Both front-ends (TransitionExecutor.Parse and LambdaParser.DriveParse) are silent unless a
+tracer is handed to them for that call (see ParseTraced/DriveParseTraced). Nested parses of
+parentheticals inherit the tracer of the parse that spawned them, with Nesting incremented.
+*/
+type ParseTracer interface {
+	Trace(e TraceEvent)
+}
+
+type TraceKind int
+
+const (
+	_           TraceKind = iota
+	TE_Step               // Read a character and moved from one state to the next
+	TE_Callback           // A TransitionCallback fired on the most recent transition
+	TE_End                // Input exhausted, parser moved into its end state
+	TE_Error              // A character, callback or the end of input was rejected
+)
+
+func (k TraceKind) ToString() string {
+	switch k {
+	case TE_Step:
+		return "step"
+	case TE_Callback:
+		return "callback"
+	case TE_End:
+		return "end"
+	case TE_Error:
+		return "error"
+	}
+	return "indeterminate event"
+}
+
+type TraceEvent struct {
+	Kind TraceKind
+	// States as printed by the front-end (ParserState.ToString() or LambdaParser.PState)
+	From string
+	To   string
+	Char string
+	// Byte offset of Char within the string handed to this (possibly nested) parse
+	Offset int
+	// ParenTracker counter after reading Char
+	Depth int
+	// Number of enclosing parentheticals this parse was spawned from
+	Nesting int
+	// Name of the callback for TE_Callback events
+	Callback string
+	// What went wrong for TE_Error events, To is empty as no transition was made
+	Err error
+}
+
+// TracerFunc adapts a plain function into a ParseTracer.
+type TracerFunc func(e TraceEvent)
+
+func (f TracerFunc) Trace(e TraceEvent) {
+	f(e)
+}
+
+// WriterTracer prints one human readable line per event, similar to the old debug output.
+type WriterTracer struct {
+	W io.Writer
+}
+
+func (w WriterTracer) Trace(e TraceEvent) {
+	indent := strings.Repeat("  ", e.Nesting)
+	switch e.Kind {
+	case TE_Step:
+		fmt.Fprintf(w.W, "%s%v -> %v reading char %q at %v (depth %v)\n",
+			indent, e.From, e.To, e.Char, e.Offset, e.Depth)
+	case TE_Callback:
+		fmt.Fprintf(w.W, "%s  callback %v on %v -> %v\n", indent, e.Callback, e.From, e.To)
+	case TE_End:
+		fmt.Fprintf(w.W, "%s%v -> %v end of input (depth %v)\n", indent, e.From, e.To, e.Depth)
+	case TE_Error:
+		fmt.Fprintf(w.W, "%s%v failed reading char %q at %v (depth %v): %v\n",
+			indent, e.From, e.Char, e.Offset, e.Depth, e.Err)
+	}
+}
+
+// SlogTracer forwards events to a slog.Logger as records at Level (zero value is Info).
+type SlogTracer struct {
+	Logger *slog.Logger
+	Level  slog.Level
+}
+
+func (s SlogTracer) Trace(e TraceEvent) {
+	attrs := []slog.Attr{
+		slog.String("from", e.From),
+		slog.String("to", e.To),
+		slog.Int("depth", e.Depth),
+		slog.Int("nesting", e.Nesting),
+	}
+	if e.Kind == TE_Step || e.Kind == TE_Error {
+		attrs = append(attrs, slog.String("char", e.Char), slog.Int("offset", e.Offset))
+	}
+	if e.Kind == TE_Callback {
+		attrs = append(attrs, slog.String("callback", e.Callback))
+	}
+	if e.Kind == TE_Error {
+		attrs = append(attrs, slog.String("error", e.Err.Error()))
+	}
+	s.Logger.LogAttrs(context.Background(), s.Level, "parse "+e.Kind.ToString(), attrs...)
+}
+
+// CallbackName recovers a readable name (e.g. "CaptureLVar") for a callback stored in a
+// TransitionCallbackMap.
+func CallbackName(cb TransitionCallback) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(cb).Pointer())
+	if fn == nil {
+		return "unknown callback"
+	}
+	name := strings.TrimSuffix(fn.Name(), "-fm")
+	return name[strings.LastIndex(name, ".")+1:]
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+func collectTrace(events *[]TraceEvent) ParseTracer {
+	return TracerFunc(func(e TraceEvent) {
+		*events = append(*events, e)
+	})
+}
+
+func TestTraceReportsFailedSteps(t *testing.T) {
+	executor := TransitionExecutor_Init()
+	cases := []struct {
+		name  string
+		parse func(tracer ParseTracer) error
+		char  string
+	}{
+		{"executor", func(tracer ParseTracer) error {
+			_, err := executor.ParseTraced("LX1.(X1)?", tracer)
+			return err
+		}, "?"},
+		{"executor end of input", func(tracer ParseTracer) error {
+			_, err := executor.ParseTraced("LX1.(X1", tracer)
+			return err
+		}, ""},
+		{"lambda parser", func(tracer ParseTracer) error {
+			parser := CreateParser("LX1.(X1)?")
+			_, err := parser.DriveParseTraced(tracer)
+			return err
+		}, "?"},
+		{"lambda parser stray paren", func(tracer ParseTracer) error {
+			parser := CreateParser("X1)")
+			_, err := parser.DriveParseTraced(tracer)
+			return err
+		}, ")"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			events := []TraceEvent{}
+			err := c.parse(collectTrace(&events))
+			if err == nil {
+				t.Fatalf("expected a parse error")
+			}
+			last := events[len(events)-1]
+			if last.Kind != TE_Error {
+				t.Fatalf("last event is %v, want error", last.Kind.ToString())
+			}
+			if last.Err != err && last.Err.Error() != err.Error() {
+				t.Errorf("traced error %q, returned %q", last.Err, err)
+			}
+			if last.Char != c.char {
+				t.Errorf("traced char %q, want %q", last.Char, c.char)
+			}
+		})
+	}
+}
+
+func TestTraceHasNoErrorEventsWithoutErrors(t *testing.T) {
+	executor := TransitionExecutor_Init()
+	events := []TraceEvent{}
+	if _, err := executor.ParseTraced("LX1.(X1)Y1", collectTrace(&events)); err != nil {
+		t.Fatal(err)
+	}
+	ended := false
+	for _, e := range events {
+		if e.Kind == TE_Error {
+			t.Errorf("unexpected error event %+v", e)
+		}
+		ended = ended || e.Kind == TE_End
+	}
+	if !ended {
+		t.Errorf("trace has no end event")
+	}
+}
+
+// Without a tracer neither parser writes anything, whether the parse succeeds or fails.
+func TestParseSilentWithoutTracer(t *testing.T) {
+	read, write, pipe_err := os.Pipe()
+	if pipe_err != nil {
+		t.Fatal(pipe_err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = write, write
+	executor := TransitionExecutor_Init()
+	for _, source := range []string{"LX1.(X1)Y1", "LX1.(X1?"} {
+		executor.Parse(source)
+		parser := CreateParser(source)
+		parser.DriveParse()
+	}
+	os.Stdout, os.Stderr = stdout, stderr
+	write.Close()
+	written, read_err := io.ReadAll(read)
+	if read_err != nil {
+		t.Fatal(read_err)
+	}
+	if len(written) > 0 {
+		t.Errorf("parsing wrote %q", written)
+	}
+}
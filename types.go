@@ -0,0 +1,411 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+/*
+	LType - types of the simply typed lambda calculus
+
+Types annotate lambda bindings, LX1:A→B.(...), and are written with base type names
+(letters and digits, e.g. A, NAT, BOOL), arrows "→" (or "->") associating to the right,
+and parentheses for grouping: (A→B)→A→B. System F adds universal types ∀A.T (see
+systemf.go), whose body extends as far right as possible.
+
+LType requires the following:
+  - TPrint() - the type as it would be written, with only the parentheses it needs
+  - TEquals(t2 LType) bool - structural equality
+*/
+type LType interface {
+	TPrint() string
+	TEquals(t2 LType) bool
+}
+
+type TBase struct {
+	Name string
+}
+
+type TArrow struct {
+	From LType
+	To   LType
+}
+
+func (t *TBase) TPrint() string {
+	return t.Name
+}
+
+func (t *TBase) TEquals(t2 LType) bool {
+	base, is_base := t2.(*TBase)
+	return is_base && base.Name == t.Name
+}
+
+func (t *TArrow) TPrint() string {
+	from := t.From.TPrint()
+	switch t.From.(type) {
+	case *TArrow, *TForall:
+		from = "(" + from + ")"
+	}
+	return from + "→" + t.To.TPrint()
+}
+
+func (t *TArrow) TEquals(t2 LType) bool {
+	arrow, is_arrow := t2.(*TArrow)
+	return is_arrow && t.From.TEquals(arrow.From) && t.To.TEquals(arrow.To)
+}
+
+// Parse a type annotation. Errors are *ParseError with offsets into s.
+func ParseType(s string) (LType, error) {
+	tp := typeParser{src: s}
+	t, parse_err := tp.arrow()
+	if parse_err != nil {
+		return nil, parse_err
+	}
+	if tp.pos < len(s) {
+		return nil, tp.error("Unexpected %q after the end of type %v", s[tp.pos:], t.TPrint())
+	}
+	return t, nil
+}
+
+type typeParser struct {
+	src string
+	pos int
+}
+
+func (tp *typeParser) error(format string, args ...any) error {
+	return &ParseError{Offset: tp.pos, State: LT, Err: fmt.Errorf(format, args...)}
+}
+
+// Consume an arrow token if one is next.
+func (tp *typeParser) arrowToken() bool {
+	for _, token := range []string{"→", "->"} {
+		if strings.HasPrefix(tp.src[tp.pos:], token) {
+			tp.pos += len(token)
+			return true
+		}
+	}
+	return false
+}
+
+func (tp *typeParser) arrow() (LType, error) {
+	from, from_err := tp.atom()
+	if from_err != nil {
+		return nil, from_err
+	}
+	if !tp.arrowToken() {
+		return from, nil
+	}
+	to, to_err := tp.arrow()
+	if to_err != nil {
+		return nil, to_err
+	}
+	return &TArrow{From: from, To: to}, nil
+}
+
+func (tp *typeParser) atom() (LType, error) {
+	if tp.pos >= len(tp.src) {
+		return nil, tp.error("Type annotation ended where a type was expected")
+	}
+	if tp.src[tp.pos] == '(' {
+		tp.pos += 1
+		inner, inner_err := tp.arrow()
+		if inner_err != nil {
+			return nil, inner_err
+		}
+		if tp.pos >= len(tp.src) || tp.src[tp.pos] != ')' {
+			return nil, tp.error("Expected ')' closing the parenthesized type %v", inner.TPrint())
+		}
+		tp.pos += 1
+		return inner, nil
+	}
+	if strings.HasPrefix(tp.src[tp.pos:], "∀") {
+		tp.pos += len("∀")
+		name := tp.name()
+		if len(name) == 0 {
+			return nil, tp.error("Expected the type variable bound by ∀ but found %q", tp.src[tp.pos:])
+		}
+		if tp.pos >= len(tp.src) || tp.src[tp.pos] != '.' {
+			return nil, tp.error("Expected '.' after ∀%v", name)
+		}
+		tp.pos += 1
+		body, body_err := tp.arrow()
+		if body_err != nil {
+			return nil, body_err
+		}
+		return &TForall{Var: name, Body: body}, nil
+	}
+	name := tp.name()
+	if len(name) == 0 {
+		return nil, tp.error("Expected a type name, ∀ or '(' but found %q", tp.src[tp.pos:])
+	}
+	return &TBase{Name: name}, nil
+}
+
+// Consume a type name, empty if none is next.
+func (tp *typeParser) name() string {
+	start := tp.pos
+	for _, r := range tp.src[tp.pos:] {
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') {
+			break
+		}
+		tp.pos += len(string(r))
+	}
+	return tp.src[start:tp.pos]
+}
+
+type TypeErrorKind int
+
+const (
+	_                 TypeErrorKind = iota
+	TY_Unbound                      // Variable neither bound nor given a type by the context
+	TY_Unannotated                  // Lambda binding without a type annotation
+	TY_NotFunction                  // Application of a term whose type is not an arrow
+	TY_Mismatch                     // Argument type differs from the function's domain
+	TY_Malformed                    // Term the checker cannot handle (empty, parse error node)
+	TY_Occurs                       // Inference needs a type containing itself, e.g. for X1X1
+	TY_Unify                        // Inference met two types that cannot be made equal
+	TY_NotPolymorphic               // Type argument given to a term whose type is not ∀A.T
+	TY_NotType                      // Term used as a type whose type is not a universe
+	TY_MissingLabel                 // Row inference met a closed row without a label it needs
+)
+
+func (k TypeErrorKind) ToString() string {
+	switch k {
+	case TY_Unbound:
+		return "unbound variable"
+	case TY_Unannotated:
+		return "missing annotation"
+	case TY_NotFunction:
+		return "not a function"
+	case TY_Mismatch:
+		return "type mismatch"
+	case TY_Malformed:
+		return "malformed term"
+	case TY_Occurs:
+		return "occurs check"
+	case TY_Unify:
+		return "unification failure"
+	case TY_NotPolymorphic:
+		return "not polymorphic"
+	case TY_NotType:
+		return "not a type"
+	case TY_MissingLabel:
+		return "missing label"
+	}
+	return "indeterminate type error"
+}
+
+// TypeError pins a checking failure to the term it happened in. Span is relative to the
+// string handed to the parser; Pos is only filled in by front-ends that know where that
+// string came from (e.g. CheckStatement).
+type TypeError struct {
+	Kind TypeErrorKind
+	Span Span
+	Pos  Position
+	// Offending term as printed by LPrint
+	Term string
+	// For TY_Mismatch the function's domain and the argument's type, for TY_NotFunction
+	// Found is the type of the term being applied
+	Expected LType
+	Found    LType
+	Message  string
+	// For inference failures, the constraints that led to the conflict, oldest first, and a
+	// guess at which subterm is wrong
+	Trace      []TypeConstraint
+	Suggestion string
+	// Subterm the suggestion blames, when it names one
+	SuggestionSpan Span
+}
+
+func (e *TypeError) Error() string {
+	if e.Pos.Line > 0 {
+		return fmt.Sprintf("%v: %v: %v", e.Pos.ToString(), e.Kind.ToString(), e.Message)
+	}
+	return fmt.Sprintf("offset %v: %v: %v", e.Span.Offset, e.Kind.ToString(), e.Message)
+}
+
+// Types of free variables.
+type TypeContext map[string]LType
+
+type stlcBinding struct {
+	Name string
+	Type LType
+}
+
+type TypeChecker struct {
+	// Spans recorded by the parser, used to locate errors
+	Spans   map[LExpr]Span
+	Context TypeContext
+}
+
+// Type of l in the simply typed lambda calculus, or a *TypeError.
+func CheckSTLC(l LExpr, spans map[LExpr]Span, context TypeContext) (LType, error) {
+	checker := TypeChecker{Spans: spans, Context: context}
+	return checker.Check(l)
+}
+
+func (tc TypeChecker) Check(l LExpr) (LType, error) {
+	return tc.check(l, []stlcBinding{}, Span{})
+}
+
+// Span of l, falling back to the enclosing term's for nodes built after parsing.
+func (tc TypeChecker) span(l LExpr, outer Span) Span {
+	if span, found := tc.Spans[l]; found {
+		return span
+	}
+	return outer
+}
+
+func (tc TypeChecker) check(l LExpr, scope []stlcBinding, outer Span) (LType, error) {
+	span := tc.span(l, outer)
+	switch node := l.(type) {
+	case *LVar:
+		for i := len(scope) - 1; i >= 0; i-- {
+			if scope[i].Name == node.Symbol {
+				return scope[i].Type, nil
+			}
+		}
+		if t, found := tc.Context[node.Symbol]; found {
+			return t, nil
+		}
+		return nil, &TypeError{Kind: TY_Unbound, Span: span, Term: node.Symbol,
+			Message: fmt.Sprintf("%v is not bound by a lambda and has no type in the context", node.Symbol)}
+	case *LExpression:
+		if len(node.Binding.Symbol) == 0 {
+			return tc.checkSeq(node, node.Exprs, scope, span)
+		}
+		if node.Binding.Type == nil {
+			return nil, &TypeError{Kind: TY_Unannotated, Span: span, Term: node.LPrint(),
+				Message: fmt.Sprintf("binding %v of %v needs a type annotation (λ%v:A.)",
+					node.Binding.Symbol, node.LPrint(), node.Binding.Symbol)}
+		}
+		inner_scope := append(scope[:len(scope):len(scope)], stlcBinding{Name: node.Binding.Symbol, Type: node.Binding.Type})
+		body, body_err := tc.checkSeq(node, node.Exprs, inner_scope, span)
+		if body_err != nil {
+			return nil, body_err
+		}
+		return &TArrow{From: node.Binding.Type, To: body}, nil
+	case *LLet:
+		bound_scope := scope
+		if node.Recursive && node.Binding.Type == nil {
+			return nil, &TypeError{Kind: TY_Unannotated, Span: span, Term: node.LPrint(),
+				Message: fmt.Sprintf("recursive binding %v of %v needs a type annotation (λ@%v:A=)",
+					node.Binding.Symbol, node.LPrint(), node.Binding.Symbol)}
+		} else if node.Recursive {
+			bound_scope = append(scope[:len(scope):len(scope)], stlcBinding{Name: node.Binding.Symbol, Type: node.Binding.Type})
+		}
+		bound, bound_err := tc.check(node.Bound, bound_scope, span)
+		if bound_err != nil {
+			return nil, bound_err
+		}
+		if node.Binding.Type != nil && !node.Binding.Type.TEquals(bound) {
+			return nil, &TypeError{Kind: TY_Mismatch, Span: tc.span(node.Bound, span), Term: node.Bound.LPrint(),
+				Expected: node.Binding.Type, Found: bound,
+				Message: fmt.Sprintf("%v has type %v but %v is annotated %v",
+					node.Bound.LPrint(), bound.TPrint(), node.Binding.Symbol, node.Binding.Type.TPrint())}
+		}
+		inner_scope := append(scope[:len(scope):len(scope)], stlcBinding{Name: node.Binding.Symbol, Type: bound})
+		return tc.check(node.Body, inner_scope, span)
+	case *LInt, *LBool, *LString:
+		return PrimType(node, nil), nil
+	case *LPrim:
+		if isPolymorphicPrim(node.Op) {
+			return nil, &TypeError{Kind: TY_Malformed, Span: span, Term: l.LPrint(),
+				Message: fmt.Sprintf("%v is polymorphic, it can only be typed applied to its arguments", l.LPrint())}
+		} else if t := PrimType(node, nil); t != nil {
+			return t, nil
+		}
+	}
+	return nil, &TypeError{Kind: TY_Malformed, Span: span, Term: l.LPrint(),
+		Message: fmt.Sprintf("cannot type %v", l.LPrint())}
+}
+
+// Primitives whose type has a variable, which the simply typed checker instantiates from the
+// type of one of their arguments.
+func isPolymorphicPrim(op PrimOp) bool {
+	return op == PRIM_Equal || op == PRIM_If || op == PRIM_Fix
+}
+
+// Type of the polymorphic prim applied to args: == at the type of its first argument, which
+// must be a base type, @IF at the type of its first branch and @FIX at the domain of its
+// function.
+func (tc TypeChecker) instantiatePrim(prim *LPrim, args []LExpr, scope []stlcBinding, span Span) (LType, error) {
+	index := 0
+	if prim.Op == PRIM_If {
+		index = 1
+	}
+	if len(args) <= index {
+		return nil, &TypeError{Kind: TY_Malformed, Span: span, Term: prim.LPrint(),
+			Message: fmt.Sprintf("%v is polymorphic, it can only be typed applied to its arguments", prim.LPrint())}
+	}
+	arg_type, arg_err := tc.check(args[index], scope, span)
+	if arg_err != nil {
+		return nil, arg_err
+	}
+	instance := arg_type
+	if arrow, is_arrow := arg_type.(*TArrow); prim.Op == PRIM_Fix && is_arrow {
+		instance = arrow.From
+	} else if prim.Op == PRIM_Fix {
+		return nil, &TypeError{Kind: TY_Mismatch, Span: tc.span(args[index], span), Term: args[index].LPrint(),
+			Found: arg_type, Message: fmt.Sprintf("%v has type %v but %v expects a function of type A→A",
+				args[index].LPrint(), arg_type.TPrint(), prim.LPrint())}
+	} else if _, is_base := arg_type.(*TBase); prim.Op == PRIM_Equal && !is_base {
+		return nil, &TypeError{Kind: TY_Mismatch, Span: tc.span(args[index], span), Term: args[index].LPrint(),
+			Found: arg_type, Message: fmt.Sprintf("%v has type %v but %v compares integers, booleans or strings",
+				args[index].LPrint(), arg_type.TPrint(), prim.LPrint())}
+	}
+	return PrimType(prim, func() LType { return instance }), nil
+}
+
+// Left-nested application of exprs, the body of node.
+func (tc TypeChecker) checkSeq(node LExpr, exprs []LExpr, scope []stlcBinding, span Span) (LType, error) {
+	if len(exprs) == 0 {
+		return nil, &TypeError{Kind: TY_Malformed, Span: span, Term: node.LPrint(), Message: "empty expression"}
+	}
+	var fun_type LType
+	var fun_err error
+	if prim, is_prim := exprs[0].(*LPrim); is_prim && isPolymorphicPrim(prim.Op) {
+		fun_type, fun_err = tc.instantiatePrim(prim, exprs[1:], scope, span)
+	} else {
+		fun_type, fun_err = tc.check(exprs[0], scope, span)
+	}
+	if fun_err != nil {
+		return nil, fun_err
+	}
+	fun_text := exprs[0].LPrint()
+	fun_span := tc.span(exprs[0], span)
+	for _, arg := range exprs[1:] {
+		arg_type, arg_err := tc.check(arg, scope, span)
+		if arg_err != nil {
+			return nil, arg_err
+		}
+		arrow, is_arrow := fun_type.(*TArrow)
+		if !is_arrow {
+			return nil, &TypeError{Kind: TY_NotFunction, Span: fun_span, Term: fun_text, Found: fun_type,
+				Message: fmt.Sprintf("%v has type %v and cannot be applied to %v", fun_text, fun_type.TPrint(), arg.LPrint())}
+		}
+		if !arrow.From.TEquals(arg_type) {
+			return nil, &TypeError{Kind: TY_Mismatch, Span: tc.span(arg, span), Term: arg.LPrint(),
+				Expected: arrow.From, Found: arg_type,
+				Message: fmt.Sprintf("%v has type %v but %v expects an argument of type %v",
+					arg.LPrint(), arg_type.TPrint(), fun_text, arrow.From.TPrint())}
+		}
+		fun_type = arrow.To
+		fun_text += arg.LPrint()
+		arg_span := tc.span(arg, span)
+		fun_span = Span{Offset: fun_span.Offset, End: max(fun_span.End, arg_span.End)}
+	}
+	return fun_type, nil
+}
+
+// Type the term of a decoded statement, positioning any error in the decoded input.
+func CheckStatement(stmt Statement, context TypeContext) (LType, error) {
+	t, check_err := CheckSTLC(stmt.Expr, stmt.Spans, context)
+	if type_err, is_type_err := check_err.(*TypeError); is_type_err {
+		located := *type_err
+		located.Pos = stmt.Locate(located.Span.Offset)
+		return nil, &located
+	}
+	return t, check_err
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Both front ends read typed binders alike, annotations with parentheses included.
+func TestTypedBindersParseAlike(t *testing.T) {
+	cases := []struct {
+		source string
+		want   string
+	}{
+		{"LX1:INT.(X1)", "λX1:INT.X1"},
+		{"LF1:(INT->BOOL)->INT.(F1(LX1:INT.(X1)))", "λF1:(INT→BOOL)→INT.(F1λX1:INT.X1)"},
+		{"LX1.(LY1:BOOL.(X1))", "λX1.λY1:BOOL.X1"},
+	}
+	executor := TransitionExecutor_Init()
+	for _, c := range cases {
+		p, fsm_err := executor.Parse(c.source)
+		parser := CreateParser(c.source)
+		exprs, lp_err := parser.DriveParse()
+		if fsm_err != nil || lp_err != nil {
+			t.Errorf("%v: %v, %v", c.source, fsm_err, lp_err)
+			continue
+		}
+		for name, got := range map[string]string{"executor": SingleLExpr(p.Exprs).LPrint(), "lambda parser": SingleLExpr(exprs).LPrint()} {
+			if got != c.want {
+				t.Errorf("%v: %v parsed %v, want %v", c.source, name, got, c.want)
+			}
+		}
+	}
+}
+
+func TestTypedBinderErrorsAgree(t *testing.T) {
+	executor := TransitionExecutor_Init()
+	for _, source := range []string{"LX1:INT->.(X1)", "LX1:INT(X1)", "LX1:(INT.(X1)", "LX1:IN T.(X1)"} {
+		_, fsm_err := executor.Parse(source)
+		parser := CreateParser(source)
+		_, lp_err := parser.DriveParse()
+		if fsm_err == nil || lp_err == nil || fsm_err.Error() != lp_err.Error() {
+			t.Errorf("%v: executor reported %v, lambda parser %v", source, fsm_err, lp_err)
+		}
+	}
+}
+
+func TestCheckCommandTakesATerm(t *testing.T) {
+	var out strings.Builder
+	if check_err := RunCommand([]string{"check", "LF1:INT->INT.(LX1:INT.(F1(F1(X1))))"}, &out); check_err != nil {
+		t.Fatal(check_err)
+	}
+	if want := "λF1:INT→INT.λX1:INT.(F1(F1X1)) : (INT→INT)→INT→INT\n"; out.String() != want {
+		t.Errorf("printed %q, want %q", out.String(), want)
+	}
+	out.Reset()
+	if check_err := RunCommand([]string{"check", "LX1:INT.(X1(X1))"}, &out); check_err == nil {
+		t.Errorf("applying an INT checked")
+	}
+	if !strings.Contains(out.String(), "not a function") {
+		t.Errorf("printed %q", out.String())
+	}
+}
+
+func TestCheckPrimitives(t *testing.T) {
+	cases := []struct {
+		source string
+		// Printed type, or part of the error
+		want string
+	}{
+		{"LX1:INT.(+(X1)(1))", "INT→INT"},
+		{"~5", "INT"},
+		{"<", "INT→INT→BOOL"},
+		{"LX1:BOOL.(@IF(X1)(\"a\")(\"b\"))", "BOOL→STR"},
+		{"==(\"a\")(\"b\")", "BOOL"},
+		{"@FIX(LF1:INT->INT.(LN1:INT.(@IF(<(N1)(1))(0)(+(N1)(F1(-(N1)(1)))))))(10)", "INT"},
+		{"==", "polymorphic"},
+		{"==(1)(@TRUE)", "expects an argument of type INT"},
+		{"==(LX1:INT.(X1))", "compares integers, booleans or strings"},
+		{"@FIX(1)", "expects a function"},
+	}
+	executor := TransitionExecutor_Init()
+	for _, c := range cases {
+		p, parse_err := executor.Parse(c.source)
+		if parse_err != nil {
+			t.Errorf("%v: %v", c.source, parse_err)
+			continue
+		}
+		got, check_err := CheckSTLC(SingleLExpr(p.Exprs), nil, nil)
+		if check_err != nil {
+			if !strings.Contains(check_err.Error(), c.want) {
+				t.Errorf("%v: %v, want %v", c.source, check_err, c.want)
+			}
+		} else if got.TPrint() != c.want {
+			t.Errorf("%v: typed %v, want %v", c.source, got.TPrint(), c.want)
+		}
+	}
+}